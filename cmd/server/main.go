@@ -2,8 +2,10 @@ package main
 
 import (
 	"log"
+	"time"
 	"wallet-point/config"
 	"wallet-point/internal/database"
+	"wallet-point/middleware"
 	"wallet-point/routes"
 	"wallet-point/utils"
 
@@ -40,6 +42,17 @@ func main() {
 	// Initialize JWT
 	utils.InitJWT(cfg.JWTSecret)
 
+	// Initialize QR payload signing. Without QR_SIGNING_KEY the key is
+	// derived from the JWT secret, never the secret itself
+	qrSigningKey := cfg.QRSigningKey
+	if qrSigningKey == "" {
+		qrSigningKey = utils.DeriveSigningKey(cfg.JWTSecret)
+	}
+	utils.InitSigning(qrSigningKey)
+
+	// Count days and months, e.g. for spending limits, in the campus time zone
+	utils.InitLocation(cfg.TimeZone)
+
 	// Connect to database
 	db := config.ConnectDB(cfg)
 
@@ -50,7 +63,16 @@ func main() {
 	r := gin.Default()
 
 	// Setup routes
-	routes.SetupRoutes(r, db, cfg.AllowedOrigins, cfg.JWTExpiryHours, cfg.UploadPath)
+	services := routes.SetupRoutes(r, db, cfg.AllowedOrigins, cfg.JWTExpiryHours, cfg.UploadPath)
+
+	// Start background jobs
+	go services.Marketplace.RunEscrowTimeouts(15 * time.Minute)
+	go services.Wallet.RunPointExpiry(time.Hour)
+	go services.Wallet.RunHoldExpiry(5 * time.Minute)
+	go services.Wallet.RunTokenSweeper(time.Minute)
+	go services.Wallet.RunFreezeExpiry(time.Minute)
+	go services.Transfer.RunScheduledTransfers(time.Minute)
+	go middleware.RunIdempotencyCleanup(services.Idempotency, time.Hour)
 
 	// Start server
 	serverAddress := cfg.ServerAddress
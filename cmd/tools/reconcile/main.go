@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"wallet-point/config"
+	"wallet-point/internal/wallet"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Recomputes every wallet balance from its successful transactions and prints
+// the wallets that drifted. With -repair, correction entries are posted so
+// the history adds up to the balance again. Exits 1 if unrepaired drift remains.
+func main() {
+	repair := flag.Bool("repair", false, "post correction entries for drifting wallets")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	walletService := wallet.NewWalletService(wallet.NewWalletRepository(db), db)
+
+	report, err := walletService.Reconcile(*repair, "system")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Checked %d wallets at %s\n", report.WalletsChecked, report.CheckedAt.Format("2006-01-02 15:04:05"))
+	if report.DriftCount == 0 {
+		fmt.Println("✅ All balances match their transaction history")
+		return
+	}
+
+	fmt.Printf("%-8s %-8s %-30s %10s %10s %10s  %s\n", "WALLET", "USER", "NAME", "BALANCE", "HISTORY", "DRIFT", "STATUS")
+	unrepaired := 0
+	for _, d := range report.Drifts {
+		status := "drift"
+		if d.Corrected {
+			status = "corrected"
+		} else {
+			unrepaired++
+		}
+		fmt.Printf("%-8d %-8d %-30s %10d %10d %+10d  %s\n", d.WalletID, d.UserID, d.FullName, d.Balance, d.ComputedBalance, d.Drift, status)
+	}
+	fmt.Printf("%d wallets drifted, total drift %d points\n", report.DriftCount, report.TotalDrift)
+
+	if unrepaired > 0 {
+		if !*repair {
+			fmt.Println("Run with -repair to post correction entries")
+		}
+		os.Exit(1)
+	}
+}
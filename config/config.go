@@ -20,6 +20,8 @@ type Config struct {
 	DBName         string
 	JWTSecret      string
 	JWTExpiryHours int
+	QRSigningKey   string // Derived from JWTSecret if unset, see utils.DeriveSigningKey
+	TimeZone       string
 	AllowedOrigins string
 	MaxUploadSize  int64
 	UploadPath     string
@@ -58,6 +60,8 @@ func LoadConfig() *Config {
 		DBName:         getEnv("DB_NAME", "railway"),
 		JWTSecret:      getEnv("JWT_SECRET", "H6RoFvCDVvlXU33SXXsi2anbRF/mafbH9O1+QWoulji6n8xtgiVXeorrSJTwr83LDVVX8wxYexICnyCyjpg=="),
 		JWTExpiryHours: jwtExpiry,
+		QRSigningKey:   getEnv("QR_SIGNING_KEY", ""),
+		TimeZone:       getEnv("TIME_ZONE", "Asia/Jakarta"),
 		AllowedOrigins: getEnv("ALLOWED_ORIGINS", "*"),
 		MaxUploadSize:  maxUploadSize,
 		UploadPath:     getEnv("UPLOAD_PATH", "./uploads"),
@@ -12,8 +12,11 @@ func NewAuditRepository(db *gorm.DB) *AuditRepository {
 	return &AuditRepository{db: db}
 }
 
-func (r *AuditRepository) Create(log *AuditLog) error {
-	return r.db.Create(log).Error
+func (r *AuditRepository) Create(tx *gorm.DB, log *AuditLog) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(log).Error
 }
 
 func (r *AuditRepository) FindAll(params AuditListParams) ([]AuditLogWithUser, int64, error) {
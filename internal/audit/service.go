@@ -3,6 +3,8 @@ package audit
 import (
 	"math"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type AuditService struct {
@@ -15,6 +17,12 @@ func NewAuditService(repo *AuditRepository) *AuditService {
 
 // LogActivity records a system activity
 func (s *AuditService) LogActivity(params CreateAuditParams) error {
+	return s.LogActivityWithTx(nil, params)
+}
+
+// LogActivityWithTx records an activity inside tx, so the entry is only kept
+// if the activity it describes commits
+func (s *AuditService) LogActivityWithTx(tx *gorm.DB, params CreateAuditParams) error {
 	log := &AuditLog{
 		UserID:    params.UserID,
 		Action:    params.Action,
@@ -26,7 +34,7 @@ func (s *AuditService) LogActivity(params CreateAuditParams) error {
 		CreatedAt: time.Now(),
 	}
 
-	return s.repo.Create(log)
+	return s.repo.Create(tx, log)
 }
 
 // GetLogs retrieves logs for admin
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"wallet-point/internal/audit"
 	"wallet-point/utils"
@@ -173,7 +174,13 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 
 	user, err := h.service.UpdateProfile(userID, &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update profile", err.Error())
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, ErrHandleTaken) {
+			statusCode = http.StatusConflict
+		} else if errors.Is(err, ErrInvalidHandle) {
+			statusCode = http.StatusBadRequest
+		}
+		utils.ErrorResponse(c, statusCode, "Failed to update profile", err.Error())
 		return
 	}
 
@@ -13,6 +13,7 @@ type User struct {
 	Role         string    `json:"role" gorm:"type:enum('admin','dosen','mahasiswa');not null"`
 	Status       string    `json:"status" gorm:"type:enum('active','inactive','suspended');default:'active'"`
 	PinHash      string    `json:"-" gorm:"column:pin_hash"`
+	Handle       *string   `json:"handle" gorm:"type:varchar(30);uniqueIndex"` // Optional @name others can send points to
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -56,7 +57,8 @@ type UserSummary struct {
 	Balance  int    `json:"balance,omitempty"`
 }
 type UpdateProfileRequest struct {
-	FullName string `json:"full_name" binding:"required"`
+	FullName string  `json:"full_name" binding:"required"`
+	Handle   *string `json:"handle"` // Omit to keep the handle, empty to remove it
 }
 
 type UpdatePasswordRequest struct {
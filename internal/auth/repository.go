@@ -52,6 +52,13 @@ func (r *AuthRepository) CheckEmailExists(email string) (bool, error) {
 	return count > 0, err
 }
 
+// CheckHandleTaken checks if a handle belongs to a user other than userID
+func (r *AuthRepository) CheckHandleTaken(handle string, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&User{}).Where("handle = ? AND id <> ?", handle, userID).Count(&count).Error
+	return count > 0, err
+}
+
 // CheckNimNipExists checks if NIM/NIP already exists
 func (r *AuthRepository) CheckNimNipExists(nimNip string) (bool, error) {
 	var count int64
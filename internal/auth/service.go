@@ -3,9 +3,20 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"wallet-point/utils"
 )
 
+var (
+	ErrInvalidHandle = errors.New("handle must be 3-30 letters, digits, dots or underscores and not only digits")
+	ErrHandleTaken   = errors.New("handle is already taken")
+
+	// Handles are kept apart from NIMs, which are all digits
+	handlePattern = regexp.MustCompile(`^[a-z0-9_.]{3,30}$`)
+	onlyDigits    = regexp.MustCompile(`^[0-9]+$`)
+)
+
 type AuthService struct {
 	repo      *AuthRepository
 	jwtExpiry int
@@ -139,6 +150,26 @@ func (s *AuthService) UpdateProfile(userID uint, req *UpdateProfileRequest) (*Us
 	updates := map[string]interface{}{
 		"full_name": req.FullName,
 	}
+
+	if req.Handle != nil {
+		handle := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(*req.Handle), "@"))
+		if handle == "" {
+			updates["handle"] = nil
+		} else {
+			if !handlePattern.MatchString(handle) || onlyDigits.MatchString(handle) {
+				return nil, ErrInvalidHandle
+			}
+			taken, err := s.repo.CheckHandleTaken(handle, userID)
+			if err != nil {
+				return nil, err
+			}
+			if taken {
+				return nil, ErrHandleTaken
+			}
+			updates["handle"] = handle
+		}
+	}
+
 	if err := s.repo.Update(userID, updates); err != nil {
 		return nil, err
 	}
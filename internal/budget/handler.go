@@ -0,0 +1,147 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"wallet-point/internal/audit"
+	"wallet-point/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BudgetHandler struct {
+	service      *BudgetService
+	auditService *audit.AuditService
+}
+
+func NewBudgetHandler(service *BudgetService, auditService *audit.AuditService) *BudgetHandler {
+	return &BudgetHandler{service: service, auditService: auditService}
+}
+
+// RespondIfBudgetError writes a 422 response carrying the budget error if err
+// was caused by a lecturer's budget. It reports whether a response was written.
+func RespondIfBudgetError(c *gin.Context, err error) bool {
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) {
+		return false
+	}
+	utils.ErrorResponse(c, http.StatusUnprocessableEntity, budgetErr.Error(), budgetErr)
+	return true
+}
+
+// GetBudgets handles listing point budgets
+// @Summary Get point budgets
+// @Description List the point budgets of every lecturer for a semester with what they spent (Admin only)
+// @Tags Admin - Budgets
+// @Security BearerAuth
+// @Produce json
+// @Param semester query string false "Semester (YYYY-1 or YYYY-2), defaults to the current one"
+// @Success 200 {object} utils.Response{data=[]PointBudgetWithDosen}
+// @Router /admin/budgets [get]
+func (h *BudgetHandler) GetBudgets(c *gin.Context) {
+	budgets, err := h.service.GetBudgets(c.Query("semester"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Point budgets retrieved successfully", budgets)
+}
+
+// AllocateBudget handles setting a lecturer's point budget
+// @Summary Allocate point budget
+// @Description Set how many points a lecturer may award in a semester and whether rewards beyond it are rejected or queued for approval (Admin only)
+// @Tags Admin - Budgets
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body AllocateBudgetRequest true "Budget details"
+// @Success 200 {object} utils.Response{data=PointBudget}
+// @Failure 400 {object} utils.Response
+// @Router /admin/budgets [post]
+func (h *BudgetHandler) AllocateBudget(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req AllocateBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	budget, err := h.service.AllocateBudget(&req, adminID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Point budget allocated successfully", budget)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "ALLOCATE_BUDGET",
+		Entity:    "POINT_BUDGET",
+		EntityID:  budget.ID,
+		Details:   fmt.Sprintf("Admin allocated %d points to dosen %d for semester %s (over budget: %s)", budget.Allocated, budget.DosenID, budget.Semester, budget.OverBudget),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetRewardRequests handles listing queued rewards
+// @Summary Get reward requests
+// @Description List rewards that exceeded a lecturer's budget and wait for approval (Admin only)
+// @Tags Admin - Budgets
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Filter by status (pending, approved, rejected)"
+// @Success 200 {object} utils.Response{data=[]RewardRequestWithUsers}
+// @Router /admin/budgets/requests [get]
+func (h *BudgetHandler) GetRewardRequests(c *gin.Context) {
+	requests, err := h.service.GetRewardRequests(c.Query("status"), 0)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve reward requests", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reward requests retrieved successfully", requests)
+}
+
+// GetMyBudget handles the lecturer's budget dashboard
+// @Summary Get my point budget
+// @Description Get the current lecturer's budget for a semester, what was spent and what is left
+// @Tags Dosen - Budget
+// @Security BearerAuth
+// @Produce json
+// @Param semester query string false "Semester (YYYY-1 or YYYY-2), defaults to the current one"
+// @Success 200 {object} utils.Response{data=BudgetDashboard}
+// @Router /dosen/budget [get]
+func (h *BudgetHandler) GetMyBudget(c *gin.Context) {
+	dashboard, err := h.service.GetDashboard(c.GetUint("user_id"), c.Query("semester"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Point budget retrieved successfully", dashboard)
+}
+
+// GetMyRewardRequests handles listing the lecturer's queued rewards
+// @Summary Get my reward requests
+// @Description List the current lecturer's rewards that exceeded the budget and their review status
+// @Tags Dosen - Budget
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Filter by status (pending, approved, rejected)"
+// @Success 200 {object} utils.Response{data=[]RewardRequestWithUsers}
+// @Router /dosen/budget/requests [get]
+func (h *BudgetHandler) GetMyRewardRequests(c *gin.Context) {
+	requests, err := h.service.GetRewardRequests(c.Query("status"), c.GetUint("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve reward requests", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reward requests retrieved successfully", requests)
+}
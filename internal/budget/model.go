@@ -0,0 +1,147 @@
+package budget
+
+import (
+	"fmt"
+	"time"
+)
+
+// CodeBudgetExceeded is returned when a reward does not fit a lecturer's
+// budget and the budget rejects overspending
+const CodeBudgetExceeded = "POINT_BUDGET_EXCEEDED"
+
+// PointBudget caps the points a lecturer may award in one semester, through
+// mission rewards and manual rewards alike. Semesters are written as
+// "2026-1" (January to June) and "2026-2" (July to December).
+type PointBudget struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	DosenID    uint      `json:"dosen_id" gorm:"not null;uniqueIndex:idx_point_budget_dosen_semester"`
+	Semester   string    `json:"semester" gorm:"size:10;not null;uniqueIndex:idx_point_budget_dosen_semester"`
+	Allocated  int       `json:"allocated" gorm:"not null"`
+	OverBudget string    `json:"over_budget" gorm:"type:enum('reject','queue');default:'queue';not null"` // What happens to rewards the budget cannot cover
+	Note       string    `json:"note" gorm:"size:255"`
+	CreatedBy  uint      `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Virtual fields for response
+	Spent     int `json:"spent" gorm:"-"`
+	Remaining int `json:"remaining" gorm:"-"`
+}
+
+func (PointBudget) TableName() string {
+	return "point_budgets"
+}
+
+type PointBudgetWithDosen struct {
+	PointBudget
+	DosenName string `json:"dosen_name"`
+	DosenNIP  string `json:"dosen_nip"`
+}
+
+// BudgetCharge is one reward paid out of a lecturer's budget. The spent
+// amount of a budget is the sum of its charges.
+type BudgetCharge struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	DosenID     uint      `json:"dosen_id" gorm:"not null;index:idx_budget_charge_dosen_semester"`
+	Semester    string    `json:"semester" gorm:"size:10;not null;index:idx_budget_charge_dosen_semester"`
+	Kind        string    `json:"kind" gorm:"type:enum('mission','reward','adjustment');not null"`
+	UserID      uint      `json:"user_id" gorm:"not null"` // Rewarded student
+	Amount      int       `json:"amount" gorm:"not null"`
+	Description string    `json:"description" gorm:"size:500"`
+	ReferenceID *uint     `json:"reference_id"` // Mission or reward batch
+	RequestID   *uint     `json:"request_id"`   // Set when an admin approved the reward
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (BudgetCharge) TableName() string {
+	return "point_budget_charges"
+}
+
+// RewardRequest is a reward the lecturer's budget could not cover. It is paid
+// only once an admin approves it.
+type RewardRequest struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	DosenID       uint       `json:"dosen_id" gorm:"not null;index"`
+	Semester      string     `json:"semester" gorm:"size:10;not null"`
+	Kind          string     `json:"kind" gorm:"type:enum('mission','reward','adjustment');not null"`
+	UserID        uint       `json:"user_id" gorm:"not null"`
+	Amount        int        `json:"amount" gorm:"not null"`
+	Description   string     `json:"description" gorm:"size:500"`
+	ReferenceID   *uint      `json:"reference_id"`
+	Status        string     `json:"status" gorm:"type:enum('pending','approved','rejected');default:'pending';index"`
+	ReviewedBy    *uint      `json:"reviewed_by"`
+	ReviewNote    string     `json:"review_note" gorm:"size:255"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	TransactionID *uint      `json:"transaction_id"` // Credit made on approval
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (RewardRequest) TableName() string {
+	return "point_reward_requests"
+}
+
+type RewardRequestWithUsers struct {
+	RewardRequest
+	DosenName   string `json:"dosen_name"`
+	StudentName string `json:"student_name"`
+	StudentNIM  string `json:"student_nim"`
+}
+
+// Charge is a reward to be taken from a lecturer's budget
+type Charge struct {
+	DosenID     uint
+	UserID      uint
+	Kind        string
+	Amount      int
+	Description string
+	ReferenceID *uint
+}
+
+type AllocateBudgetRequest struct {
+	DosenID    uint   `json:"dosen_id" binding:"required"`
+	Semester   string `json:"semester"` // Defaults to the current semester
+	Allocated  int    `json:"allocated" binding:"gte=0"`
+	OverBudget string `json:"over_budget" binding:"omitempty,oneof=reject queue"`
+	Note       string `json:"note" binding:"max=255"`
+}
+
+type ReviewRewardRequest struct {
+	Status string `json:"status" binding:"required,oneof=approved rejected"`
+	Note   string `json:"note" binding:"max=255"`
+}
+
+type BudgetKindTotal struct {
+	Kind   string `json:"kind"`
+	Count  int    `json:"count"`
+	Amount int    `json:"amount"`
+}
+
+// BudgetDashboard shows a lecturer how much of a semester's budget is left
+type BudgetDashboard struct {
+	DosenID       uint              `json:"dosen_id"`
+	Semester      string            `json:"semester"`
+	PeriodStart   time.Time         `json:"period_start"`
+	PeriodEnd     time.Time         `json:"period_end"`
+	HasBudget     bool              `json:"has_budget"`
+	Allocated     int               `json:"allocated"`
+	Spent         int               `json:"spent"`
+	Remaining     int               `json:"remaining"`
+	OverBudget    string            `json:"over_budget"`
+	PendingCount  int               `json:"pending_count"`
+	PendingAmount int               `json:"pending_amount"`
+	ByKind        []BudgetKindTotal `json:"by_kind"`
+	RecentCharges []BudgetCharge    `json:"recent_charges"`
+}
+
+// BudgetError reports a reward the lecturer's budget rejected
+type BudgetError struct {
+	Code      string `json:"code"`
+	Semester  string `json:"semester"`
+	Allocated int    `json:"allocated"`
+	Spent     int    `json:"spent"`
+	Attempted int    `json:"attempted"`
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("point budget of %d points for semester %s exceeded (%d already spent, %d requested)", e.Allocated, e.Semester, e.Spent, e.Attempted)
+}
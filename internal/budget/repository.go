@@ -0,0 +1,169 @@
+package budget
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BudgetRepository struct {
+	db *gorm.DB
+}
+
+func NewBudgetRepository(db *gorm.DB) *BudgetRepository {
+	return &BudgetRepository{db: db}
+}
+
+// GetAll lists the budgets of a semester with their lecturers
+func (r *BudgetRepository) GetAll(semester string) ([]PointBudgetWithDosen, error) {
+	var budgets []PointBudgetWithDosen
+	err := r.db.Table("point_budgets").
+		Select("point_budgets.*, users.full_name as dosen_name, users.nim_nip as dosen_nip").
+		Joins("LEFT JOIN users ON users.id = point_budgets.dosen_id").
+		Where("point_budgets.semester = ?", semester).
+		Order("users.full_name ASC").
+		Scan(&budgets).Error
+	return budgets, err
+}
+
+// FindBudget finds the budget of a lecturer for a semester, nil if there is none
+func (r *BudgetRepository) FindBudget(tx *gorm.DB, dosenID uint, semester string) (*PointBudget, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var budget PointBudget
+	err := tx.Where("dosen_id = ? AND semester = ?", dosenID, semester).First(&budget).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// LockBudget is FindBudget that also locks the budget until the transaction
+// ends, so concurrent rewards are checked against it one at a time
+func (r *BudgetRepository) LockBudget(tx *gorm.DB, dosenID uint, semester string) (*PointBudget, error) {
+	return r.FindBudget(tx.Clauses(clause.Locking{Strength: "UPDATE"}), dosenID, semester)
+}
+
+func (r *BudgetRepository) SaveBudget(budget *PointBudget) error {
+	return r.db.Save(budget).Error
+}
+
+// SumCharges totals what a lecturer spent in a semester
+func (r *BudgetRepository) SumCharges(tx *gorm.DB, dosenID uint, semester string) (int, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var total int
+	err := tx.Model(&BudgetCharge{}).
+		Where("dosen_id = ? AND semester = ?", dosenID, semester).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// SumChargesByKind totals what a lecturer spent in a semester per kind of reward
+func (r *BudgetRepository) SumChargesByKind(dosenID uint, semester string) ([]BudgetKindTotal, error) {
+	var totals []BudgetKindTotal
+	err := r.db.Model(&BudgetCharge{}).
+		Select("kind, COUNT(*) as count, COALESCE(SUM(amount), 0) as amount").
+		Where("dosen_id = ? AND semester = ?", dosenID, semester).
+		Group("kind").
+		Order("kind ASC").
+		Scan(&totals).Error
+	return totals, err
+}
+
+func (r *BudgetRepository) GetCharges(dosenID uint, semester string, limit int) ([]BudgetCharge, error) {
+	var charges []BudgetCharge
+	err := r.db.Where("dosen_id = ? AND semester = ?", dosenID, semester).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&charges).Error
+	return charges, err
+}
+
+func (r *BudgetRepository) CreateCharge(tx *gorm.DB, charge *BudgetCharge) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(charge).Error
+}
+
+func (r *BudgetRepository) CreateRequest(tx *gorm.DB, request *RewardRequest) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(request).Error
+}
+
+// LockRequest finds a reward request and locks it until the transaction ends
+func (r *BudgetRepository) LockRequest(tx *gorm.DB, requestID uint) (*RewardRequest, error) {
+	var request RewardRequest
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("reward request not found")
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *BudgetRepository) UpdateRequest(tx *gorm.DB, requestID uint, updates map[string]interface{}) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&RewardRequest{}).Where("id = ?", requestID).Updates(updates).Error
+}
+
+// GetRequests lists reward requests with the lecturer and student, filtered
+// by status and lecturer when given
+func (r *BudgetRepository) GetRequests(status string, dosenID uint) ([]RewardRequestWithUsers, error) {
+	var requests []RewardRequestWithUsers
+	query := r.db.Table("point_reward_requests").
+		Select("point_reward_requests.*, dosen.full_name as dosen_name, student.full_name as student_name, student.nim_nip as student_nim").
+		Joins("LEFT JOIN users dosen ON dosen.id = point_reward_requests.dosen_id").
+		Joins("LEFT JOIN users student ON student.id = point_reward_requests.user_id")
+	if status != "" {
+		query = query.Where("point_reward_requests.status = ?", status)
+	}
+	if dosenID != 0 {
+		query = query.Where("point_reward_requests.dosen_id = ?", dosenID)
+	}
+	err := query.Order("point_reward_requests.created_at DESC").Scan(&requests).Error
+	return requests, err
+}
+
+// SumPendingRequests counts and totals the pending reward requests of a lecturer
+func (r *BudgetRepository) SumPendingRequests(dosenID uint, semester string) (int, int, error) {
+	var result struct {
+		Count  int
+		Amount int
+	}
+	err := r.db.Model(&RewardRequest{}).
+		Select("COUNT(*) as count, COALESCE(SUM(amount), 0) as amount").
+		Where("dosen_id = ? AND semester = ? AND status = ?", dosenID, semester, "pending").
+		Scan(&result).Error
+	return result.Count, result.Amount, err
+}
+
+// GetUserRole returns the role of a user
+func (r *BudgetRepository) GetUserRole(tx *gorm.DB, userID uint) (string, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var role string
+	err := tx.Table("users").Where("id = ?", userID).Select("role").Scan(&role).Error
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return "", errors.New("user not found")
+	}
+	return role, nil
+}
@@ -0,0 +1,277 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"wallet-point/utils"
+
+	"gorm.io/gorm"
+)
+
+type BudgetService struct {
+	repo *BudgetRepository
+}
+
+func NewBudgetService(repo *BudgetRepository) *BudgetService {
+	return &BudgetService{repo: repo}
+}
+
+// GetBudgets lists the budgets of a semester, the current one when empty
+func (s *BudgetService) GetBudgets(semester string) ([]PointBudgetWithDosen, error) {
+	if semester == "" {
+		semester = SemesterOf(time.Now())
+	}
+	if _, _, err := semesterPeriod(semester); err != nil {
+		return nil, err
+	}
+
+	budgets, err := s.repo.GetAll(semester)
+	if err != nil {
+		return nil, err
+	}
+	for i := range budgets {
+		if err := s.fillSpent(&budgets[i].PointBudget); err != nil {
+			return nil, err
+		}
+	}
+	return budgets, nil
+}
+
+// AllocateBudget sets the budget of a lecturer for a semester, replacing the
+// allocation if one exists
+func (s *BudgetService) AllocateBudget(req *AllocateBudgetRequest, adminID uint) (*PointBudget, error) {
+	if req.Semester == "" {
+		req.Semester = SemesterOf(time.Now())
+	}
+	if _, _, err := semesterPeriod(req.Semester); err != nil {
+		return nil, err
+	}
+
+	role, err := s.repo.GetUserRole(nil, req.DosenID)
+	if err != nil {
+		return nil, err
+	}
+	if role != "dosen" {
+		return nil, errors.New("budgets can only be allocated to dosen")
+	}
+
+	budget, err := s.repo.FindBudget(nil, req.DosenID, req.Semester)
+	if err != nil {
+		return nil, err
+	}
+	if budget == nil {
+		budget = &PointBudget{DosenID: req.DosenID, Semester: req.Semester, CreatedBy: adminID}
+	}
+	budget.Allocated = req.Allocated
+	budget.OverBudget = req.OverBudget
+	if budget.OverBudget == "" {
+		budget.OverBudget = "queue"
+	}
+	budget.Note = req.Note
+
+	if err := s.repo.SaveBudget(budget); err != nil {
+		return nil, err
+	}
+	if err := s.fillSpent(budget); err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
+
+// GetDashboard shows a lecturer what they spent of a semester's budget, the
+// current semester when empty
+func (s *BudgetService) GetDashboard(dosenID uint, semester string) (*BudgetDashboard, error) {
+	if semester == "" {
+		semester = SemesterOf(time.Now())
+	}
+	start, end, err := semesterPeriod(semester)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &BudgetDashboard{
+		DosenID:     dosenID,
+		Semester:    semester,
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+
+	budget, err := s.repo.FindBudget(nil, dosenID, semester)
+	if err != nil {
+		return nil, err
+	}
+	if budget != nil {
+		dashboard.HasBudget = true
+		dashboard.Allocated = budget.Allocated
+		dashboard.OverBudget = budget.OverBudget
+	}
+
+	if dashboard.Spent, err = s.repo.SumCharges(nil, dosenID, semester); err != nil {
+		return nil, err
+	}
+	dashboard.Remaining = max(dashboard.Allocated-dashboard.Spent, 0)
+
+	if dashboard.PendingCount, dashboard.PendingAmount, err = s.repo.SumPendingRequests(dosenID, semester); err != nil {
+		return nil, err
+	}
+	if dashboard.ByKind, err = s.repo.SumChargesByKind(dosenID, semester); err != nil {
+		return nil, err
+	}
+	if dashboard.RecentCharges, err = s.repo.GetCharges(dosenID, semester, 20); err != nil {
+		return nil, err
+	}
+	return dashboard, nil
+}
+
+// GetRewardRequests lists reward requests waiting for or past admin review.
+// A non-zero dosenID limits them to one lecturer.
+func (s *BudgetService) GetRewardRequests(status string, dosenID uint) ([]RewardRequestWithUsers, error) {
+	return s.repo.GetRequests(status, dosenID)
+}
+
+// Charge takes a reward from the budget of the lecturer giving it. Rewards by
+// the system or anyone but a dosen are not budgeted. Call it inside the
+// transaction that pays the reward: the budget stays locked until it ends.
+//
+// When the budget cannot cover the reward it is either rejected with a
+// BudgetError or stored as a RewardRequest for admin approval, depending on
+// the budget. A lecturer without a budget for the semester always queues.
+// A returned request means the caller must not pay the reward now.
+func (s *BudgetService) Charge(tx *gorm.DB, c Charge) (*RewardRequest, error) {
+	if c.DosenID == 0 {
+		return nil, nil
+	}
+	role, err := s.repo.GetUserRole(tx, c.DosenID)
+	if err != nil {
+		return nil, err
+	}
+	if role != "dosen" {
+		return nil, nil
+	}
+
+	semester := SemesterOf(time.Now())
+	budget, err := s.repo.LockBudget(tx, c.DosenID, semester)
+	if err != nil {
+		return nil, err
+	}
+
+	if budget != nil {
+		spent, err := s.repo.SumCharges(tx, c.DosenID, semester)
+		if err != nil {
+			return nil, err
+		}
+		if spent+c.Amount <= budget.Allocated {
+			return nil, s.repo.CreateCharge(tx, newCharge(c, semester, nil))
+		}
+		if budget.OverBudget == "reject" {
+			return nil, &BudgetError{Code: CodeBudgetExceeded, Semester: semester, Allocated: budget.Allocated, Spent: spent, Attempted: c.Amount}
+		}
+	}
+
+	request := &RewardRequest{
+		DosenID:     c.DosenID,
+		Semester:    semester,
+		Kind:        c.Kind,
+		UserID:      c.UserID,
+		Amount:      c.Amount,
+		Description: c.Description,
+		ReferenceID: c.ReferenceID,
+		Status:      "pending",
+	}
+	if err := s.repo.CreateRequest(tx, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// LockPendingRequest finds a reward request that still waits for review and
+// locks it until the transaction ends
+func (s *BudgetService) LockPendingRequest(tx *gorm.DB, requestID uint) (*RewardRequest, error) {
+	request, err := s.repo.LockRequest(tx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != "pending" {
+		return nil, fmt.Errorf("reward request is already %s", request.Status)
+	}
+	return request, nil
+}
+
+// ResolveRequest records the admin's decision on a reward request. An
+// approved reward counts towards the lecturer's spending in the semester it
+// was requested, even beyond the allocation.
+func (s *BudgetService) ResolveRequest(tx *gorm.DB, request *RewardRequest, status string, adminID uint, note string, transactionID *uint) error {
+	now := time.Now()
+	request.Status = status
+	request.ReviewedBy = &adminID
+	request.ReviewNote = note
+	request.ReviewedAt = &now
+	request.TransactionID = transactionID
+
+	if status == "approved" {
+		c := Charge{
+			DosenID:     request.DosenID,
+			UserID:      request.UserID,
+			Kind:        request.Kind,
+			Amount:      request.Amount,
+			Description: request.Description,
+			ReferenceID: request.ReferenceID,
+		}
+		if err := s.repo.CreateCharge(tx, newCharge(c, request.Semester, &request.ID)); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.UpdateRequest(tx, request.ID, map[string]interface{}{
+		"status":         request.Status,
+		"reviewed_by":    request.ReviewedBy,
+		"review_note":    request.ReviewNote,
+		"reviewed_at":    request.ReviewedAt,
+		"transaction_id": request.TransactionID,
+	})
+}
+
+func (s *BudgetService) fillSpent(budget *PointBudget) error {
+	spent, err := s.repo.SumCharges(nil, budget.DosenID, budget.Semester)
+	if err != nil {
+		return err
+	}
+	budget.Spent = spent
+	budget.Remaining = max(budget.Allocated-spent, 0)
+	return nil
+}
+
+func newCharge(c Charge, semester string, requestID *uint) *BudgetCharge {
+	return &BudgetCharge{
+		DosenID:     c.DosenID,
+		Semester:    semester,
+		Kind:        c.Kind,
+		UserID:      c.UserID,
+		Amount:      c.Amount,
+		Description: c.Description,
+		ReferenceID: c.ReferenceID,
+		RequestID:   requestID,
+	}
+}
+
+// SemesterOf returns the semester a time falls in: "2026-1" runs from
+// January to June, "2026-2" from July to December, in the campus time zone
+func SemesterOf(t time.Time) string {
+	t = t.In(utils.Location())
+	half := 1
+	if t.Month() > time.June {
+		half = 2
+	}
+	return fmt.Sprintf("%d-%d", t.Year(), half)
+}
+
+// semesterPeriod returns when a semester starts and ends
+func semesterPeriod(semester string) (time.Time, time.Time, error) {
+	var year, half int
+	if n, err := fmt.Sscanf(semester, "%4d-%1d", &year, &half); err != nil || n != 2 || (half != 1 && half != 2) || len(semester) != 6 {
+		return time.Time{}, time.Time{}, errors.New("invalid semester, use YYYY-1 or YYYY-2")
+	}
+	start := time.Date(year, time.Month(1+(half-1)*6), 1, 0, 0, 0, 0, utils.Location())
+	return start, start.AddDate(0, 6, 0), nil
+}
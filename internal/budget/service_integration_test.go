@@ -0,0 +1,134 @@
+//go:build integration
+
+package budget_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+	"wallet-point/internal/auth"
+	"wallet-point/internal/budget"
+	"wallet-point/internal/database"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Run with TEST_DB_DSN pointing at a development database, see
+// internal/wallet/integration_test.go
+
+func setupBudget(t *testing.T) (*gorm.DB, *auth.AuthService, *budget.BudgetService) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Migrate(db)
+	return db, auth.NewAuthService(auth.NewAuthRepository(db), 1), budget.NewBudgetService(budget.NewBudgetRepository(db))
+}
+
+func registerUser(t *testing.T, authService *auth.AuthService, name, role string) uint {
+	t.Helper()
+	tag := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	user, err := authService.Register(&auth.RegisterRequest{
+		Email:    tag + "@budget.test",
+		Password: "Password123!",
+		FullName: "Budget " + name,
+		NimNip:   tag,
+		Role:     role,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func TestChargeFollowsTheBudget(t *testing.T) {
+	db, authService, service := setupBudget(t)
+	dosen := registerUser(t, authService, "dosen", "dosen")
+	student := registerUser(t, authService, "student", "mahasiswa")
+
+	if _, err := service.AllocateBudget(&budget.AllocateBudgetRequest{DosenID: student, Allocated: 100}, 1); err == nil {
+		t.Error("allocated a budget to a student")
+	}
+	if _, err := service.AllocateBudget(&budget.AllocateBudgetRequest{DosenID: dosen, Allocated: 100, OverBudget: "reject"}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	charge := func(amount int) (*budget.RewardRequest, error) {
+		var request *budget.RewardRequest
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			request, err = service.Charge(tx, budget.Charge{DosenID: dosen, UserID: student, Kind: "reward", Amount: amount, Description: "Budget test"})
+			return err
+		})
+		return request, err
+	}
+
+	if request, err := charge(60); err != nil || request != nil {
+		t.Fatalf("charge within the budget = %v, %v, want it taken from the budget", request, err)
+	}
+	var budgetErr *budget.BudgetError
+	if _, err := charge(50); !errors.As(err, &budgetErr) || budgetErr.Spent != 60 {
+		t.Fatalf("charge beyond a rejecting budget error = %v, want a BudgetError with 60 spent", err)
+	}
+
+	// A queueing budget turns the same charge into a request for review
+	if _, err := service.AllocateBudget(&budget.AllocateBudgetRequest{DosenID: dosen, Allocated: 100, OverBudget: "queue"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	request, err := charge(50)
+	if err != nil || request == nil || request.Status != "pending" {
+		t.Fatalf("charge beyond a queueing budget = %v, %v, want a pending request", request, err)
+	}
+
+	dashboard, err := service.GetDashboard(dosen, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Spent != 60 || dashboard.Remaining != 40 || dashboard.PendingCount != 1 || dashboard.PendingAmount != 50 {
+		t.Errorf("dashboard spent %d, remaining %d, %d pending for %d, want 60, 40 and 1 pending for 50",
+			dashboard.Spent, dashboard.Remaining, dashboard.PendingCount, dashboard.PendingAmount)
+	}
+
+	// An approved request counts even beyond the allocation
+	err = db.Transaction(func(tx *gorm.DB) error {
+		locked, err := service.LockPendingRequest(tx, request.ID)
+		if err != nil {
+			return err
+		}
+		return service.ResolveRequest(tx, locked, "approved", 1, "Approved in test", nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dashboard, err = service.GetDashboard(dosen, ""); err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Spent != 110 || dashboard.Remaining != 0 || dashboard.PendingCount != 0 {
+		t.Errorf("after approval spent %d, remaining %d, %d pending, want 110, 0 and none", dashboard.Spent, dashboard.Remaining, dashboard.PendingCount)
+	}
+}
+
+func TestChargeIgnoresRewardsNotByDosen(t *testing.T) {
+	db, authService, service := setupBudget(t)
+	student := registerUser(t, authService, "giver", "mahasiswa")
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		request, err := service.Charge(tx, budget.Charge{DosenID: student, Kind: "reward", Amount: 10})
+		if request != nil {
+			t.Error("queued a reward that is not budgeted")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
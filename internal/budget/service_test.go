@@ -0,0 +1,53 @@
+package budget
+
+import (
+	"testing"
+	"time"
+	"wallet-point/utils"
+)
+
+func TestSemesterOf(t *testing.T) {
+	utils.InitLocation("Asia/Jakarta")
+	defer utils.InitLocation("Local")
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"first half", time.Date(2026, time.March, 10, 5, 0, 0, 0, time.UTC), "2026-1"},
+		{"second half", time.Date(2026, time.September, 1, 5, 0, 0, 0, time.UTC), "2026-2"},
+		// Already July 1st, 03:00 on campus
+		{"campus midnight", time.Date(2026, time.June, 30, 20, 0, 0, 0, time.UTC), "2026-2"},
+		{"new year", time.Date(2026, time.December, 31, 18, 0, 0, 0, time.UTC), "2027-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SemesterOf(tt.at); got != tt.want {
+				t.Errorf("SemesterOf(%v) = %s, want %s", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSemesterPeriod(t *testing.T) {
+	utils.InitLocation("Asia/Jakarta")
+	defer utils.InitLocation("Local")
+
+	start, end, err := semesterPeriod("2026-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, time.June, 30, 17, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("start = %v, want %v", start, want)
+	}
+	if want := time.Date(2026, time.December, 31, 17, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("end = %v, want %v", end, want)
+	}
+
+	for _, semester := range []string{"2026-3", "2026-0", "26-1", "2026-12", "2026/1"} {
+		if _, _, err := semesterPeriod(semester); err == nil {
+			t.Errorf("semesterPeriod(%q) accepted an invalid semester", semester)
+		}
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"log"
 	"wallet-point/internal/audit"
 	"wallet-point/internal/auth"
+	"wallet-point/internal/budget"
+	"wallet-point/internal/fraud"
+	"wallet-point/internal/idempotency"
+	"wallet-point/internal/limit"
 	"wallet-point/internal/marketplace"
 	"wallet-point/internal/mission"
 	"wallet-point/internal/transfer"
@@ -24,19 +28,65 @@ func Migrate(db *gorm.DB) {
 		&wallet.Wallet{},
 		&wallet.WalletTransaction{},
 		&wallet.PaymentToken{},
+		&wallet.LedgerAccount{},
+		&wallet.LedgerJournal{},
+		&wallet.LedgerLine{},
+		&wallet.PointLot{},
+		&wallet.LotSpend{},
+		&wallet.WalletHold{},
+		&wallet.TokenPayment{},
+		&wallet.RewardBatch{},
+		&wallet.RewardBatchItem{},
 		&marketplace.Product{},
+		&marketplace.ProductCategory{},
+		&marketplace.ProductTag{},
 		&marketplace.MarketplaceTransaction{},
 		&marketplace.CartItem{},
+		&marketplace.MarketplaceOrder{},
+		&marketplace.Listing{},
+		&marketplace.ListingOrder{},
 		&audit.AuditLog{},
 		&mission.Mission{},
 		&mission.MissionQuestion{},
 		&mission.MissionSubmission{},
 		&transfer.Transfer{},
+		&transfer.PaymentRequest{},
+		&transfer.ScheduledTransfer{},
+		&transfer.ScheduledTransferRun{},
+		&transfer.TransferQuote{},
+		&idempotency.IdempotencyKey{},
+		&limit.LimitPolicy{},
+		&budget.PointBudget{},
+		&budget.BudgetCharge{},
+		&budget.RewardRequest{},
+		&fraud.FraudRule{},
+		&fraud.FraudCheck{},
 	)
 
 	if err != nil {
 		log.Fatal("❌ Migration failed:", err)
 	}
 
+	syncEnumColumns(db)
+
 	log.Println("✅ Database migration/sync completed")
 }
+
+// syncEnumColumns widens enum columns that gained values after the tables were
+// created. AutoMigrate does not alter an enum whose existing values still match.
+func syncEnumColumns(db *gorm.DB) {
+	columns := []struct {
+		model interface{}
+		field string
+	}{
+		{&wallet.WalletTransaction{}, "Type"},
+		{&wallet.PaymentToken{}, "Status"},
+		{&marketplace.MarketplaceTransaction{}, "Status"},
+	}
+
+	for _, col := range columns {
+		if err := db.Migrator().AlterColumn(col.model, col.field); err != nil {
+			log.Fatalf("❌ Failed to update enum column %s: %v", col.field, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package fraud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"wallet-point/internal/audit"
+	"wallet-point/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FraudHandler struct {
+	service      *FraudService
+	auditService *audit.AuditService
+}
+
+func NewFraudHandler(service *FraudService, auditService *audit.AuditService) *FraudHandler {
+	return &FraudHandler{service: service, auditService: auditService}
+}
+
+// RespondIfFraudError writes a 422 response carrying the fraud check if err
+// was caused by the fraud rules. It reports whether a response was written.
+func RespondIfFraudError(c *gin.Context, err error) bool {
+	var fraudErr *FraudError
+	if !errors.As(err, &fraudErr) {
+		return false
+	}
+	utils.ErrorResponse(c, http.StatusUnprocessableEntity, fraudErr.Error(), fraudErr)
+	return true
+}
+
+// GetRules handles listing fraud rules
+// @Summary Get fraud rules
+// @Description List the fraud and velocity rules run on transfers and QR payments with their settings (Admin only)
+// @Tags Admin - Fraud
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]FraudRule}
+// @Router /admin/fraud/rules [get]
+func (h *FraudHandler) GetRules(c *gin.Context) {
+	rules, err := h.service.GetRules()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve fraud rules", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Fraud rules retrieved successfully", rules)
+}
+
+// UpdateRule handles changing a fraud rule
+// @Summary Update fraud rule
+// @Description Enable or disable a rule, choose whether it flags or blocks and set its threshold and window (Admin only)
+// @Tags Admin - Fraud
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param code path string true "Rule code"
+// @Param request body UpdateRuleRequest true "Rule settings"
+// @Success 200 {object} utils.Response{data=FraudRule}
+// @Failure 404 {object} utils.Response
+// @Router /admin/fraud/rules/{code} [put]
+func (h *FraudHandler) UpdateRule(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req UpdateRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	rule, err := h.service.UpdateRule(c.Param("code"), &req, adminID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "fraud rule not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Fraud rule updated successfully", rule)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "UPDATE_FRAUD_RULE",
+		Entity:    "FRAUD_RULE",
+		EntityID:  rule.ID,
+		Details:   fmt.Sprintf("Admin set fraud rule %s to %s at threshold %d within %d minutes (enabled: %t)", rule.Code, rule.Action, rule.Threshold, rule.WindowMinutes, rule.Enabled),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetReviewQueue handles listing flagged transfers and payments
+// @Summary Get fraud review queue
+// @Description List the transfers and payments flagged by the fraud rules (Admin only)
+// @Tags Admin - Fraud
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Review status (pending, cleared, confirmed)" default(pending)
+// @Success 200 {object} utils.Response{data=[]FraudCheckWithUsers}
+// @Router /admin/fraud/reviews [get]
+func (h *FraudHandler) GetReviewQueue(c *gin.Context) {
+	checks, err := h.service.GetChecks(c.DefaultQuery("status", "pending"), "flag", 0)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve fraud reviews", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Fraud reviews retrieved successfully", checks)
+}
+
+// GetChecks handles listing fraud decisions
+// @Summary Get fraud checks
+// @Description List the latest fraud decisions, optionally for one decision or user (Admin only)
+// @Tags Admin - Fraud
+// @Security BearerAuth
+// @Produce json
+// @Param decision query string false "Filter by decision (allow, flag, block)"
+// @Param user_id query int false "Filter by sender or receiver"
+// @Success 200 {object} utils.Response{data=[]FraudCheckWithUsers}
+// @Router /admin/fraud/checks [get]
+func (h *FraudHandler) GetChecks(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Query("user_id"), 10, 32)
+
+	checks, err := h.service.GetChecks("", c.Query("decision"), uint(userID))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve fraud checks", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Fraud checks retrieved successfully", checks)
+}
+
+// ReviewCheck handles resolving a flagged transfer or payment
+// @Summary Review fraud check
+// @Description Mark a flagged transfer or payment as cleared or as confirmed fraud (Admin only)
+// @Tags Admin - Fraud
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Fraud check ID"
+// @Param request body ReviewCheckRequest true "Review"
+// @Success 200 {object} utils.Response{data=FraudCheck}
+// @Failure 404 {object} utils.Response
+// @Router /admin/fraud/reviews/{id}/review [post]
+func (h *FraudHandler) ReviewCheck(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	checkID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid fraud check ID", nil)
+		return
+	}
+
+	var req ReviewCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	check, err := h.service.ReviewCheck(uint(checkID), &req, adminID)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "fraud check not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Fraud check reviewed successfully", check)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "REVIEW_FRAUD_CHECK",
+		Entity:    "FRAUD_CHECK",
+		EntityID:  check.ID,
+		Details:   fmt.Sprintf("Admin marked fraud check %d (%s) as %s", check.ID, check.Rules, check.ReviewStatus),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
@@ -0,0 +1,118 @@
+package fraud
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CodeFraudBlocked is returned when the fraud rules block a transfer or payment
+const CodeFraudBlocked = "FRAUD_BLOCKED"
+
+// Rule codes
+const (
+	RuleVelocity   = "VELOCITY"    // Transfers and payments a sender made within the window
+	RuleFanOut     = "FAN_OUT"     // Distinct users a sender paid within the window
+	RuleFanIn      = "FAN_IN"      // Distinct students paying one student within the window
+	RuleRoundTrip  = "ROUND_TRIP"  // Points returning to the sender directly or through one other user
+	RuleNewAccount = "NEW_ACCOUNT" // Larger amounts to or from an account younger than the window
+)
+
+// FraudRule tunes one of the built-in rules. Rules without a stored row use
+// their defaults.
+type FraudRule struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	Code          string    `json:"code" gorm:"size:30;uniqueIndex;not null"`
+	Enabled       bool      `json:"enabled" gorm:"not null"`
+	Action        string    `json:"action" gorm:"type:enum('flag','block');default:'flag';not null"`
+	Threshold     int       `json:"threshold" gorm:"not null"`
+	WindowMinutes int       `json:"window_minutes" gorm:"not null"`
+	UpdatedBy     uint      `json:"updated_by"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Virtual fields for response
+	Description string `json:"description" gorm:"-"`
+}
+
+func (FraudRule) TableName() string {
+	return "fraud_rules"
+}
+
+type UpdateRuleRequest struct {
+	Enabled       *bool  `json:"enabled" binding:"required"`
+	Action        string `json:"action" binding:"required,oneof=flag block"`
+	Threshold     int    `json:"threshold" binding:"required,gt=0"`
+	WindowMinutes int    `json:"window_minutes" binding:"required,gt=0"`
+}
+
+// FraudCheck is the decision taken on one transfer or QR payment. Checks
+// that were let through also form the graph the rules look back on, and
+// flagged ones wait in the review queue until an admin resolves them.
+type FraudCheck struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Kind         string     `json:"kind" gorm:"type:enum('transfer','qr_payment');not null"`
+	SenderID     uint       `json:"sender_id" gorm:"not null;index:idx_fraud_check_sender"`
+	ReceiverID   uint       `json:"receiver_id" gorm:"not null;index:idx_fraud_check_receiver"`
+	Amount       int        `json:"amount" gorm:"not null"`
+	ReferenceID  *uint      `json:"reference_id"` // Transfer or payment token, unset for blocked checks
+	Decision     string     `json:"decision" gorm:"type:enum('allow','flag','block');not null;index"`
+	Rules        string     `json:"rules" gorm:"size:255"` // Codes of the rules that fired
+	Reasons      string     `json:"reasons" gorm:"type:text"`
+	ReviewStatus string     `json:"review_status" gorm:"type:enum('none','pending','cleared','confirmed');default:'none';index"`
+	ReviewedBy   *uint      `json:"reviewed_by"`
+	ReviewNote   string     `json:"review_note" gorm:"size:255"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"index:idx_fraud_check_sender;index:idx_fraud_check_receiver"`
+}
+
+func (FraudCheck) TableName() string {
+	return "fraud_checks"
+}
+
+type FraudCheckWithUsers struct {
+	FraudCheck
+	SenderName   string `json:"sender_name"`
+	SenderNIM    string `json:"sender_nim"`
+	ReceiverName string `json:"receiver_name"`
+	ReceiverNIM  string `json:"receiver_nim"`
+}
+
+// Activity is a transfer or payment about to be made
+type Activity struct {
+	Kind        string
+	SenderID    uint
+	ReceiverID  uint
+	Amount      int
+	ReferenceID *uint
+}
+
+// RuleHit is a rule that fired with what it observed
+type RuleHit struct {
+	Code      string `json:"code"`
+	Action    string `json:"action"`
+	Observed  int    `json:"observed"`
+	Threshold int    `json:"threshold"`
+	Reason    string `json:"reason"`
+}
+
+type ReviewCheckRequest struct {
+	Status string `json:"status" binding:"required,oneof=cleared confirmed"`
+	Note   string `json:"note" binding:"max=255"`
+}
+
+// FraudError reports that the rules blocked an operation
+type FraudError struct {
+	Code    string    `json:"code"`
+	CheckID uint      `json:"check_id"`
+	Hits    []RuleHit `json:"rules"`
+}
+
+func (e *FraudError) Error() string {
+	var codes []string
+	for _, hit := range e.Hits {
+		if hit.Action == "block" {
+			codes = append(codes, hit.Code)
+		}
+	}
+	return fmt.Sprintf("blocked by fraud rules: %s", strings.Join(codes, ", "))
+}
@@ -0,0 +1,158 @@
+package fraud
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FraudRepository struct {
+	db *gorm.DB
+}
+
+func NewFraudRepository(db *gorm.DB) *FraudRepository {
+	return &FraudRepository{db: db}
+}
+
+// GetRules returns the stored rule settings
+func (r *FraudRepository) GetRules(tx *gorm.DB) ([]FraudRule, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var rules []FraudRule
+	err := tx.Find(&rules).Error
+	return rules, err
+}
+
+// SaveRule stores the settings of a rule, replacing earlier ones
+func (r *FraudRepository) SaveRule(rule *FraudRule) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "action", "threshold", "window_minutes", "updated_by", "updated_at"}),
+	}).Create(rule).Error
+}
+
+// CreateCheck stores a decision. Pass nil to store it outside any transaction.
+func (r *FraudRepository) CreateCheck(tx *gorm.DB, check *FraudCheck) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(check).Error
+}
+
+// FindCheck finds a fraud check by ID
+func (r *FraudRepository) FindCheck(id uint) (*FraudCheck, error) {
+	var check FraudCheck
+	err := r.db.First(&check, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("fraud check not found")
+		}
+		return nil, err
+	}
+	return &check, nil
+}
+
+// UpdateCheck updates the review of a fraud check still pending review. It
+// reports whether the check was updated.
+func (r *FraudRepository) UpdateCheck(id uint, updates map[string]interface{}) (bool, error) {
+	result := r.db.Model(&FraudCheck{}).Where("id = ? AND review_status = ?", id, "pending").Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetChecks lists fraud checks with both users, newest first
+func (r *FraudRepository) GetChecks(reviewStatus, decision string, userID uint, limit int) ([]FraudCheckWithUsers, error) {
+	var checks []FraudCheckWithUsers
+	query := r.db.Table("fraud_checks").
+		Select("fraud_checks.*, sender.full_name as sender_name, sender.nim_nip as sender_nim, receiver.full_name as receiver_name, receiver.nim_nip as receiver_nim").
+		Joins("LEFT JOIN users sender ON sender.id = fraud_checks.sender_id").
+		Joins("LEFT JOIN users receiver ON receiver.id = fraud_checks.receiver_id")
+
+	if reviewStatus != "" {
+		query = query.Where("fraud_checks.review_status = ?", reviewStatus)
+	}
+	if decision != "" {
+		query = query.Where("fraud_checks.decision = ?", decision)
+	}
+	if userID != 0 {
+		query = query.Where("(fraud_checks.sender_id = ? OR fraud_checks.receiver_id = ?)", userID, userID)
+	}
+
+	err := query.Order("fraud_checks.created_at DESC, fraud_checks.id DESC").Limit(limit).Scan(&checks).Error
+	return checks, err
+}
+
+// CountSent counts what a user sent since a given time that was let through
+func (r *FraudRepository) CountSent(tx *gorm.DB, senderID uint, since time.Time) (int, error) {
+	var count int64
+	err := r.passed(tx).Where("sender_id = ? AND created_at >= ?", senderID, since).Count(&count).Error
+	return int(count), err
+}
+
+// CountReceivers counts the distinct users a sender paid since a given time
+func (r *FraudRepository) CountReceivers(tx *gorm.DB, senderID uint, since time.Time, exclude uint) (int, error) {
+	var count int64
+	err := r.passed(tx).Where("sender_id = ? AND created_at >= ? AND receiver_id <> ?", senderID, since, exclude).
+		Distinct("receiver_id").Count(&count).Error
+	return int(count), err
+}
+
+// CountSenders counts the distinct users who paid a receiver since a given time
+func (r *FraudRepository) CountSenders(tx *gorm.DB, receiverID uint, since time.Time, exclude uint) (int, error) {
+	var count int64
+	err := r.passed(tx).Where("receiver_id = ? AND created_at >= ? AND sender_id <> ?", receiverID, since, exclude).
+		Distinct("sender_id").Count(&count).Error
+	return int(count), err
+}
+
+// CountReturnPaths counts the ways points went from `from` back to `to`
+// since a given time, directly or through one other user
+func (r *FraudRepository) CountReturnPaths(tx *gorm.DB, from, to uint, since time.Time) (int, error) {
+	var direct int64
+	err := r.passed(tx).Where("sender_id = ? AND receiver_id = ? AND created_at >= ?", from, to, since).Count(&direct).Error
+	if err != nil {
+		return 0, err
+	}
+
+	if tx == nil {
+		tx = r.db
+	}
+	var viaOther int64
+	err = tx.Table("fraud_checks hop1").
+		Joins("INNER JOIN fraud_checks hop2 ON hop2.sender_id = hop1.receiver_id").
+		Where("hop1.sender_id = ? AND hop2.receiver_id = ? AND hop1.receiver_id NOT IN ?", from, to, []uint{from, to}).
+		Where("hop1.decision <> ? AND hop2.decision <> ?", "block", "block").
+		Where("hop1.created_at >= ? AND hop2.created_at >= hop1.created_at", since).
+		Count(&viaOther).Error
+	return int(direct + viaOther), err
+}
+
+// GetUserInfo returns the role and sign-up time of a user
+func (r *FraudRepository) GetUserInfo(tx *gorm.DB, userID uint) (string, time.Time, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var user struct {
+		Role      string
+		CreatedAt time.Time
+	}
+	err := tx.Table("users").Where("id = ?", userID).Select("role, created_at").Scan(&user).Error
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if user.Role == "" {
+		return "", time.Time{}, errors.New("user not found")
+	}
+	return user.Role, user.CreatedAt, nil
+}
+
+// passed selects the checks that were not blocked, that is the transfers and
+// payments that actually went through
+func (r *FraudRepository) passed(tx *gorm.DB) *gorm.DB {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&FraudCheck{}).Where("decision <> ?", "block")
+}
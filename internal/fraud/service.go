@@ -0,0 +1,293 @@
+package fraud
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"wallet-point/internal/audit"
+
+	"gorm.io/gorm"
+)
+
+type FraudService struct {
+	repo         *FraudRepository
+	auditService *audit.AuditService
+}
+
+func NewFraudService(repo *FraudRepository, auditService *audit.AuditService) *FraudService {
+	return &FraudService{repo: repo, auditService: auditService}
+}
+
+// defaultRules are the settings of every rule until an admin changes them.
+// The threshold of a rule is a count, except for NEW_ACCOUNT where it is an
+// amount of points.
+var defaultRules = []FraudRule{
+	{Code: RuleVelocity, Enabled: true, Action: "block", Threshold: 20, WindowMinutes: 60,
+		Description: "A sender makes more transfers and payments than the threshold within the window"},
+	{Code: RuleFanOut, Enabled: true, Action: "flag", Threshold: 10, WindowMinutes: 24 * 60,
+		Description: "A sender pays more different users than the threshold within the window"},
+	{Code: RuleFanIn, Enabled: true, Action: "flag", Threshold: 10, WindowMinutes: 24 * 60,
+		Description: "A student is paid by more different users than the threshold within the window"},
+	{Code: RuleRoundTrip, Enabled: true, Action: "flag", Threshold: 1, WindowMinutes: 7 * 24 * 60,
+		Description: "At least threshold earlier transfers lead from the receiver back to the sender within the window, directly or through one other user"},
+	{Code: RuleNewAccount, Enabled: true, Action: "flag", Threshold: 100, WindowMinutes: 7 * 24 * 60,
+		Description: "At least threshold points move to or from an account created within the window"},
+}
+
+// GetRules lists every rule with its current settings
+func (s *FraudService) GetRules() ([]FraudRule, error) {
+	return s.rules(nil)
+}
+
+// UpdateRule changes the settings of a rule
+func (s *FraudService) UpdateRule(code string, req *UpdateRuleRequest, adminID uint) (*FraudRule, error) {
+	code = strings.ToUpper(code)
+	if findRule(defaultRules, code) == nil {
+		return nil, errors.New("fraud rule not found")
+	}
+
+	rule := &FraudRule{
+		Code:          code,
+		Enabled:       *req.Enabled,
+		Action:        req.Action,
+		Threshold:     req.Threshold,
+		WindowMinutes: req.WindowMinutes,
+		UpdatedBy:     adminID,
+		UpdatedAt:     time.Now(),
+	}
+	if err := s.repo.SaveRule(rule); err != nil {
+		return nil, err
+	}
+
+	rules, err := s.rules(nil)
+	if err != nil {
+		return nil, err
+	}
+	return findRule(rules, code), nil
+}
+
+// Check runs the rules on a transfer or payment inside the transaction that
+// makes it. Allowed and flagged activity is stored and audited in tx, so both
+// are undone with a failed payment; flagged checks enter the review queue. A
+// block is stored and audited on its own and returned as a FraudError: the
+// caller must roll back.
+func (s *FraudService) Check(tx *gorm.DB, a Activity) (*FraudCheck, error) {
+	rules, err := s.rules(tx)
+	if err != nil {
+		return nil, err
+	}
+	hits, err := s.evaluate(tx, a, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &FraudCheck{
+		Kind:         a.Kind,
+		SenderID:     a.SenderID,
+		ReceiverID:   a.ReceiverID,
+		Amount:       a.Amount,
+		ReferenceID:  a.ReferenceID,
+		Decision:     decide(hits),
+		ReviewStatus: "none",
+	}
+	var codes, reasons []string
+	for _, hit := range hits {
+		codes = append(codes, hit.Code)
+		reasons = append(reasons, hit.Code+": "+hit.Reason)
+	}
+	check.Rules = strings.Join(codes, ",")
+	check.Reasons = strings.Join(reasons, "; ")
+
+	switch check.Decision {
+	case "block":
+		// The activity never happens, so there is nothing to reference and the
+		// check must outlive the rolled back transaction
+		check.ReferenceID = nil
+		if err := s.repo.CreateCheck(nil, check); err != nil {
+			return nil, err
+		}
+		s.logDecision(nil, check)
+		return check, &FraudError{Code: CodeFraudBlocked, CheckID: check.ID, Hits: hits}
+	case "flag":
+		check.ReviewStatus = "pending"
+	}
+
+	if err := s.repo.CreateCheck(tx, check); err != nil {
+		return nil, err
+	}
+	if err := s.logDecision(tx, check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// decide turns the rules an activity tripped into a decision, the strictest
+// action of any of them
+func decide(hits []RuleHit) string {
+	decision := "allow"
+	for _, hit := range hits {
+		if hit.Action == "block" {
+			return "block"
+		}
+		decision = "flag"
+	}
+	return decision
+}
+
+// evaluate returns the enabled rules the activity trips
+func (s *FraudService) evaluate(tx *gorm.DB, a Activity, rules []FraudRule) ([]RuleHit, error) {
+	receiverRole, receiverSince, err := s.repo.GetUserInfo(tx, a.ReceiverID)
+	if err != nil {
+		return nil, err
+	}
+	_, senderSince, err := s.repo.GetUserInfo(tx, a.SenderID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var hits []RuleHit
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		since := now.Add(-time.Duration(rule.WindowMinutes) * time.Minute)
+
+		var observed int
+		var fired bool
+		var reason string
+		switch rule.Code {
+		case RuleVelocity:
+			n, err := s.repo.CountSent(tx, a.SenderID, since)
+			if err != nil {
+				return nil, err
+			}
+			observed = n + 1
+			fired = observed > rule.Threshold
+			reason = fmt.Sprintf("%d transfers and payments within %d minutes", observed, rule.WindowMinutes)
+		case RuleFanOut:
+			n, err := s.repo.CountReceivers(tx, a.SenderID, since, a.ReceiverID)
+			if err != nil {
+				return nil, err
+			}
+			observed = n + 1
+			fired = observed > rule.Threshold
+			reason = fmt.Sprintf("sender paid %d different users within %d minutes", observed, rule.WindowMinutes)
+		case RuleFanIn:
+			// Merchants and staff are paid by many students by design
+			if receiverRole != "mahasiswa" {
+				continue
+			}
+			n, err := s.repo.CountSenders(tx, a.ReceiverID, since, a.SenderID)
+			if err != nil {
+				return nil, err
+			}
+			observed = n + 1
+			fired = observed > rule.Threshold
+			reason = fmt.Sprintf("receiver was paid by %d different users within %d minutes", observed, rule.WindowMinutes)
+		case RuleRoundTrip:
+			n, err := s.repo.CountReturnPaths(tx, a.ReceiverID, a.SenderID, since)
+			if err != nil {
+				return nil, err
+			}
+			observed = n
+			fired = observed >= rule.Threshold
+			reason = fmt.Sprintf("%d earlier transfers lead from the receiver back to the sender within %d minutes", observed, rule.WindowMinutes)
+		case RuleNewAccount:
+			observed = a.Amount
+			fired = a.Amount >= rule.Threshold && (senderSince.After(since) || receiverSince.After(since))
+			reason = fmt.Sprintf("%d points to or from an account younger than %d minutes", observed, rule.WindowMinutes)
+		}
+
+		if fired {
+			hits = append(hits, RuleHit{
+				Code:      rule.Code,
+				Action:    rule.Action,
+				Observed:  observed,
+				Threshold: rule.Threshold,
+				Reason:    reason,
+			})
+		}
+	}
+	return hits, nil
+}
+
+// GetChecks lists fraud checks by review status, decision and user
+func (s *FraudService) GetChecks(reviewStatus, decision string, userID uint) ([]FraudCheckWithUsers, error) {
+	return s.repo.GetChecks(reviewStatus, decision, userID, 200)
+}
+
+// ReviewCheck resolves a flagged check as cleared or confirmed fraud
+func (s *FraudService) ReviewCheck(id uint, req *ReviewCheckRequest, adminID uint) (*FraudCheck, error) {
+	check, err := s.repo.FindCheck(id)
+	if err != nil {
+		return nil, err
+	}
+	if check.ReviewStatus != "pending" {
+		return nil, errors.New("fraud check is not waiting for review")
+	}
+
+	now := time.Now()
+	updated, err := s.repo.UpdateCheck(id, map[string]interface{}{
+		"review_status": req.Status,
+		"reviewed_by":   adminID,
+		"review_note":   req.Note,
+		"reviewed_at":   now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.New("fraud check is not waiting for review")
+	}
+
+	check.ReviewStatus = req.Status
+	check.ReviewedBy = &adminID
+	check.ReviewNote = req.Note
+	check.ReviewedAt = &now
+	return check, nil
+}
+
+// rules merges the stored settings into the default rules
+func (s *FraudService) rules(tx *gorm.DB) ([]FraudRule, error) {
+	stored, err := s.repo.GetRules(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]FraudRule, len(defaultRules))
+	copy(rules, defaultRules)
+	for i := range rules {
+		if saved := findRule(stored, rules[i].Code); saved != nil {
+			description := rules[i].Description
+			rules[i] = *saved
+			rules[i].Description = description
+		}
+	}
+	return rules, nil
+}
+
+func (s *FraudService) logDecision(tx *gorm.DB, check *FraudCheck) error {
+	details := fmt.Sprintf("%s of %d points from user %d to user %d: %s", strings.ReplaceAll(check.Kind, "_", " "), check.Amount, check.SenderID, check.ReceiverID, check.Decision)
+	if check.Reasons != "" {
+		details += " (" + check.Reasons + ")"
+	}
+
+	return s.auditService.LogActivityWithTx(tx, audit.CreateAuditParams{
+		UserID:   check.SenderID,
+		Action:   "FRAUD_" + strings.ToUpper(check.Decision),
+		Entity:   "FRAUD_CHECK",
+		EntityID: check.ID,
+		Details:  details,
+	})
+}
+
+func findRule(rules []FraudRule, code string) *FraudRule {
+	for i := range rules {
+		if rules[i].Code == code {
+			return &rules[i]
+		}
+	}
+	return nil
+}
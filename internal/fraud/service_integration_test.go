@@ -0,0 +1,156 @@
+//go:build integration
+
+package fraud_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+	"wallet-point/internal/audit"
+	"wallet-point/internal/auth"
+	"wallet-point/internal/database"
+	"wallet-point/internal/fraud"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Run with TEST_DB_DSN pointing at a development database, see
+// internal/wallet/integration_test.go
+
+var errRollback = errors.New("roll back")
+
+func setupFraud(t *testing.T) (*gorm.DB, *auth.AuthService, *fraud.FraudService) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Migrate(db)
+
+	auditService := audit.NewAuditService(audit.NewAuditRepository(db))
+	return db, auth.NewAuthService(auth.NewAuthRepository(db), 1), fraud.NewFraudService(fraud.NewFraudRepository(db), auditService)
+}
+
+func registerStudent(t *testing.T, authService *auth.AuthService, name string) uint {
+	t.Helper()
+	tag := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	user, err := authService.Register(&auth.RegisterRequest{
+		Email:    tag + "@fraud.test",
+		Password: "Password123!",
+		FullName: "Fraud " + name,
+		NimNip:   tag,
+		Role:     "mahasiswa",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func TestCheckFlagsNewAccountsAndRollsBackWithThePayment(t *testing.T) {
+	db, authService, service := setupFraud(t)
+	sender := registerStudent(t, authService, "sender")
+	receiver := registerStudent(t, authService, "receiver")
+
+	var check *fraud.FraudCheck
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		check, err = service.Check(tx, fraud.Activity{Kind: "transfer", SenderID: sender, ReceiverID: receiver, Amount: 150})
+		if err != nil {
+			return err
+		}
+		// The payment fails after the check, e.g. on the balance
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if check.Decision != "flag" || check.Rules != fraud.RuleNewAccount {
+		t.Errorf("Check() = %s by %q, want flag by %s", check.Decision, check.Rules, fraud.RuleNewAccount)
+	}
+
+	var checks, logs int64
+	db.Model(&fraud.FraudCheck{}).Where("id = ?", check.ID).Count(&checks)
+	db.Model(&audit.AuditLog{}).Where("entity = ? AND entity_id = ?", "FRAUD_CHECK", check.ID).Count(&logs)
+	if checks != 0 || logs != 0 {
+		t.Errorf("rolled back check left %d checks and %d audit entries, want none", checks, logs)
+	}
+}
+
+func TestCheckFlagsRoundTrips(t *testing.T) {
+	db, authService, service := setupFraud(t)
+	a := registerStudent(t, authService, "ping")
+	b := registerStudent(t, authService, "pong")
+
+	first, err := service.Check(db, fraud.Activity{Kind: "transfer", SenderID: a, ReceiverID: b, Amount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Decision != "allow" {
+		t.Errorf("first transfer %s by %q, want allow", first.Decision, first.Rules)
+	}
+
+	back, err := service.Check(db, fraud.Activity{Kind: "transfer", SenderID: b, ReceiverID: a, Amount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Decision != "flag" || back.Rules != fraud.RuleRoundTrip {
+		t.Errorf("transfer back %s by %q, want flag by %s", back.Decision, back.Rules, fraud.RuleRoundTrip)
+	}
+	if back.ReviewStatus != "pending" {
+		t.Errorf("flagged check review status %s, want pending", back.ReviewStatus)
+	}
+
+	var logs int64
+	db.Model(&audit.AuditLog{}).Where("action = ? AND entity_id = ?", "FRAUD_FLAG", back.ID).Count(&logs)
+	if logs != 1 {
+		t.Errorf("%d audit entries for the flagged check, want 1", logs)
+	}
+}
+
+func TestCheckBlocksVelocityAndKeepsTheBlock(t *testing.T) {
+	db, authService, service := setupFraud(t)
+	sender := registerStudent(t, authService, "fast")
+	receiver := registerStudent(t, authService, "shop")
+
+	activity := fraud.Activity{Kind: "qr_payment", SenderID: sender, ReceiverID: receiver, Amount: 5}
+	for i := 0; i < 20; i++ {
+		check, err := service.Check(db, activity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if check.Decision != "allow" {
+			t.Fatalf("payment %d %s by %q, want allow", i+1, check.Decision, check.Rules)
+		}
+	}
+
+	var check *fraud.FraudCheck
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		check, err = service.Check(tx, activity)
+		return err
+	})
+	var fraudErr *fraud.FraudError
+	if !errors.As(err, &fraudErr) || fraudErr.Code != fraud.CodeFraudBlocked {
+		t.Fatalf("21st payment error = %v, want a FraudError", err)
+	}
+	if check.Rules != fraud.RuleVelocity {
+		t.Errorf("blocked by %q, want %s", check.Rules, fraud.RuleVelocity)
+	}
+
+	// The payment rolled back, the block is kept for review
+	var checks, logs int64
+	db.Model(&fraud.FraudCheck{}).Where("id = ? AND decision = ?", fraudErr.CheckID, "block").Count(&checks)
+	db.Model(&audit.AuditLog{}).Where("action = ? AND entity_id = ?", "FRAUD_BLOCK", fraudErr.CheckID).Count(&logs)
+	if checks != 1 || logs != 1 {
+		t.Errorf("block left %d checks and %d audit entries, want 1 and 1", checks, logs)
+	}
+}
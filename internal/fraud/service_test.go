@@ -0,0 +1,26 @@
+package fraud
+
+import "testing"
+
+func TestDecide(t *testing.T) {
+	flag := RuleHit{Code: RuleFanOut, Action: "flag"}
+	block := RuleHit{Code: RuleVelocity, Action: "block"}
+
+	tests := []struct {
+		name string
+		hits []RuleHit
+		want string
+	}{
+		{"no rule tripped", nil, "allow"},
+		{"flagging rule", []RuleHit{flag}, "flag"},
+		{"blocking rule", []RuleHit{block}, "block"},
+		{"block wins over flag", []RuleHit{flag, block}, "block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decide(tt.hits); got != tt.want {
+				t.Errorf("decide() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package idempotency
+
+import (
+	"time"
+)
+
+// IdempotencyKey stores the outcome of a money-moving request so that a
+// retried request with the same key replays it instead of running it again.
+type IdempotencyKey struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `json:"key" gorm:"type:varchar(191);not null;uniqueIndex:idx_idempotency_user_key"`
+	Method       string    `json:"method" gorm:"size:10"`
+	Path         string    `json:"path" gorm:"size:255"`
+	RequestHash  string    `json:"request_hash" gorm:"size:64"`
+	Status       string    `json:"status" gorm:"type:enum('processing','completed');default:'processing'"`
+	ResponseCode int       `json:"response_code"`
+	ResponseBody string    `json:"response_body" gorm:"type:mediumtext"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index;not null"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
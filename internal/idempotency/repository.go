@@ -0,0 +1,54 @@
+package idempotency
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Find finds a stored key for a user, returning nil if it does not exist
+func (r *IdempotencyRepository) Find(userID uint, key string) (*IdempotencyKey, error) {
+	var record IdempotencyKey
+	err := r.db.Where("user_id = ? AND `key` = ?", userID, key).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Create reserves a key, failing if another request already holds it
+func (r *IdempotencyRepository) Create(record *IdempotencyKey) error {
+	return r.db.Create(record).Error
+}
+
+// Complete stores the response of a finished request
+func (r *IdempotencyRepository) Complete(id uint, code int, body string) error {
+	return r.db.Model(&IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        "completed",
+		"response_code": code,
+		"response_body": body,
+	}).Error
+}
+
+// Delete releases a key so the request can be retried
+func (r *IdempotencyRepository) Delete(id uint) error {
+	return r.db.Delete(&IdempotencyKey{}, id).Error
+}
+
+// DeleteExpired removes keys past their retention window
+func (r *IdempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", now).Delete(&IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
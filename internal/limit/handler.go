@@ -0,0 +1,236 @@
+package limit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"wallet-point/internal/audit"
+	"wallet-point/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LimitHandler struct {
+	service      *LimitService
+	auditService *audit.AuditService
+}
+
+func NewLimitHandler(service *LimitService, auditService *audit.AuditService) *LimitHandler {
+	return &LimitHandler{service: service, auditService: auditService}
+}
+
+// RespondIfLimitError writes a 422 response carrying the limit error code if
+// err was caused by a limit. It reports whether a response was written.
+func RespondIfLimitError(c *gin.Context, err error) bool {
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	utils.ErrorResponse(c, http.StatusUnprocessableEntity, limitErr.Error(), limitErr)
+	return true
+}
+
+// GetPolicies handles listing limit policies
+// @Summary Get limit policies
+// @Description List the spending and transfer limit policies of every role and user (Admin only)
+// @Tags Admin - Limits
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]LimitPolicy}
+// @Router /admin/limits [get]
+func (h *LimitHandler) GetPolicies(c *gin.Context) {
+	policies, err := h.service.GetPolicies()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve limit policies", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Limit policies retrieved successfully", policies)
+}
+
+// CreatePolicy handles creating a limit policy
+// @Summary Create limit policy
+// @Description Set limits for a role or a single user. Omitted limits are not enforced (Admin only)
+// @Tags Admin - Limits
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body LimitPolicyRequest true "Policy details"
+// @Success 201 {object} utils.Response{data=LimitPolicy}
+// @Failure 400 {object} utils.Response
+// @Router /admin/limits [post]
+func (h *LimitHandler) CreatePolicy(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req LimitPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	policy, err := h.service.CreatePolicy(&req, adminID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Limit policy created successfully", policy)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "CREATE_LIMIT_POLICY",
+		Entity:    "LIMIT_POLICY",
+		EntityID:  policy.ID,
+		Details:   "Admin created limit policy for " + describeSubject(policy),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// UpdatePolicy handles updating a limit policy
+// @Summary Update limit policy
+// @Description Replace the limits of a policy. Omitted limits are cleared (Admin only)
+// @Tags Admin - Limits
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Policy ID"
+// @Param request body LimitPolicyRequest true "Policy details"
+// @Success 200 {object} utils.Response{data=LimitPolicy}
+// @Failure 404 {object} utils.Response
+// @Router /admin/limits/{id} [put]
+func (h *LimitHandler) UpdatePolicy(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	policyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid policy ID", nil)
+		return
+	}
+
+	var req LimitPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	policy, err := h.service.UpdatePolicy(uint(policyID), &req)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "limit policy not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Limit policy updated successfully", policy)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "UPDATE_LIMIT_POLICY",
+		Entity:    "LIMIT_POLICY",
+		EntityID:  policy.ID,
+		Details:   "Admin updated limit policy for " + describeSubject(policy),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// DeletePolicy handles deleting a limit policy
+// @Summary Delete limit policy
+// @Description Remove a limit policy (Admin only)
+// @Tags Admin - Limits
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Policy ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /admin/limits/{id} [delete]
+func (h *LimitHandler) DeletePolicy(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	policyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid policy ID", nil)
+		return
+	}
+
+	if err := h.service.DeletePolicy(uint(policyID)); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "limit policy not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Limit policy deleted successfully", nil)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "DELETE_LIMIT_POLICY",
+		Entity:    "LIMIT_POLICY",
+		EntityID:  uint(policyID),
+		Details:   "Admin deleted limit policy",
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetUserLimits handles getting the effective limits of a user
+// @Summary Get user limits
+// @Description Get the limits that apply to a user and how much of them is used (Admin only)
+// @Tags Admin - Limits
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.Response{data=UserLimits}
+// @Failure 404 {object} utils.Response
+// @Router /admin/limits/users/{id} [get]
+func (h *LimitHandler) GetUserLimits(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", nil)
+		return
+	}
+
+	limits, err := h.service.GetUserLimits(uint(userID))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User limits retrieved successfully", limits)
+}
+
+// GetMyLimits handles getting the current user's limits
+// @Summary Get my limits
+// @Description Get the spending and transfer limits of the current user and how much of them is used
+// @Tags Wallet
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=UserLimits}
+// @Router /mahasiswa/limits [get]
+func (h *LimitHandler) GetMyLimits(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	limits, err := h.service.GetUserLimits(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Limits retrieved successfully", limits)
+}
+
+func describeSubject(policy *LimitPolicy) string {
+	if policy.UserID != nil {
+		return fmt.Sprintf("user #%d", *policy.UserID)
+	}
+	return "role " + policy.Role
+}
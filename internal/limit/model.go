@@ -0,0 +1,88 @@
+package limit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Error codes returned when a limit blocks an operation
+const (
+	CodeTransactionLimit      = "TRANSACTION_LIMIT_EXCEEDED"
+	CodeDailyTransferLimit    = "DAILY_TRANSFER_LIMIT_EXCEEDED"
+	CodeMonthlyTransferLimit  = "MONTHLY_TRANSFER_LIMIT_EXCEEDED"
+	CodeDailyMarketplaceLimit = "DAILY_MARKETPLACE_LIMIT_EXCEEDED"
+)
+
+// LimitPolicy caps outgoing points for a role or a single user. Nil fields
+// are not limited. A user policy overrides the role policy field by field.
+type LimitPolicy struct {
+	ID                    uint      `json:"id" gorm:"primaryKey"`
+	Role                  string    `json:"role,omitempty" gorm:"size:20;index"`
+	UserID                *uint     `json:"user_id,omitempty" gorm:"index"`
+	PerTransactionLimit   *int      `json:"per_transaction_limit"`
+	DailyTransferLimit    *int      `json:"daily_transfer_limit"`
+	MonthlyTransferLimit  *int      `json:"monthly_transfer_limit"`
+	DailyMarketplaceLimit *int      `json:"daily_marketplace_limit"`
+	Note                  string    `json:"note" gorm:"size:255"`
+	CreatedBy             uint      `json:"created_by"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+func (LimitPolicy) TableName() string {
+	return "limit_policies"
+}
+
+type LimitPolicyRequest struct {
+	Role                  string `json:"role" binding:"omitempty,oneof=admin dosen mahasiswa"`
+	UserID                *uint  `json:"user_id"`
+	PerTransactionLimit   *int   `json:"per_transaction_limit" binding:"omitempty,gte=0"`
+	DailyTransferLimit    *int   `json:"daily_transfer_limit" binding:"omitempty,gte=0"`
+	MonthlyTransferLimit  *int   `json:"monthly_transfer_limit" binding:"omitempty,gte=0"`
+	DailyMarketplaceLimit *int   `json:"daily_marketplace_limit" binding:"omitempty,gte=0"`
+	Note                  string `json:"note"`
+}
+
+// EffectiveLimits are the limits that apply to a user after merging the
+// role and user policies
+type EffectiveLimits struct {
+	UserID                uint   `json:"user_id"`
+	Role                  string `json:"role"`
+	PerTransactionLimit   *int   `json:"per_transaction_limit"`
+	DailyTransferLimit    *int   `json:"daily_transfer_limit"`
+	MonthlyTransferLimit  *int   `json:"monthly_transfer_limit"`
+	DailyMarketplaceLimit *int   `json:"daily_marketplace_limit"`
+}
+
+type LimitUsage struct {
+	TransferToday     int `json:"transfer_today"`
+	TransferThisMonth int `json:"transfer_this_month"`
+	MarketplaceToday  int `json:"marketplace_today"`
+}
+
+type UserLimits struct {
+	Limits EffectiveLimits `json:"limits"`
+	Usage  LimitUsage      `json:"usage"`
+}
+
+// LimitError reports which limit blocked an operation
+type LimitError struct {
+	Code      string `json:"code"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Attempted int    `json:"attempted"`
+}
+
+func (e *LimitError) Error() string {
+	switch e.Code {
+	case CodeTransactionLimit:
+		return fmt.Sprintf("amount %d exceeds the per-transaction limit of %d points", e.Attempted, e.Limit)
+	case CodeDailyTransferLimit:
+		return fmt.Sprintf("daily transfer limit of %d points exceeded (%d already sent today)", e.Limit, e.Used)
+	case CodeMonthlyTransferLimit:
+		return fmt.Sprintf("monthly transfer limit of %d points exceeded (%d already sent this month)", e.Limit, e.Used)
+	case CodeDailyMarketplaceLimit:
+		return fmt.Sprintf("daily marketplace limit of %d points exceeded (%d already spent today)", e.Limit, e.Used)
+	}
+	return "limit exceeded"
+}
@@ -0,0 +1,115 @@
+package limit
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LimitRepository struct {
+	db *gorm.DB
+}
+
+func NewLimitRepository(db *gorm.DB) *LimitRepository {
+	return &LimitRepository{db: db}
+}
+
+// GetAll lists every limit policy, role policies first
+func (r *LimitRepository) GetAll() ([]LimitPolicy, error) {
+	var policies []LimitPolicy
+	err := r.db.Order("user_id IS NOT NULL, role ASC, user_id ASC").Find(&policies).Error
+	return policies, err
+}
+
+// FindByID finds a limit policy by ID
+func (r *LimitRepository) FindByID(id uint) (*LimitPolicy, error) {
+	var policy LimitPolicy
+	err := r.db.First(&policy, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("limit policy not found")
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// FindBySubject finds the policy of a role or user, nil if there is none
+func (r *LimitRepository) FindBySubject(tx *gorm.DB, role string, userID *uint) (*LimitPolicy, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var policy LimitPolicy
+	query := tx
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	} else {
+		query = query.Where("role = ? AND user_id IS NULL", role)
+	}
+	err := query.First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *LimitRepository) Create(policy *LimitPolicy) error {
+	return r.db.Create(policy).Error
+}
+
+// Save stores every field of a policy, including limits cleared to nil
+func (r *LimitRepository) Save(policy *LimitPolicy) error {
+	return r.db.Save(policy).Error
+}
+
+func (r *LimitRepository) Delete(id uint) error {
+	return r.db.Delete(&LimitPolicy{}, id).Error
+}
+
+// GetUserRole returns the role of a user
+func (r *LimitRepository) GetUserRole(tx *gorm.DB, userID uint) (string, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var role string
+	err := tx.Table("users").Where("id = ?", userID).Select("role").Scan(&role).Error
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return "", errors.New("user not found")
+	}
+	return role, nil
+}
+
+// SumDebits totals the outgoing points of a wallet since a given time.
+// Pending debits of active holds count as spent, reversed debits do not.
+func (r *LimitRepository) SumDebits(tx *gorm.DB, walletID uint, txnType string, since time.Time) (int, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var total int
+	err := tx.Table("wallet_transactions").
+		Where("wallet_id = ? AND direction = ? AND type = ? AND status IN ? AND created_at >= ? AND reversed_at IS NULL",
+			walletID, "debit", txnType, []string{"success", "pending"}, since).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// FindWalletID returns the wallet of a user
+func (r *LimitRepository) FindWalletID(userID uint) (uint, error) {
+	var walletID uint
+	err := r.db.Table("wallets").Where("user_id = ?", userID).Select("id").Scan(&walletID).Error
+	if err != nil {
+		return 0, err
+	}
+	if walletID == 0 {
+		return 0, errors.New("wallet not found")
+	}
+	return walletID, nil
+}
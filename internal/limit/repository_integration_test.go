@@ -0,0 +1,60 @@
+//go:build integration
+
+package limit_test
+
+import (
+	"os"
+	"testing"
+	"time"
+	"wallet-point/internal/database"
+	"wallet-point/internal/limit"
+	"wallet-point/internal/wallet"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Run with TEST_DB_DSN pointing at a development database, see
+// internal/wallet/integration_test.go
+
+func TestSumDebitsSkipsReversedDebits(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Migrate(db)
+
+	// A wallet ID no other test uses, the rows are only summed
+	walletID := uint(2_000_000_000 + time.Now().UnixNano()%100_000_000)
+	now := time.Now()
+	since := now.Add(-time.Hour)
+	rows := []wallet.WalletTransaction{
+		{Type: "transfer_out", Direction: "debit", Amount: 30, Status: "success"},
+		{Type: "transfer_out", Direction: "debit", Amount: 10, Status: "pending"},
+		{Type: "transfer_out", Direction: "debit", Amount: 20, Status: "success", ReversedAt: &now},
+		{Type: "transfer_out", Direction: "debit", Amount: 5, Status: "failed"},
+		{Type: "transfer_out", Direction: "debit", Amount: 7, Status: "success", CreatedAt: since.Add(-time.Minute)},
+		{Type: "marketplace", Direction: "debit", Amount: 9, Status: "success"},
+		{Type: "transfer_in", Direction: "credit", Amount: 4, Status: "success"},
+	}
+	for i := range rows {
+		rows[i].WalletID = walletID
+		if err := db.Create(&rows[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { db.Where("wallet_id = ?", walletID).Delete(&wallet.WalletTransaction{}) })
+
+	total, err := limit.NewLimitRepository(db).SumDebits(nil, walletID, "transfer_out", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 40 {
+		t.Errorf("SumDebits() = %d, want 40 from the successful and pending debits", total)
+	}
+}
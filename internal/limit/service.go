@@ -0,0 +1,229 @@
+package limit
+
+import (
+	"errors"
+	"time"
+	"wallet-point/utils"
+
+	"gorm.io/gorm"
+)
+
+type LimitService struct {
+	repo *LimitRepository
+}
+
+func NewLimitService(repo *LimitRepository) *LimitService {
+	return &LimitService{repo: repo}
+}
+
+func (s *LimitService) GetPolicies() ([]LimitPolicy, error) {
+	return s.repo.GetAll()
+}
+
+// CreatePolicy adds the limit policy of a role or a user
+func (s *LimitService) CreatePolicy(req *LimitPolicyRequest, adminID uint) (*LimitPolicy, error) {
+	if (req.Role == "") == (req.UserID == nil) {
+		return nil, errors.New("set either role or user_id")
+	}
+	if req.UserID != nil {
+		req.Role = ""
+		if _, err := s.repo.GetUserRole(nil, *req.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := s.repo.FindBySubject(nil, req.Role, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("a limit policy already exists for this role or user")
+	}
+
+	policy := &LimitPolicy{
+		Role:      req.Role,
+		UserID:    req.UserID,
+		CreatedBy: adminID,
+	}
+	applyRequest(policy, req)
+
+	if err := s.repo.Create(policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdatePolicy replaces the limits of a policy, its role or user stays the same
+func (s *LimitService) UpdatePolicy(id uint, req *LimitPolicyRequest) (*LimitPolicy, error) {
+	policy, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyRequest(policy, req)
+
+	if err := s.repo.Save(policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (s *LimitService) DeletePolicy(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// GetEffectiveLimits merges the role and user policies of a user
+func (s *LimitService) GetEffectiveLimits(tx *gorm.DB, userID uint) (*EffectiveLimits, error) {
+	role, err := s.repo.GetUserRole(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := &EffectiveLimits{UserID: userID, Role: role}
+
+	rolePolicy, err := s.repo.FindBySubject(tx, role, nil)
+	if err != nil {
+		return nil, err
+	}
+	userPolicy, err := s.repo.FindBySubject(tx, "", &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range []*LimitPolicy{rolePolicy, userPolicy} {
+		if p == nil {
+			continue
+		}
+		if p.PerTransactionLimit != nil {
+			limits.PerTransactionLimit = p.PerTransactionLimit
+		}
+		if p.DailyTransferLimit != nil {
+			limits.DailyTransferLimit = p.DailyTransferLimit
+		}
+		if p.MonthlyTransferLimit != nil {
+			limits.MonthlyTransferLimit = p.MonthlyTransferLimit
+		}
+		if p.DailyMarketplaceLimit != nil {
+			limits.DailyMarketplaceLimit = p.DailyMarketplaceLimit
+		}
+	}
+
+	return limits, nil
+}
+
+// GetUserLimits returns the limits of a user together with what was used so far
+func (s *LimitService) GetUserLimits(userID uint) (*UserLimits, error) {
+	limits, err := s.GetEffectiveLimits(nil, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	walletID, err := s.repo.FindWalletID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	day, month := periodStarts(time.Now().In(utils.Location()))
+	result := &UserLimits{Limits: *limits}
+
+	if result.Usage.TransferToday, err = s.repo.SumDebits(nil, walletID, "transfer_out", day); err != nil {
+		return nil, err
+	}
+	if result.Usage.TransferThisMonth, err = s.repo.SumDebits(nil, walletID, "transfer_out", month); err != nil {
+		return nil, err
+	}
+	if result.Usage.MarketplaceToday, err = s.repo.SumDebits(nil, walletID, "marketplace", day); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CheckTransfer enforces the transfer limits of a sender. Call it inside the
+// transaction after the debit was posted: the wallet row is locked and the
+// new debit is already part of the totals, so concurrent transfers cannot
+// slip past the limit together.
+func (s *LimitService) CheckTransfer(tx *gorm.DB, userID, walletID uint, amount int) error {
+	limits, err := s.GetEffectiveLimits(tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPerTransaction(limits, amount); err != nil {
+		return err
+	}
+
+	day, month := periodStarts(time.Now().In(utils.Location()))
+
+	if limits.DailyTransferLimit != nil {
+		total, err := s.repo.SumDebits(tx, walletID, "transfer_out", day)
+		if err != nil {
+			return err
+		}
+		if total > *limits.DailyTransferLimit {
+			return &LimitError{Code: CodeDailyTransferLimit, Limit: *limits.DailyTransferLimit, Used: total - amount, Attempted: amount}
+		}
+	}
+
+	if limits.MonthlyTransferLimit != nil {
+		total, err := s.repo.SumDebits(tx, walletID, "transfer_out", month)
+		if err != nil {
+			return err
+		}
+		if total > *limits.MonthlyTransferLimit {
+			return &LimitError{Code: CodeMonthlyTransferLimit, Limit: *limits.MonthlyTransferLimit, Used: total - amount, Attempted: amount}
+		}
+	}
+
+	return nil
+}
+
+// CheckMarketplace enforces the marketplace limits of a buyer. Like
+// CheckTransfer it must run after the debit was posted.
+func (s *LimitService) CheckMarketplace(tx *gorm.DB, userID, walletID uint, amount int) error {
+	limits, err := s.GetEffectiveLimits(tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPerTransaction(limits, amount); err != nil {
+		return err
+	}
+
+	if limits.DailyMarketplaceLimit != nil {
+		day, _ := periodStarts(time.Now().In(utils.Location()))
+		total, err := s.repo.SumDebits(tx, walletID, "marketplace", day)
+		if err != nil {
+			return err
+		}
+		if total > *limits.DailyMarketplaceLimit {
+			return &LimitError{Code: CodeDailyMarketplaceLimit, Limit: *limits.DailyMarketplaceLimit, Used: total - amount, Attempted: amount}
+		}
+	}
+
+	return nil
+}
+
+func checkPerTransaction(limits *EffectiveLimits, amount int) error {
+	if limits.PerTransactionLimit != nil && amount > *limits.PerTransactionLimit {
+		return &LimitError{Code: CodeTransactionLimit, Limit: *limits.PerTransactionLimit, Attempted: amount}
+	}
+	return nil
+}
+
+func applyRequest(policy *LimitPolicy, req *LimitPolicyRequest) {
+	policy.PerTransactionLimit = req.PerTransactionLimit
+	policy.DailyTransferLimit = req.DailyTransferLimit
+	policy.MonthlyTransferLimit = req.MonthlyTransferLimit
+	policy.DailyMarketplaceLimit = req.DailyMarketplaceLimit
+	policy.Note = req.Note
+}
+
+// periodStarts returns the start of the day and month of now, in the
+// location of now
+func periodStarts(now time.Time) (time.Time, time.Time) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return day, month
+}
@@ -0,0 +1,77 @@
+package limit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodStarts(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantDay   time.Time
+		wantMonth time.Time
+	}{
+		{
+			// 20:00 UTC on Jan 31 is already Feb 1 in Jakarta
+			name:      "new month in Jakarta",
+			now:       time.Date(2026, 1, 31, 20, 0, 0, 0, time.UTC).In(jakarta),
+			wantDay:   time.Date(2026, 2, 1, 0, 0, 0, 0, jakarta),
+			wantMonth: time.Date(2026, 2, 1, 0, 0, 0, 0, jakarta),
+		},
+		{
+			name:      "mid month",
+			now:       time.Date(2026, 3, 17, 14, 30, 0, 0, jakarta),
+			wantDay:   time.Date(2026, 3, 17, 0, 0, 0, 0, jakarta),
+			wantMonth: time.Date(2026, 3, 1, 0, 0, 0, 0, jakarta),
+		},
+		{
+			name:      "just before midnight",
+			now:       time.Date(2026, 12, 31, 23, 59, 59, 0, jakarta),
+			wantDay:   time.Date(2026, 12, 31, 0, 0, 0, 0, jakarta),
+			wantMonth: time.Date(2026, 12, 1, 0, 0, 0, 0, jakarta),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, month := periodStarts(tt.now)
+			if !day.Equal(tt.wantDay) {
+				t.Errorf("day = %v, want %v", day, tt.wantDay)
+			}
+			if !month.Equal(tt.wantMonth) {
+				t.Errorf("month = %v, want %v", month, tt.wantMonth)
+			}
+		})
+	}
+}
+
+func TestCheckPerTransaction(t *testing.T) {
+	limit := 50
+	tests := []struct {
+		name   string
+		limits EffectiveLimits
+		amount int
+		want   bool
+	}{
+		{"no limit", EffectiveLimits{}, 1000, false},
+		{"at the limit", EffectiveLimits{PerTransactionLimit: &limit}, 50, false},
+		{"over the limit", EffectiveLimits{PerTransactionLimit: &limit}, 51, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPerTransaction(&tt.limits, tt.amount)
+			if (err != nil) != tt.want {
+				t.Fatalf("checkPerTransaction() error = %v, want error %v", err, tt.want)
+			}
+			if err == nil {
+				return
+			}
+			limitErr, ok := err.(*LimitError)
+			if !ok || limitErr.Code != CodeTransactionLimit || limitErr.Attempted != tt.amount {
+				t.Errorf("checkPerTransaction() error = %#v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package marketplace
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GetCategoryTree returns every category nested under its parent
+func (s *MarketplaceService) GetCategoryTree() ([]ProductCategory, error) {
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	return buildCategoryTree(categories, nil), nil
+}
+
+// CreateCategory adds a category, at the top level or under a parent
+func (s *MarketplaceService) CreateCategory(req *CategoryRequest) (*ProductCategory, error) {
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	category := &ProductCategory{Name: strings.TrimSpace(req.Name), ParentID: normalizeParent(req.ParentID)}
+	if err := validateCategory(categories, category); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateCategory(category); err != nil {
+		return nil, errors.New("failed to create category")
+	}
+	return category, nil
+}
+
+// UpdateCategory renames a category or moves it under another parent
+func (s *MarketplaceService) UpdateCategory(categoryID uint, req *CategoryRequest) (*ProductCategory, error) {
+	category, err := s.repo.FindCategory(categoryID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	category.Name = strings.TrimSpace(req.Name)
+	category.ParentID = normalizeParent(req.ParentID)
+	if err := validateCategory(categories, category); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.UpdateCategory(categoryID, map[string]interface{}{
+		"name":      category.Name,
+		"parent_id": category.ParentID,
+	})
+	if err != nil {
+		return nil, errors.New("failed to update category")
+	}
+	return s.repo.FindCategory(categoryID)
+}
+
+// DeleteCategory removes a category without subcategories. Its products move
+// up to the parent category.
+func (s *MarketplaceService) DeleteCategory(categoryID uint) error {
+	category, err := s.repo.FindCategory(categoryID)
+	if err != nil {
+		return err
+	}
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return err
+	}
+	for _, c := range categories {
+		if c.ParentID != nil && *c.ParentID == categoryID {
+			return errors.New("category still has subcategories")
+		}
+	}
+	return s.repo.DeleteCategory(category)
+}
+
+// GetTags lists the tags in use on active products
+func (s *MarketplaceService) GetTags() ([]TagCount, error) {
+	return s.repo.GetTagCounts()
+}
+
+// categoryAndDescendants returns the ID of a category and of every category
+// below it
+func (s *MarketplaceService) categoryAndDescendants(categoryID uint) ([]uint, error) {
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []uint{categoryID}
+	for i := 0; i < len(ids); i++ {
+		for _, c := range categories {
+			if c.ParentID != nil && *c.ParentID == ids[i] {
+				ids = append(ids, c.ID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// checkCategory makes sure a product is put in an existing category
+func (s *MarketplaceService) checkCategory(categoryID *uint) error {
+	if categoryID == nil || *categoryID == 0 {
+		return nil
+	}
+	_, err := s.repo.FindCategory(*categoryID)
+	return err
+}
+
+// attachTags fills in the tags of each product
+func (s *MarketplaceService) attachTags(products []Product) error {
+	ids := make([]uint, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+
+	tags, err := s.repo.GetProductTags(ids)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		products[i].Tags = tags[products[i].ID]
+		if products[i].Tags == nil {
+			products[i].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// normalizeTags lowercases, trims and de-duplicates tags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > 50 {
+			return nil, fmt.Errorf("tag '%s' is longer than 50 characters", tag)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxProductTags {
+		return nil, fmt.Errorf("a product can have at most %d tags", MaxProductTags)
+	}
+	return normalized, nil
+}
+
+// validateCategory checks the name and parent of a new or changed category.
+// Names are unique among siblings and a category cannot be moved below itself.
+func validateCategory(categories []ProductCategory, category *ProductCategory) error {
+	if category.Name == "" {
+		return errors.New("category name is required")
+	}
+
+	byID := make(map[uint]ProductCategory, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	if category.ParentID != nil {
+		if _, ok := byID[*category.ParentID]; !ok {
+			return errors.New("parent category not found")
+		}
+		for id := category.ParentID; id != nil; id = byID[*id].ParentID {
+			if *id == category.ID {
+				return errors.New("a category cannot be placed under itself")
+			}
+		}
+	}
+
+	for _, c := range categories {
+		if c.ID != category.ID && sameParent(c.ParentID, category.ParentID) && strings.EqualFold(c.Name, category.Name) {
+			return errors.New("category already exists")
+		}
+	}
+	return nil
+}
+
+func buildCategoryTree(categories []ProductCategory, parentID *uint) []ProductCategory {
+	tree := []ProductCategory{}
+	for _, c := range categories {
+		if sameParent(c.ParentID, parentID) {
+			c.Children = buildCategoryTree(categories, &c.ID)
+			tree = append(tree, c)
+		}
+	}
+	return tree
+}
+
+func normalizeParent(parentID *uint) *uint {
+	if parentID == nil || *parentID == 0 {
+		return nil
+	}
+	return parentID
+}
+
+func sameParent(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
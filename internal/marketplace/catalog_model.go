@@ -0,0 +1,53 @@
+package marketplace
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidProductFilter is returned for product list parameters that cannot be applied
+var ErrInvalidProductFilter = errors.New("invalid product filter")
+
+// ProductSorts are the orderings products can be listed in
+var ProductSorts = []string{"newest", "price_asc", "price_desc", "popular"}
+
+// MaxProductTags caps the tags of one product
+const MaxProductTags = 10
+
+// ProductCategory groups products. Categories nest through ParentID, a
+// product in a subcategory is also listed under every parent.
+type ProductCategory struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ParentID  *uint     `json:"parent_id" gorm:"index"`
+	Name      string    `json:"name" gorm:"size:100;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Children []ProductCategory `json:"children,omitempty" gorm:"-"`
+}
+
+func (ProductCategory) TableName() string {
+	return "product_categories"
+}
+
+// ProductTag attaches a free-form tag to a product. Tags are stored lowercase.
+type ProductTag struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	ProductID uint   `json:"product_id" gorm:"not null;uniqueIndex:idx_product_tag"`
+	Tag       string `json:"tag" gorm:"size:50;not null;uniqueIndex:idx_product_tag;index"`
+}
+
+func (ProductTag) TableName() string {
+	return "product_tags"
+}
+
+// TagCount is a tag with the number of active products carrying it
+type TagCount struct {
+	Tag      string `json:"tag"`
+	Products int    `json:"products"`
+}
+
+type CategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	ParentID *uint  `json:"parent_id"`
+}
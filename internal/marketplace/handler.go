@@ -1,9 +1,12 @@
 package marketplace
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"wallet-point/internal/audit"
+	"wallet-point/internal/limit"
 	"wallet-point/utils"
 
 	"fmt"
@@ -20,11 +23,17 @@ func NewMarketplaceHandler(service *MarketplaceService, auditService *audit.Audi
 	return &MarketplaceHandler{service: service, auditService: auditService}
 }
 
-// GetAll handles getting all products
+// GetAll handles getting all products. Products can be searched by keyword
+// (q) and filtered by category_id, tags, min_price, max_price and in_stock,
+// and sorted by newest, price_asc, price_desc or popular.
 func (h *MarketplaceHandler) GetAll(c *gin.Context) {
 	status := c.Query("status")
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	categoryID, _ := strconv.ParseUint(c.Query("category_id"), 10, 32)
+	minPrice, _ := strconv.Atoi(c.Query("min_price"))
+	maxPrice, _ := strconv.Atoi(c.Query("max_price"))
+	inStock, _ := strconv.ParseBool(c.Query("in_stock"))
 
 	role, _ := c.Get("role")
 	if role == "mahasiswa" {
@@ -32,14 +41,25 @@ func (h *MarketplaceHandler) GetAll(c *gin.Context) {
 	}
 
 	params := ProductListParams{
-		Status: status,
-		Page:   page,
-		Limit:  limit,
+		Status:     status,
+		Search:     c.Query("q"),
+		CategoryID: uint(categoryID),
+		Tags:       splitTags(c.Query("tags")),
+		MinPrice:   minPrice,
+		MaxPrice:   maxPrice,
+		InStock:    inStock,
+		Sort:       c.Query("sort"),
+		Page:       page,
+		Limit:      limit,
 	}
 
 	response, err := h.service.GetAllProducts(params)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve products", err.Error())
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidProductFilter) {
+			statusCode = http.StatusBadRequest
+		}
+		utils.ErrorResponse(c, statusCode, "Failed to retrieve products", err.Error())
 		return
 	}
 
@@ -106,6 +126,8 @@ func (h *MarketplaceHandler) Create(c *gin.Context) {
 		Price:       price,
 		Stock:       stock,
 		ImageURL:    imageURL,
+		CategoryID:  formCategoryID(c),
+		Tags:        splitTags(c.PostForm("tags")),
 	}
 
 	if req.Name == "" || req.Price <= 0 {
@@ -175,6 +197,11 @@ func (h *MarketplaceHandler) Update(c *gin.Context) {
 		Stock:       stock,
 		ImageURL:    imageURL,
 		Status:      status,
+		CategoryID:  formCategoryID(c),
+	}
+	if tags, ok := c.GetPostForm("tags"); ok {
+		list := splitTags(tags)
+		req.Tags = &list
 	}
 
 	product, err := h.service.UpdateProduct(uint(productID), &req)
@@ -242,20 +269,165 @@ func (h *MarketplaceHandler) Purchase(c *gin.Context) {
 		return
 	}
 
-	err := h.service.PurchaseProduct(userID, &req)
+	order, err := h.service.PurchaseProduct(userID, &req)
 	if err != nil {
+		if limit.RespondIfLimitError(c, err) {
+			return
+		}
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Purchase successful", nil)
+	utils.SuccessResponse(c, http.StatusOK, "Purchase successful", order)
 
 	h.auditService.LogActivity(audit.CreateAuditParams{
 		UserID:    userID,
 		Action:    "PURCHASE_PRODUCT",
 		Entity:    "PRODUCT",
 		EntityID:  req.ProductID,
-		Details:   fmt.Sprintf("User purchased units of product ID %d", req.ProductID),
+		Details:   fmt.Sprintf("User purchased units of product ID %d (order %s)", req.ProductID, order.CheckoutID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// formCategoryID reads the category of a product form. An empty value or 0
+// takes the product out of its category; a missing field leaves it as is.
+func formCategoryID(c *gin.Context) *uint {
+	value, ok := c.GetPostForm("category_id")
+	if !ok {
+		return nil
+	}
+	categoryID, _ := strconv.ParseUint(value, 10, 32)
+	id := uint(categoryID)
+	return &id
+}
+
+// splitTags reads a comma separated list of tags
+func splitTags(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+// GetCategories handles getting the category tree
+func (h *MarketplaceHandler) GetCategories(c *gin.Context) {
+	categories, err := h.service.GetCategoryTree()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve categories", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Categories retrieved successfully", categories)
+}
+
+// GetTags handles getting the tags used on active products
+func (h *MarketplaceHandler) GetTags(c *gin.Context) {
+	tags, err := h.service.GetTags()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve tags", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Tags retrieved successfully", tags)
+}
+
+// CreateCategory handles creating a product category
+func (h *MarketplaceHandler) CreateCategory(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	category, err := h.service.CreateCategory(&req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Category created successfully", category)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "CREATE_CATEGORY",
+		Entity:    "PRODUCT_CATEGORY",
+		EntityID:  category.ID,
+		Details:   "Admin created product category: " + category.Name,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// UpdateCategory handles renaming or moving a product category
+func (h *MarketplaceHandler) UpdateCategory(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid category ID", nil)
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	category, err := h.service.UpdateCategory(uint(categoryID), &req)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "category not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Category updated successfully", category)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "UPDATE_CATEGORY",
+		Entity:    "PRODUCT_CATEGORY",
+		EntityID:  category.ID,
+		Details:   "Admin updated product category: " + category.Name,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// DeleteCategory handles deleting a product category
+func (h *MarketplaceHandler) DeleteCategory(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid category ID", nil)
+		return
+	}
+
+	if err := h.service.DeleteCategory(uint(categoryID)); err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "category not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Category deleted successfully", nil)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "DELETE_CATEGORY",
+		Entity:    "PRODUCT_CATEGORY",
+		EntityID:  uint(categoryID),
+		Details:   "Admin deleted product category ID: " + strconv.FormatUint(categoryID, 10),
 		IPAddress: c.ClientIP(),
 		UserAgent: c.Request.UserAgent(),
 	})
@@ -342,19 +514,480 @@ func (h *MarketplaceHandler) Checkout(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Checkout(userID, req); err != nil {
+	order, err := h.service.Checkout(userID, req)
+	if err != nil {
+		if limit.RespondIfLimitError(c, err) {
+			return
+		}
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Checkout berhasil!", nil)
+	utils.SuccessResponse(c, http.StatusOK, "Checkout berhasil!", order)
 
 	h.auditService.LogActivity(audit.CreateAuditParams{
 		UserID:    userID,
 		Action:    "CART_CHECKOUT",
 		Entity:    "WALLET",
 		EntityID:  userID,
-		Details:   "User completed checkout from cart",
+		Details:   "User completed checkout from cart, order " + order.CheckoutID,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetListings handles browsing the active student listings
+func (h *MarketplaceHandler) GetListings(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	response, err := h.service.GetListings(ListingListParams{
+		Search: c.Query("q"),
+		Status: "active",
+		Page:   page,
+		Limit:  limit,
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve listings", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Listings retrieved successfully", response)
+}
+
+// GetMyListings handles listing the student's own listings in any status
+func (h *MarketplaceHandler) GetMyListings(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	response, err := h.service.GetListings(ListingListParams{
+		SellerID: c.GetUint("user_id"),
+		Status:   c.Query("status"),
+		Page:     page,
+		Limit:    limit,
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve listings", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Listings retrieved successfully", response)
+}
+
+// GetListing handles getting a student listing by ID
+func (h *MarketplaceHandler) GetListing(c *gin.Context) {
+	listingID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid listing ID", nil)
+		return
+	}
+
+	listing, err := h.service.GetListing(uint(listingID))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Listing retrieved successfully", listing)
+}
+
+// CreateListing handles a student putting an item up for sale
+func (h *MarketplaceHandler) CreateListing(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req CreateListingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	listing, err := h.service.CreateListing(userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Listing created successfully", listing)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "CREATE_LISTING",
+		Entity:    "LISTING",
+		EntityID:  listing.ID,
+		Details:   fmt.Sprintf("Student listed %s for %d points", listing.Title, listing.Price),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// UpdateListing handles a student changing their active listing
+func (h *MarketplaceHandler) UpdateListing(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	listingID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid listing ID", nil)
+		return
+	}
+
+	var req UpdateListingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	listing, err := h.service.UpdateListing(uint(listingID), userID, &req)
+	if err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Listing updated successfully", listing)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "UPDATE_LISTING",
+		Entity:    "LISTING",
+		EntityID:  listing.ID,
+		Details:   fmt.Sprintf("Student updated listing %s (%d points)", listing.Title, listing.Price),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// WithdrawListing handles a student taking their listing off the marketplace
+func (h *MarketplaceHandler) WithdrawListing(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	listingID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid listing ID", nil)
+		return
+	}
+
+	if err := h.service.WithdrawListing(uint(listingID), userID); err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Listing withdrawn successfully", nil)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "WITHDRAW_LISTING",
+		Entity:    "LISTING",
+		EntityID:  uint(listingID),
+		Details:   "Student withdrew listing ID: " + strconv.FormatUint(listingID, 10),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// BuyListing handles buying a student listing, the points are held in escrow
+func (h *MarketplaceHandler) BuyListing(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	listingID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid listing ID", nil)
+		return
+	}
+
+	var req BuyListingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	order, err := h.service.BuyListing(userID, uint(listingID), req.PIN)
+	if err != nil {
+		if limit.RespondIfLimitError(c, err) {
+			return
+		}
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Purchase successful, points are held until you confirm receipt", order)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "BUY_LISTING",
+		Entity:    "LISTING_ORDER",
+		EntityID:  order.ID,
+		Details:   fmt.Sprintf("Student bought listing %s from user %d, %d points held in escrow", order.ListingTitle, order.SellerID, order.Amount),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetMyListingOrders handles listing the student's listing purchases, or
+// sales with ?as=seller
+func (h *MarketplaceHandler) GetMyListingOrders(c *gin.Context) {
+	orders, err := h.service.GetListingOrders(c.GetUint("user_id"), c.Query("as") == "seller", c.Query("status"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve listing orders", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Listing orders retrieved successfully", orders)
+}
+
+// ConfirmListingOrder handles the buyer confirming receipt, which pays the seller
+func (h *MarketplaceHandler) ConfirmListingOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid listing order ID", nil)
+		return
+	}
+
+	order, err := h.service.ConfirmListingOrder(uint(orderID), userID)
+	if err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Receipt confirmed, points released to the seller", order)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "CONFIRM_LISTING_ORDER",
+		Entity:    "LISTING_ORDER",
+		EntityID:  order.ID,
+		Details:   fmt.Sprintf("Buyer confirmed receipt, %d points released to user %d", order.Amount, order.SellerID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// DisputeListingOrder handles the buyer disputing a purchase
+func (h *MarketplaceHandler) DisputeListingOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid listing order ID", nil)
+		return
+	}
+
+	var req DisputeListingOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	order, err := h.service.DisputeListingOrder(uint(orderID), userID, req.Reason)
+	if err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Dispute opened, an admin will review it", order)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "DISPUTE_LISTING_ORDER",
+		Entity:    "LISTING_ORDER",
+		EntityID:  order.ID,
+		Details:   "Buyer disputed listing order: " + order.DisputeReason,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetAllListingOrders handles listing every listing order for admins
+func (h *MarketplaceHandler) GetAllListingOrders(c *gin.Context) {
+	orders, err := h.service.GetAllListingOrders(c.Query("status"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve listing orders", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Listing orders retrieved successfully", orders)
+}
+
+// ResolveListingOrder handles an admin settling a disputed listing order
+func (h *MarketplaceHandler) ResolveListingOrder(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid listing order ID", nil)
+		return
+	}
+
+	var req ResolveListingOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	order, err := h.service.ResolveListingOrder(uint(orderID), adminID, &req)
+	if err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Listing order resolved successfully", order)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "RESOLVE_LISTING_ORDER",
+		Entity:    "LISTING_ORDER",
+		EntityID:  order.ID,
+		Details:   fmt.Sprintf("Admin resolved disputed listing order as %s (%d points)", order.Status, order.Amount),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// respondMarketplaceError maps the not found errors of listings and orders to
+// 404 and everything else to 400
+func respondMarketplaceError(c *gin.Context, err error) {
+	statusCode := http.StatusBadRequest
+	switch err.Error() {
+	case "listing not found", "listing order not found", "order not found":
+		statusCode = http.StatusNotFound
+	}
+	utils.ErrorResponse(c, statusCode, err.Error(), nil)
+}
+
+// GetMyOrders handles listing the student's orders with their pickup codes
+func (h *MarketplaceHandler) GetMyOrders(c *gin.Context) {
+	orders, err := h.service.GetMyOrders(c.GetUint("user_id"), c.Query("status"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve orders", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Orders retrieved successfully", orders)
+}
+
+// GetMyOrder handles getting one of the student's orders with its pickup QR
+func (h *MarketplaceHandler) GetMyOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", nil)
+		return
+	}
+
+	order, err := h.service.GetMyOrder(uint(orderID), c.GetUint("user_id"))
+	if err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Order retrieved successfully", order)
+}
+
+// GetFulfilmentQueue handles listing the orders staff still have to hand over
+func (h *MarketplaceHandler) GetFulfilmentQueue(c *gin.Context) {
+	orders, err := h.service.GetFulfilmentQueue(c.Query("status"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve orders", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Orders retrieved successfully", orders)
+}
+
+// MarkOrderReady handles staff marking an order ready for pickup
+func (h *MarketplaceHandler) MarkOrderReady(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", nil)
+		return
+	}
+
+	order, err := h.service.MarkOrderReady(uint(orderID))
+	if err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Order is ready for pickup", order)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "ORDER_READY",
+		Entity:    "MARKETPLACE_ORDER",
+		EntityID:  order.ID,
+		Details:   "Admin marked order " + order.CheckoutID + " ready for pickup",
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// PickUpOrder handles staff scanning a pickup code and handing the order over
+func (h *MarketplaceHandler) PickUpOrder(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req PickupOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	order, err := h.service.PickUpOrder(req.Code, adminID)
+	if err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Order handed over successfully", order)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "ORDER_PICKED_UP",
+		Entity:    "MARKETPLACE_ORDER",
+		EntityID:  order.ID,
+		Details:   fmt.Sprintf("Admin handed order %s over to user %d", order.CheckoutID, order.UserID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// CancelOrder handles cancelling an order, which refunds and restocks it.
+// Students cancel their own orders, admins any order not picked up yet.
+func (h *MarketplaceHandler) CancelOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	role := c.GetString("role")
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", nil)
+		return
+	}
+
+	var req CancelOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	order, err := h.service.CancelOrder(uint(orderID), userID, role, req.Reason)
+	if err != nil {
+		respondMarketplaceError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Order cancelled and refunded", order)
+
+	actor := "Student"
+	if role == "admin" {
+		actor = "Admin"
+	}
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "CANCEL_ORDER",
+		Entity:    "MARKETPLACE_ORDER",
+		EntityID:  order.ID,
+		Details:   fmt.Sprintf("%s cancelled order %s, %d points refunded | Reason: %s", actor, order.CheckoutID, order.TotalAmount, order.CancelReason),
 		IPAddress: c.ClientIP(),
 		UserAgent: c.Request.UserAgent(),
 	})
@@ -0,0 +1,403 @@
+package marketplace
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"wallet-point/internal/wallet"
+
+	"gorm.io/gorm"
+)
+
+// CreateListing puts an item of a student up for sale
+func (s *MarketplaceService) CreateListing(sellerID uint, req *CreateListingRequest) (*Listing, error) {
+	listing := &Listing{
+		SellerID:    sellerID,
+		Title:       strings.TrimSpace(req.Title),
+		Description: req.Description,
+		Price:       req.Price,
+		ImageURL:    req.ImageURL,
+		Status:      "active",
+	}
+	if listing.Title == "" {
+		return nil, errors.New("title is required")
+	}
+
+	if err := s.repo.CreateListing(listing); err != nil {
+		return nil, errors.New("failed to create listing")
+	}
+	return s.repo.FindListing(nil, listing.ID)
+}
+
+// UpdateListing changes a listing of the seller that is not sold or reserved
+func (s *MarketplaceService) UpdateListing(listingID, sellerID uint, req *UpdateListingRequest) (*Listing, error) {
+	if _, err := s.findOwnListing(listingID, sellerID); err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if title := strings.TrimSpace(req.Title); title != "" {
+		updates["title"] = title
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+	if req.Price > 0 {
+		updates["price"] = req.Price
+	}
+	if req.ImageURL != "" {
+		updates["image_url"] = req.ImageURL
+	}
+
+	if len(updates) > 0 {
+		updated, err := s.repo.UpdateListing(nil, listingID, "active", updates)
+		if err != nil {
+			return nil, errors.New("failed to update listing")
+		}
+		if !updated {
+			return nil, errors.New("only active listings can be changed")
+		}
+	}
+	return s.repo.FindListing(nil, listingID)
+}
+
+// WithdrawListing takes an active listing of the seller off the marketplace
+func (s *MarketplaceService) WithdrawListing(listingID, sellerID uint) error {
+	if _, err := s.findOwnListing(listingID, sellerID); err != nil {
+		return err
+	}
+
+	updated, err := s.repo.UpdateListing(nil, listingID, "active", map[string]interface{}{"status": "withdrawn"})
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.New("only active listings can be withdrawn")
+	}
+	return nil
+}
+
+// GetListings lists student listings with pagination
+func (s *MarketplaceService) GetListings(params ListingListParams) (*ListingListResponse, error) {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.Limit < 1 {
+		params.Limit = 20
+	}
+	params.Search = strings.TrimSpace(params.Search)
+
+	listings, total, err := s.repo.GetListings(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListingListResponse{
+		Listings:   listings,
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(params.Limit))),
+	}, nil
+}
+
+// GetListing returns a student listing
+func (s *MarketplaceService) GetListing(listingID uint) (*Listing, error) {
+	return s.repo.FindListing(nil, listingID)
+}
+
+// BuyListing pays for a listing into escrow and reserves it for the buyer.
+// The seller is paid once the buyer confirms receipt.
+func (s *MarketplaceService) BuyListing(buyerID, listingID uint, pin string) (*ListingOrder, error) {
+	if err := s.authService.VerifyPIN(buyerID, pin); err != nil {
+		return nil, err
+	}
+
+	listing, err := s.repo.FindListing(nil, listingID)
+	if err != nil {
+		return nil, err
+	}
+	if listing.SellerID == buyerID {
+		return nil, errors.New("cannot buy your own listing")
+	}
+	if listing.Status != "active" {
+		return nil, errors.New("listing is no longer available")
+	}
+
+	buyerWallet, err := s.walletService.GetWalletByUserID(buyerID)
+	if err != nil {
+		return nil, err
+	}
+	if buyerWallet.AvailableBalance < listing.Price {
+		return nil, fmt.Errorf("%w. Required: %d", wallet.ErrInsufficientBalance, listing.Price)
+	}
+
+	order := &ListingOrder{
+		ListingID: listing.ID,
+		BuyerID:   buyerID,
+		SellerID:  listing.SellerID,
+		Amount:    listing.Price,
+		Status:    "escrowed",
+		ExpiresAt: time.Now().Add(EscrowTimeout),
+	}
+
+	err = s.walletService.Transaction(func(tx *gorm.DB) error {
+		// Only one buyer can reserve the listing, at the price they saw
+		reserved, err := s.repo.UpdateListing(tx, listing.ID, "active", map[string]interface{}{"status": "reserved"})
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			return errors.New("listing is no longer available")
+		}
+		current, err := s.repo.FindListing(tx, listing.ID)
+		if err != nil {
+			return err
+		}
+		if current.Price != listing.Price {
+			return errors.New("listing price has changed, please review it again")
+		}
+
+		walletTxn, err := s.walletService.EscrowDebitWithTransaction(tx, buyerWallet.ID, order.Amount, "Escrow: "+listing.Title)
+		if err != nil {
+			return err
+		}
+		if err := s.checkSpendLimit(tx, buyerID, buyerWallet.ID, order.Amount); err != nil {
+			return err
+		}
+
+		order.EscrowTransactionID = &walletTxn.ID
+		return s.repo.CreateListingOrder(tx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order.ListingTitle = listing.Title
+	order.SellerName = listing.SellerName
+	return order, nil
+}
+
+// ConfirmListingOrder is the buyer confirming they received the item, which
+// releases the escrowed points to the seller
+func (s *MarketplaceService) ConfirmListingOrder(orderID, buyerID uint) (*ListingOrder, error) {
+	return s.changeListingOrder(orderID, func(tx *gorm.DB, order *ListingOrder) error {
+		if order.BuyerID != buyerID {
+			return errors.New("listing order not found")
+		}
+		if order.Status != "escrowed" {
+			return fmt.Errorf("listing order is already %s", order.Status)
+		}
+		return s.settleListingOrder(tx, order, "released", nil)
+	})
+}
+
+// DisputeListingOrder holds the escrowed points of an order until an admin
+// decides who gets them. A disputed order no longer times out.
+func (s *MarketplaceService) DisputeListingOrder(orderID, buyerID uint, reason string) (*ListingOrder, error) {
+	return s.changeListingOrder(orderID, func(tx *gorm.DB, order *ListingOrder) error {
+		if order.BuyerID != buyerID {
+			return errors.New("listing order not found")
+		}
+		if order.Status != "escrowed" {
+			return fmt.Errorf("listing order is already %s", order.Status)
+		}
+
+		order.Status = "disputed"
+		order.DisputeReason = reason
+		return s.repo.UpdateListingOrder(tx, order.ID, map[string]interface{}{
+			"status":         order.Status,
+			"dispute_reason": order.DisputeReason,
+		})
+	})
+}
+
+// ResolveListingOrder settles a disputed order: a refund pays the buyer back
+// and puts the listing up for sale again, a release pays the seller
+func (s *MarketplaceService) ResolveListingOrder(orderID, adminID uint, req *ResolveListingOrderRequest) (*ListingOrder, error) {
+	return s.changeListingOrder(orderID, func(tx *gorm.DB, order *ListingOrder) error {
+		if order.Status != "disputed" {
+			return errors.New("only disputed listing orders can be resolved")
+		}
+
+		status := "released"
+		if req.Resolution == "refund" {
+			status = "refunded"
+		}
+		order.ResolvedBy = &adminID
+		order.ResolutionNote = req.Note
+		return s.settleListingOrder(tx, order, status, map[string]interface{}{
+			"resolved_by":     order.ResolvedBy,
+			"resolution_note": order.ResolutionNote,
+		})
+	})
+}
+
+// GetListingOrders lists the listing orders a user bought, or sold when
+// asSeller is set
+func (s *MarketplaceService) GetListingOrders(userID uint, asSeller bool, status string) ([]ListingOrder, error) {
+	if asSeller {
+		return s.repo.GetListingOrders(status, 0, userID)
+	}
+	return s.repo.GetListingOrders(status, userID, 0)
+}
+
+// GetAllListingOrders lists every listing order for admins
+func (s *MarketplaceService) GetAllListingOrders(status string) ([]ListingOrder, error) {
+	return s.repo.GetListingOrders(status, 0, 0)
+}
+
+// RefundExpiredListingOrders refunds every escrowed order the buyer neither
+// confirmed nor disputed in time
+func (s *MarketplaceService) RefundExpiredListingOrders() (*EscrowSweepResult, error) {
+	ids, err := s.repo.FindExpiredListingOrders(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &EscrowSweepResult{}
+	for _, id := range ids {
+		refunded := false
+		_, err := s.changeListingOrder(id, func(tx *gorm.DB, order *ListingOrder) error {
+			// Confirmed or disputed since it was picked
+			if order.Status != "escrowed" || order.ExpiresAt.After(time.Now()) {
+				return nil
+			}
+			refunded = true
+			return s.settleListingOrder(tx, order, "refunded", nil)
+		})
+		if err != nil {
+			log.Printf("[Escrow] Refund of listing order %d failed: %v", id, err)
+			result.Failed++
+			continue
+		}
+		if refunded {
+			result.Refunded++
+		}
+	}
+	return result, nil
+}
+
+// RunEscrowTimeouts refunds expired listing orders on a fixed interval. It
+// blocks, so start it in its own goroutine.
+func (s *MarketplaceService) RunEscrowTimeouts(interval time.Duration) {
+	for {
+		result, err := s.RefundExpiredListingOrders()
+		if err != nil {
+			log.Printf("[Escrow] Timeout run failed: %v", err)
+		} else if result.Refunded > 0 || result.Failed > 0 {
+			log.Printf("[Escrow] Refunded %d expired listing orders (%d failed)", result.Refunded, result.Failed)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// changeListingOrder locks an order and applies change to it in one transaction
+func (s *MarketplaceService) changeListingOrder(orderID uint, change func(tx *gorm.DB, order *ListingOrder) error) (*ListingOrder, error) {
+	var order *ListingOrder
+	err := s.walletService.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = s.repo.LockListingOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+		return change(tx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// settleListingOrder pays the escrowed points of an order out, to the seller
+// when released or back to the buyer when refunded. A refunded listing is
+// put up for sale again. Points meant for a seller whose wallet has been
+// closed go back to the buyer and the listing is withdrawn; a refund to a
+// closed buyer wallet fails and leaves the points in escrow.
+func (s *MarketplaceService) settleListingOrder(tx *gorm.DB, order *ListingOrder, status string, extra map[string]interface{}) error {
+	listing, err := s.repo.FindListing(tx, order.ListingID)
+	if err != nil {
+		return err
+	}
+
+	payee, listingStatus, description := order.SellerID, "sold", "Sold: "+listing.Title
+	if status == "refunded" {
+		payee, listingStatus, description = order.BuyerID, "active", "Refund: "+listing.Title
+	}
+
+	payeeWallet, err := s.walletService.GetWalletByUserID(payee)
+	if err != nil {
+		return err
+	}
+	if status == "released" && payeeWallet.Status == "closed" {
+		status, listingStatus, description = "refunded", "withdrawn", "Refund, seller wallet closed: "+listing.Title
+		if payeeWallet, err = s.walletService.GetWalletByUserID(order.BuyerID); err != nil {
+			return err
+		}
+	}
+	walletTxn, err := s.walletService.EscrowCreditWithTransaction(tx, payeeWallet.ID, order.Amount, description, order.EscrowTransactionID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	order.Status = status
+	order.SettlementTransactionID = &walletTxn.ID
+	order.SettledAt = &now
+
+	updates := map[string]interface{}{
+		"status":                    order.Status,
+		"settlement_transaction_id": order.SettlementTransactionID,
+		"settled_at":                order.SettledAt,
+	}
+	for column, value := range extra {
+		updates[column] = value
+	}
+	if err := s.repo.UpdateListingOrder(tx, order.ID, updates); err != nil {
+		return err
+	}
+
+	_, err = s.repo.UpdateListing(tx, order.ListingID, "reserved", map[string]interface{}{"status": listingStatus})
+	return err
+}
+
+// reverseListingPayment keeps listing orders in step with reversed wallet
+// transactions. Reversing the escrow debit of an open order refunds it; a
+// settled order cannot be reversed, its dispute resolution decides instead.
+// It reports whether the transaction belonged to a listing order.
+func (s *MarketplaceService) reverseListingPayment(tx *gorm.DB, original *wallet.WalletTransaction) (bool, error) {
+	order, err := s.repo.LockListingOrderByTransaction(tx, original.ID)
+	if err != nil || order == nil {
+		return false, err
+	}
+	if order.Status != "escrowed" && order.Status != "disputed" {
+		return true, fmt.Errorf("listing order %d is already %s and cannot be reversed", order.ID, order.Status)
+	}
+
+	// The reversal already moved the points from escrow back to the buyer
+	now := time.Now()
+	err = s.repo.UpdateListingOrder(tx, order.ID, map[string]interface{}{
+		"status":          "refunded",
+		"settled_at":      now,
+		"resolution_note": "Payment reversed",
+	})
+	if err != nil {
+		return true, err
+	}
+	_, err = s.repo.UpdateListing(tx, order.ListingID, "reserved", map[string]interface{}{"status": "active"})
+	return true, err
+}
+
+func (s *MarketplaceService) findOwnListing(listingID, sellerID uint) (*Listing, error) {
+	listing, err := s.repo.FindListing(nil, listingID)
+	if err != nil {
+		return nil, err
+	}
+	if listing.SellerID != sellerID {
+		return nil, errors.New("listing not found")
+	}
+	return listing, nil
+}
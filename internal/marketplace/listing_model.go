@@ -0,0 +1,109 @@
+package marketplace
+
+import (
+	"time"
+)
+
+// EscrowTimeout is how long a buyer has to confirm receipt of a listing. An
+// order neither confirmed nor disputed by then is refunded.
+const EscrowTimeout = 7 * 24 * time.Hour
+
+// Listing is a single item a student sells to other students, such as a used
+// book. Unlike a Product it has no stock: it is sold once.
+type Listing struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	SellerID    uint      `json:"seller_id" gorm:"not null;index"`
+	Title       string    `json:"title" gorm:"size:255;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	Price       int       `json:"price" gorm:"not null"`
+	ImageURL    string    `json:"image_url" gorm:"size:500"`
+	Status      string    `json:"status" gorm:"type:enum('active','reserved','sold','withdrawn');default:'active';index"` // Reserved while a purchase sits in escrow
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Read from the users table when listing, never written
+	SellerName string `json:"seller_name,omitempty" gorm:"->;-:migration"`
+}
+
+func (Listing) TableName() string {
+	return "marketplace_listings"
+}
+
+// ListingOrder is the purchase of a listing. The buyer's points wait in the
+// marketplace escrow account until the buyer confirms receipt, which pays
+// the seller, or the order is refunded after a dispute or the timeout.
+type ListingOrder struct {
+	ID                      uint       `json:"id" gorm:"primaryKey"`
+	ListingID               uint       `json:"listing_id" gorm:"not null;index"`
+	BuyerID                 uint       `json:"buyer_id" gorm:"not null;index"`
+	SellerID                uint       `json:"seller_id" gorm:"not null;index"`
+	Amount                  int        `json:"amount" gorm:"not null"`
+	Status                  string     `json:"status" gorm:"type:enum('escrowed','disputed','released','refunded');default:'escrowed';index"`
+	EscrowTransactionID     *uint      `json:"escrow_transaction_id" gorm:"index"`     // Buyer's debit into escrow
+	SettlementTransactionID *uint      `json:"settlement_transaction_id" gorm:"index"` // Credit to the seller or back to the buyer
+	DisputeReason           string     `json:"dispute_reason,omitempty" gorm:"size:500"`
+	ResolvedBy              *uint      `json:"resolved_by,omitempty"`
+	ResolutionNote          string     `json:"resolution_note,omitempty" gorm:"size:255"`
+	ExpiresAt               time.Time  `json:"expires_at" gorm:"index"`
+	SettledAt               *time.Time `json:"settled_at"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at"`
+
+	// Read from the listings and users tables when listing, never written
+	ListingTitle string `json:"listing_title,omitempty" gorm:"->;-:migration"`
+	BuyerName    string `json:"buyer_name,omitempty" gorm:"->;-:migration"`
+	SellerName   string `json:"seller_name,omitempty" gorm:"->;-:migration"`
+}
+
+func (ListingOrder) TableName() string {
+	return "marketplace_listing_orders"
+}
+
+type CreateListingRequest struct {
+	Title       string `json:"title" binding:"required,max=255"`
+	Description string `json:"description"`
+	Price       int    `json:"price" binding:"required,gt=0"`
+	ImageURL    string `json:"image_url" binding:"max=500"`
+}
+
+type UpdateListingRequest struct {
+	Title       string `json:"title,omitempty" binding:"max=255"`
+	Description string `json:"description,omitempty"`
+	Price       int    `json:"price,omitempty" binding:"omitempty,gt=0"`
+	ImageURL    string `json:"image_url,omitempty" binding:"max=500"`
+}
+
+type BuyListingRequest struct {
+	PIN string `json:"pin" binding:"required"`
+}
+
+type DisputeListingOrderRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type ResolveListingOrderRequest struct {
+	Resolution string `json:"resolution" binding:"required,oneof=refund release"`
+	Note       string `json:"note" binding:"max=255"`
+}
+
+type ListingListParams struct {
+	Search   string
+	SellerID uint
+	Status   string
+	Page     int
+	Limit    int
+}
+
+type ListingListResponse struct {
+	Listings   []Listing `json:"listings"`
+	Total      int64     `json:"total"`
+	Page       int       `json:"page"`
+	Limit      int       `json:"limit"`
+	TotalPages int       `json:"total_pages"`
+}
+
+// EscrowSweepResult counts the orders refunded by one timeout run
+type EscrowSweepResult struct {
+	Refunded int `json:"refunded"`
+	Failed   int `json:"failed"`
+}
@@ -6,15 +6,21 @@ import (
 
 type Product struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
-	Name        string    `json:"name" gorm:"not null"`
-	Description string    `json:"description" gorm:"type:text"`
+	Name        string    `json:"name" gorm:"not null;index:idx_product_search,class:FULLTEXT"`
+	Description string    `json:"description" gorm:"type:text;index:idx_product_search,class:FULLTEXT"`
 	Price       int       `json:"price" gorm:"not null"`
 	Stock       int       `json:"stock" gorm:"default:0;not null"`
 	ImageURL    string    `json:"image_url" gorm:"size:500"`
 	Status      string    `json:"status" gorm:"type:enum('active','inactive');default:'active'"`
+	CategoryID  *uint     `json:"category_id" gorm:"index"`
 	CreatedBy   uint      `json:"created_by" gorm:"not null"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Units sold, read when listing products, never written
+	Sold int `json:"sold" gorm:"->;-:migration"`
+
+	Tags []string `json:"tags" gorm:"-"`
 }
 
 func (Product) TableName() string {
@@ -22,20 +28,21 @@ func (Product) TableName() string {
 }
 
 type MarketplaceTransaction struct {
-	ID            uint      `json:"id" gorm:"primaryKey"`
-	CheckoutID    string    `json:"checkout_id" gorm:"size:50;index"`
-	WalletID      uint      `json:"wallet_id" gorm:"not null;index"`
-	ProductID     uint      `json:"product_id" gorm:"not null;index"`
-	Amount        int       `json:"amount" gorm:"not null"`                           // Individual item price
-	TotalAmount   int       `json:"total_amount" gorm:"column:total_amount;not null"` // This fixes the DB constraint error
-	Quantity      int       `json:"quantity" gorm:"default:1;not null"`
-	StudentName   string    `json:"student_name" gorm:"size:255"`
-	StudentNPM    string    `json:"student_npm" gorm:"size:100"`
-	StudentMajor  string    `json:"student_major" gorm:"size:255"`
-	StudentBatch  string    `json:"student_batch" gorm:"size:50"`
-	PaymentMethod string    `json:"payment_method" gorm:"size:50;default:'wallet'"`
-	Status        string    `json:"status" gorm:"type:enum('success','failed');default:'success'"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID                  uint      `json:"id" gorm:"primaryKey"`
+	CheckoutID          string    `json:"checkout_id" gorm:"size:50;index"`
+	WalletID            uint      `json:"wallet_id" gorm:"not null;index"`
+	ProductID           uint      `json:"product_id" gorm:"not null;index"`
+	Amount              int       `json:"amount" gorm:"not null"`                           // Individual item price
+	TotalAmount         int       `json:"total_amount" gorm:"column:total_amount;not null"` // This fixes the DB constraint error
+	Quantity            int       `json:"quantity" gorm:"default:1;not null"`
+	StudentName         string    `json:"student_name" gorm:"size:255"`
+	StudentNPM          string    `json:"student_npm" gorm:"size:100"`
+	StudentMajor        string    `json:"student_major" gorm:"size:255"`
+	StudentBatch        string    `json:"student_batch" gorm:"size:50"`
+	PaymentMethod       string    `json:"payment_method" gorm:"size:50;default:'wallet'"`
+	Status              string    `json:"status" gorm:"type:enum('success','failed','refunded');default:'success'"`
+	WalletTransactionID *uint     `json:"wallet_transaction_id" gorm:"index"` // Debit that paid for this row
+	CreatedAt           time.Time `json:"created_at"`
 }
 
 type PurchaseRequest struct {
@@ -74,26 +81,38 @@ type MarketplaceTransactionWithDetails struct {
 }
 
 type CreateProductRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
-	Price       int    `json:"price" binding:"required,gt=0"`
-	Stock       int    `json:"stock" binding:"gte=0"`
-	ImageURL    string `json:"image_url"`
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Price       int      `json:"price" binding:"required,gt=0"`
+	Stock       int      `json:"stock" binding:"gte=0"`
+	ImageURL    string   `json:"image_url"`
+	CategoryID  *uint    `json:"category_id"`
+	Tags        []string `json:"tags"`
 }
 
 type UpdateProductRequest struct {
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	Price       int    `json:"price,omitempty" binding:"omitempty,gt=0"`
-	Stock       int    `json:"stock,omitempty" binding:"omitempty,gte=0"`
-	ImageURL    string `json:"image_url,omitempty"`
-	Status      string `json:"status,omitempty" binding:"omitempty,oneof=active inactive"`
+	Name        string    `json:"name,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Price       int       `json:"price,omitempty" binding:"omitempty,gt=0"`
+	Stock       int       `json:"stock,omitempty" binding:"omitempty,gte=0"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	Status      string    `json:"status,omitempty" binding:"omitempty,oneof=active inactive"`
+	CategoryID  *uint     `json:"category_id,omitempty"` // 0 removes the product from its category
+	Tags        *[]string `json:"tags,omitempty"`        // Replaces every tag when set
 }
 
 type ProductListParams struct {
-	Status string
-	Page   int
-	Limit  int
+	Status      string
+	Search      string   // Keywords matched against name and description
+	CategoryID  uint     // Includes the subcategories
+	CategoryIDs []uint   // Filled in from CategoryID by the service
+	Tags        []string // Products must carry every tag
+	MinPrice    int
+	MaxPrice    int
+	InStock     bool
+	Sort        string // newest, price_asc, price_desc or popular
+	Page        int
+	Limit       int
 }
 
 type ProductListResponse struct {
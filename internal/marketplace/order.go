@@ -0,0 +1,289 @@
+package marketplace
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"wallet-point/internal/wallet"
+	"wallet-point/utils"
+
+	"gorm.io/gorm"
+)
+
+// PickupQRVersion prefixes the signed payload of a pickup QR code
+const PickupQRVersion = "PU1"
+
+// pickupAlphabet leaves out characters that are easily misread at the counter
+const pickupAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// placeOrder opens the order for a paid purchase or checkout
+func (s *MarketplaceService) placeOrder(tx *gorm.DB, order *MarketplaceOrder) error {
+	code, err := newPickupCode()
+	if err != nil {
+		return err
+	}
+	order.Status = "placed"
+	order.PickupCode = code
+	if err := s.repo.CreateOrder(tx, order); err != nil {
+		return err
+	}
+	order.PickupQR = pickupQRPayload(code)
+	return nil
+}
+
+// GetMyOrders lists the orders of a student, newest first
+func (s *MarketplaceService) GetMyOrders(userID uint, status string) ([]MarketplaceOrder, error) {
+	orders, err := s.repo.GetOrders(splitStatuses(status), userID, false)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].PickupQR = pickupQRPayload(orders[i].PickupCode)
+	}
+	return orders, s.attachOrderItems(orders)
+}
+
+// GetMyOrder returns an order of a student with its items and pickup QR
+func (s *MarketplaceService) GetMyOrder(orderID, userID uint) (*MarketplaceOrder, error) {
+	order, err := s.repo.FindOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, errors.New("order not found")
+	}
+	order.PickupQR = pickupQRPayload(order.PickupCode)
+
+	orders := []MarketplaceOrder{*order}
+	if err := s.attachOrderItems(orders); err != nil {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// GetFulfilmentQueue lists orders for staff, oldest first. Without a status
+// it lists the orders still to be handed over. Pickup codes are left out so
+// an order can only be handed over to the student holding it.
+func (s *MarketplaceService) GetFulfilmentQueue(status string) ([]MarketplaceOrder, error) {
+	statuses := splitStatuses(status)
+	if len(statuses) == 0 {
+		statuses = []string{"placed", "ready"}
+	}
+
+	orders, err := s.repo.GetOrders(statuses, 0, true)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].PickupCode = ""
+	}
+	return orders, s.attachOrderItems(orders)
+}
+
+// MarkOrderReady tells the student a placed order can be collected
+func (s *MarketplaceService) MarkOrderReady(orderID uint) (*MarketplaceOrder, error) {
+	order, err := s.changeOrder(func(tx *gorm.DB) (*MarketplaceOrder, error) {
+		return s.repo.LockOrder(tx, orderID)
+	}, func(tx *gorm.DB, order *MarketplaceOrder) error {
+		if order.Status != "placed" {
+			return fmt.Errorf("order is already %s", strings.ReplaceAll(order.Status, "_", " "))
+		}
+
+		now := time.Now()
+		order.Status = "ready"
+		order.ReadyAt = &now
+		return s.repo.UpdateOrder(tx, order.ID, map[string]interface{}{
+			"status":   order.Status,
+			"ready_at": order.ReadyAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	order.PickupCode = ""
+	return order, nil
+}
+
+// PickUpOrder hands an order over to the student showing its pickup code or
+// QR. Orders can be handed over as soon as they are placed.
+func (s *MarketplaceService) PickUpOrder(code string, staffID uint) (*MarketplaceOrder, error) {
+	pickupCode, err := parsePickupCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.changeOrder(func(tx *gorm.DB) (*MarketplaceOrder, error) {
+		return s.repo.LockOrderByPickupCode(tx, pickupCode)
+	}, func(tx *gorm.DB, order *MarketplaceOrder) error {
+		if order.Status != "placed" && order.Status != "ready" {
+			return fmt.Errorf("order is already %s", strings.ReplaceAll(order.Status, "_", " "))
+		}
+
+		now := time.Now()
+		order.Status = "picked_up"
+		order.PickedUpAt = &now
+		order.HandedOverBy = &staffID
+		return s.repo.UpdateOrder(tx, order.ID, map[string]interface{}{
+			"status":         order.Status,
+			"picked_up_at":   order.PickedUpAt,
+			"handed_over_by": order.HandedOverBy,
+		})
+	})
+}
+
+// CancelOrder cancels an order that was not picked up yet. The payment is
+// reversed, which refunds the student and puts the items back in stock.
+// Students can only cancel their own orders before they are ready; staff can
+// cancel any order still waiting for pickup.
+func (s *MarketplaceService) CancelOrder(orderID, userID uint, role, reason string) (*MarketplaceOrder, error) {
+	order, err := s.changeOrder(func(tx *gorm.DB) (*MarketplaceOrder, error) {
+		return s.repo.LockOrder(tx, orderID)
+	}, func(tx *gorm.DB, order *MarketplaceOrder) error {
+		if role != "admin" {
+			if order.UserID != userID {
+				return errors.New("order not found")
+			}
+			if order.Status == "ready" {
+				return errors.New("order is ready for pickup and can only be cancelled by staff")
+			}
+		}
+		if order.Status != "placed" && order.Status != "ready" {
+			return fmt.Errorf("order is already %s", strings.ReplaceAll(order.Status, "_", " "))
+		}
+		if order.WalletTransactionID == nil {
+			return errors.New("order has no payment to refund")
+		}
+
+		if reason == "" {
+			reason = "Cancelled by " + role
+		}
+		_, err := s.walletService.ReverseTransactionWithTx(tx, *order.WalletTransactionID, "Order cancelled: "+reason, role)
+		if err != nil {
+			return err
+		}
+
+		// The reversal hook already marked the order cancelled
+		now := time.Now()
+		order.Status = "cancelled"
+		order.CancelledAt = &now
+		order.CancelledBy = &userID
+		order.CancelReason = reason
+		return s.repo.UpdateOrder(tx, order.ID, map[string]interface{}{
+			"cancelled_by":  order.CancelledBy,
+			"cancel_reason": order.CancelReason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if role == "admin" {
+		order.PickupCode = ""
+	}
+	return order, nil
+}
+
+// cancelReversedOrder keeps the order of a reversed payment in step: it is
+// cancelled, unless it was already picked up and the goods are gone.
+func (s *MarketplaceService) cancelReversedOrder(tx *gorm.DB, original *wallet.WalletTransaction) error {
+	order, err := s.repo.LockOrderByTransaction(tx, original.ID)
+	if err != nil || order == nil {
+		return err
+	}
+	if order.Status == "picked_up" {
+		return fmt.Errorf("order %s was already picked up and cannot be reversed", order.CheckoutID)
+	}
+	if order.Status == "cancelled" {
+		return nil
+	}
+
+	return s.repo.UpdateOrder(tx, order.ID, map[string]interface{}{
+		"status":        "cancelled",
+		"cancelled_at":  time.Now(),
+		"cancel_reason": "Payment reversed",
+	})
+}
+
+// changeOrder locks an order and applies change to it in one transaction
+func (s *MarketplaceService) changeOrder(lock func(tx *gorm.DB) (*MarketplaceOrder, error), change func(tx *gorm.DB, order *MarketplaceOrder) error) (*MarketplaceOrder, error) {
+	var order *MarketplaceOrder
+	err := s.walletService.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = lock(tx)
+		if err != nil {
+			return err
+		}
+		return change(tx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// attachOrderItems fills in the product lines of each order
+func (s *MarketplaceService) attachOrderItems(orders []MarketplaceOrder) error {
+	checkoutIDs := make([]string, 0, len(orders))
+	for _, order := range orders {
+		checkoutIDs = append(checkoutIDs, order.CheckoutID)
+	}
+
+	items, err := s.repo.GetOrderItems(checkoutIDs)
+	if err != nil {
+		return err
+	}
+
+	byCheckout := make(map[string][]OrderItem)
+	for _, item := range items {
+		byCheckout[item.CheckoutID] = append(byCheckout[item.CheckoutID], item)
+	}
+	for i := range orders {
+		orders[i].Items = byCheckout[orders[i].CheckoutID]
+	}
+	return nil
+}
+
+func newCheckoutID(userID uint) string {
+	return fmt.Sprintf("CK-%d-%d", userID, time.Now().UnixNano())
+}
+
+func newPickupCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = pickupAlphabet[int(b[i])%len(pickupAlphabet)]
+	}
+	return string(b), nil
+}
+
+// pickupQRPayload builds the signed payload shown in the pickup QR code
+func pickupQRPayload(code string) string {
+	signed := PickupQRVersion + "." + code
+	return signed + "." + utils.Sign(signed)
+}
+
+// parsePickupCode accepts a typed pickup code or a scanned pickup QR payload
+func parsePickupCode(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	parts := strings.Split(input, ".")
+	if len(parts) == 1 {
+		return strings.ToUpper(input), nil
+	}
+	if len(parts) != 3 || parts[0] != PickupQRVersion {
+		return "", errors.New("pickup QR is not recognised")
+	}
+	if !utils.VerifySignature(parts[0]+"."+parts[1], parts[2]) {
+		return "", errors.New("pickup QR signature is invalid")
+	}
+	return parts[1], nil
+}
+
+func splitStatuses(status string) []string {
+	if status == "" {
+		return nil
+	}
+	return strings.Split(status, ",")
+}
@@ -0,0 +1,59 @@
+package marketplace
+
+import (
+	"time"
+)
+
+// MarketplaceOrder groups the items bought in one purchase or checkout, by
+// CheckoutID, and tracks their collection: placed, ready for pickup, then
+// picked up by the student or cancelled and refunded.
+type MarketplaceOrder struct {
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	CheckoutID          string     `json:"checkout_id" gorm:"size:50;uniqueIndex;not null"`
+	UserID              uint       `json:"user_id" gorm:"not null;index"`
+	WalletID            uint       `json:"wallet_id" gorm:"not null"`
+	TotalAmount         int        `json:"total_amount" gorm:"not null"`
+	ItemCount           int        `json:"item_count" gorm:"not null"`
+	Status              string     `json:"status" gorm:"type:enum('placed','ready','picked_up','cancelled');default:'placed';index"`
+	PickupCode          string     `json:"pickup_code,omitempty" gorm:"size:20;uniqueIndex;not null"` // Shown to the buyer only, staff scan it at the counter
+	WalletTransactionID *uint      `json:"wallet_transaction_id" gorm:"index"`                        // Debit that paid for the order
+	ReadyAt             *time.Time `json:"ready_at"`
+	PickedUpAt          *time.Time `json:"picked_up_at"`
+	HandedOverBy        *uint      `json:"handed_over_by,omitempty"`
+	CancelledAt         *time.Time `json:"cancelled_at"`
+	CancelledBy         *uint      `json:"cancelled_by,omitempty"`
+	CancelReason        string     `json:"cancel_reason,omitempty" gorm:"size:255"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+
+	// Read from the users table when listing, never written
+	StudentName string `json:"student_name,omitempty" gorm:"->;-:migration"`
+	StudentNIM  string `json:"student_nim,omitempty" gorm:"->;-:migration"`
+
+	Items    []OrderItem `json:"items,omitempty" gorm:"-"`
+	PickupQR string      `json:"pickup_qr,omitempty" gorm:"-"` // Signed payload to render as the pickup QR code
+}
+
+func (MarketplaceOrder) TableName() string {
+	return "marketplace_orders"
+}
+
+// OrderItem is one product line of an order
+type OrderItem struct {
+	ID          uint   `json:"id"`
+	CheckoutID  string `json:"-"`
+	ProductID   uint   `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Amount      int    `json:"amount"`
+	Quantity    int    `json:"quantity"`
+	TotalAmount int    `json:"total_amount"`
+	Status      string `json:"status"`
+}
+
+type PickupOrderRequest struct {
+	Code string `json:"code" binding:"required"` // Pickup code or the scanned QR payload
+}
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
@@ -2,8 +2,12 @@ package marketplace
 
 import (
 	"errors"
+	"strings"
+	"time"
+	"unicode"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MarketplaceRepository struct {
@@ -23,7 +27,35 @@ func (r *MarketplaceRepository) GetAll(params ProductListParams) ([]Product, int
 
 	// Apply filters
 	if params.Status != "" {
-		query = query.Where("status = ?", params.Status)
+		query = query.Where("products.status = ?", params.Status)
+	}
+	if params.Search != "" {
+		if terms := fullTextTerms(params.Search); terms != "" {
+			query = query.Where("MATCH(products.name, products.description) AGAINST (? IN BOOLEAN MODE)", terms)
+		} else {
+			// Words shorter than the full-text minimum are not indexed
+			query = query.Where("products.name LIKE ?", "%"+params.Search+"%")
+		}
+	}
+	if len(params.CategoryIDs) > 0 {
+		query = query.Where("products.category_id IN ?", params.CategoryIDs)
+	}
+	if len(params.Tags) > 0 {
+		tagged := r.db.Model(&ProductTag{}).
+			Select("product_id").
+			Where("tag IN ?", params.Tags).
+			Group("product_id").
+			Having("COUNT(DISTINCT tag) = ?", len(params.Tags))
+		query = query.Where("products.id IN (?)", tagged)
+	}
+	if params.MinPrice > 0 {
+		query = query.Where("products.price >= ?", params.MinPrice)
+	}
+	if params.MaxPrice > 0 {
+		query = query.Where("products.price <= ?", params.MaxPrice)
+	}
+	if params.InStock {
+		query = query.Where("products.stock > 0")
 	}
 
 	// Count total
@@ -31,9 +63,27 @@ func (r *MarketplaceRepository) GetAll(params ProductListParams) ([]Product, int
 		return nil, 0, err
 	}
 
+	// Units sold drive the popular ordering
+	sold := r.db.Model(&MarketplaceTransaction{}).
+		Select("product_id, SUM(quantity) AS sold").
+		Where("status = ?", "success").
+		Group("product_id")
+	query = query.Select("products.*, COALESCE(sales.sold, 0) AS sold").
+		Joins("LEFT JOIN (?) sales ON sales.product_id = products.id", sold)
+
+	order := "products.created_at DESC, products.id DESC"
+	switch params.Sort {
+	case "price_asc":
+		order = "products.price ASC, products.id DESC"
+	case "price_desc":
+		order = "products.price DESC, products.id DESC"
+	case "popular":
+		order = "sold DESC, products.created_at DESC"
+	}
+
 	// Apply pagination
 	offset := (params.Page - 1) * params.Limit
-	query = query.Limit(params.Limit).Offset(offset).Order("created_at DESC")
+	query = query.Limit(params.Limit).Offset(offset).Order(order)
 
 	if err := query.Find(&products).Error; err != nil {
 		return nil, 0, err
@@ -42,6 +92,24 @@ func (r *MarketplaceRepository) GetAll(params ProductListParams) ([]Product, int
 	return products, total, nil
 }
 
+// fullTextTerms turns keywords into a boolean full-text query matching every
+// word as a prefix. Operators typed by the user are dropped.
+func fullTextTerms(search string) string {
+	var terms []string
+	for _, word := range strings.Fields(search) {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, word)
+		if len([]rune(word)) >= 3 {
+			terms = append(terms, "+"+word+"*")
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
 // FindByID finds product by ID
 func (r *MarketplaceRepository) FindByID(productID uint) (*Product, error) {
 	var product Product
@@ -81,6 +149,23 @@ func (r *MarketplaceRepository) UpdateStock(tx *gorm.DB, productID uint, delta i
 		Error
 }
 
+// DecreaseStock reduces product stock only if enough units are left
+func (r *MarketplaceRepository) DecreaseStock(tx *gorm.DB, productID uint, quantity int) error {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Model(&Product{}).
+		Where("id = ? AND stock >= ?", productID, quantity).
+		Update("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("product out of stock")
+	}
+	return nil
+}
+
 func (r *MarketplaceRepository) AddToCart(item *CartItem) error {
 	var existing CartItem
 	err := r.db.Where("user_id = ? AND product_id = ?", item.UserID, item.ProductID).First(&existing).Error
@@ -128,6 +213,24 @@ func (r *MarketplaceRepository) CreateMarketplaceTransaction(tx *gorm.DB, txn *M
 	return tx.Create(txn).Error
 }
 
+// FindByWalletTransaction returns the sale rows paid for by a wallet debit
+func (r *MarketplaceRepository) FindByWalletTransaction(tx *gorm.DB, walletTxnID uint) ([]MarketplaceTransaction, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var txns []MarketplaceTransaction
+	err := tx.Where("wallet_transaction_id = ? AND status = ?", walletTxnID, "success").Find(&txns).Error
+	return txns, err
+}
+
+// UpdateTransactionStatus updates the status of marketplace sale rows
+func (r *MarketplaceRepository) UpdateTransactionStatus(tx *gorm.DB, ids []uint, status string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&MarketplaceTransaction{}).Where("id IN ?", ids).Update("status", status).Error
+}
+
 // GetTransactions with details for admin monitoring
 func (r *MarketplaceRepository) GetTransactions(limit, page int) ([]MarketplaceTransactionWithDetails, int64, error) {
 	var txns []MarketplaceTransactionWithDetails
@@ -147,3 +250,338 @@ func (r *MarketplaceRepository) GetTransactions(limit, page int) ([]MarketplaceT
 	err := query.Order("t.created_at DESC").Limit(limit).Offset(offset).Find(&txns).Error
 	return txns, total, err
 }
+
+// CreateListing saves a student listing
+func (r *MarketplaceRepository) CreateListing(listing *Listing) error {
+	return r.db.Create(listing).Error
+}
+
+// FindListing finds a student listing by ID with its seller
+func (r *MarketplaceRepository) FindListing(tx *gorm.DB, listingID uint) (*Listing, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var listing Listing
+	err := tx.Table("marketplace_listings").
+		Select("marketplace_listings.*, users.full_name as seller_name").
+		Joins("LEFT JOIN users ON users.id = marketplace_listings.seller_id").
+		Where("marketplace_listings.id = ?", listingID).
+		Take(&listing).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("listing not found")
+		}
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// GetListings lists student listings with filters and pagination, newest first
+func (r *MarketplaceRepository) GetListings(params ListingListParams) ([]Listing, int64, error) {
+	var listings []Listing
+	var total int64
+
+	query := r.db.Table("marketplace_listings").
+		Select("marketplace_listings.*, users.full_name as seller_name").
+		Joins("LEFT JOIN users ON users.id = marketplace_listings.seller_id")
+
+	if params.Status != "" {
+		query = query.Where("marketplace_listings.status = ?", params.Status)
+	}
+	if params.SellerID != 0 {
+		query = query.Where("marketplace_listings.seller_id = ?", params.SellerID)
+	}
+	if params.Search != "" {
+		search := "%" + params.Search + "%"
+		query = query.Where("(marketplace_listings.title LIKE ? OR marketplace_listings.description LIKE ?)", search, search)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.Limit
+	err := query.Order("marketplace_listings.created_at DESC, marketplace_listings.id DESC").
+		Limit(params.Limit).Offset(offset).Scan(&listings).Error
+	return listings, total, err
+}
+
+// UpdateListing updates a listing if it still has the expected status. It
+// reports whether the listing was updated.
+func (r *MarketplaceRepository) UpdateListing(tx *gorm.DB, listingID uint, fromStatus string, updates map[string]interface{}) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Model(&Listing{}).Where("id = ? AND status = ?", listingID, fromStatus).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateListingOrder saves the purchase of a listing
+func (r *MarketplaceRepository) CreateListingOrder(tx *gorm.DB, order *ListingOrder) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(order).Error
+}
+
+// LockListingOrder finds a listing order and locks it until the transaction ends
+func (r *MarketplaceRepository) LockListingOrder(tx *gorm.DB, orderID uint) (*ListingOrder, error) {
+	var order ListingOrder
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("listing order not found")
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// LockListingOrderByTransaction finds and locks the listing order a wallet
+// transaction paid into or out of escrow, nil if there is none
+func (r *MarketplaceRepository) LockListingOrderByTransaction(tx *gorm.DB, walletTxnID uint) (*ListingOrder, error) {
+	var order ListingOrder
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("escrow_transaction_id = ? OR settlement_transaction_id = ?", walletTxnID, walletTxnID).
+		First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// UpdateListingOrder updates a listing order
+func (r *MarketplaceRepository) UpdateListingOrder(tx *gorm.DB, orderID uint, updates map[string]interface{}) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&ListingOrder{}).Where("id = ?", orderID).Updates(updates).Error
+}
+
+// GetListingOrders lists listing orders with their listing and both users.
+// A non-zero buyerID or sellerID limits them to one side, newest first.
+func (r *MarketplaceRepository) GetListingOrders(status string, buyerID, sellerID uint) ([]ListingOrder, error) {
+	var orders []ListingOrder
+	query := r.db.Table("marketplace_listing_orders o").
+		Select("o.*, l.title as listing_title, buyer.full_name as buyer_name, seller.full_name as seller_name").
+		Joins("LEFT JOIN marketplace_listings l ON l.id = o.listing_id").
+		Joins("LEFT JOIN users buyer ON buyer.id = o.buyer_id").
+		Joins("LEFT JOIN users seller ON seller.id = o.seller_id")
+
+	if status != "" {
+		query = query.Where("o.status = ?", status)
+	}
+	if buyerID != 0 {
+		query = query.Where("o.buyer_id = ?", buyerID)
+	}
+	if sellerID != 0 {
+		query = query.Where("o.seller_id = ?", sellerID)
+	}
+
+	err := query.Order("o.created_at DESC, o.id DESC").Limit(200).Scan(&orders).Error
+	return orders, err
+}
+
+// FindExpiredListingOrders returns the escrowed orders past their timeout
+func (r *MarketplaceRepository) FindExpiredListingOrders(now time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&ListingOrder{}).
+		Where("status = ? AND expires_at <= ?", "escrowed", now).
+		Order("expires_at ASC").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *MarketplaceRepository) CreateOrder(tx *gorm.DB, order *MarketplaceOrder) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(order).Error
+}
+
+func (r *MarketplaceRepository) FindOrder(orderID uint) (*MarketplaceOrder, error) {
+	var order MarketplaceOrder
+	err := r.db.Table("marketplace_orders o").
+		Select("o.*, u.full_name as student_name, u.nim_nip as student_nim").
+		Joins("LEFT JOIN users u ON u.id = o.user_id").
+		Where("o.id = ?", orderID).
+		Take(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *MarketplaceRepository) LockOrder(tx *gorm.DB, orderID uint) (*MarketplaceOrder, error) {
+	var order MarketplaceOrder
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *MarketplaceRepository) LockOrderByPickupCode(tx *gorm.DB, code string) (*MarketplaceOrder, error) {
+	var order MarketplaceOrder
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("pickup_code = ?", code).First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// LockOrderByTransaction returns the order paid by a wallet transaction, or
+// nil if the transaction did not pay for an order
+func (r *MarketplaceRepository) LockOrderByTransaction(tx *gorm.DB, walletTxnID uint) (*MarketplaceOrder, error) {
+	var orders []MarketplaceOrder
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("wallet_transaction_id = ?", walletTxnID).
+		Limit(1).Find(&orders).Error
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+func (r *MarketplaceRepository) UpdateOrder(tx *gorm.DB, orderID uint, updates map[string]interface{}) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&MarketplaceOrder{}).Where("id = ?", orderID).Updates(updates).Error
+}
+
+// GetOrders lists orders in the given statuses, of one student when userID is set
+func (r *MarketplaceRepository) GetOrders(statuses []string, userID uint, oldestFirst bool) ([]MarketplaceOrder, error) {
+	var orders []MarketplaceOrder
+	query := r.db.Table("marketplace_orders o").
+		Select("o.*, u.full_name as student_name, u.nim_nip as student_nim").
+		Joins("LEFT JOIN users u ON u.id = o.user_id")
+
+	if len(statuses) > 0 {
+		query = query.Where("o.status IN ?", statuses)
+	}
+	if userID != 0 {
+		query = query.Where("o.user_id = ?", userID)
+	}
+
+	order := "o.created_at DESC, o.id DESC"
+	if oldestFirst {
+		order = "o.created_at ASC, o.id ASC"
+	}
+	err := query.Order(order).Limit(200).Scan(&orders).Error
+	return orders, err
+}
+
+// GetOrderItems lists the product lines of the given checkouts
+func (r *MarketplaceRepository) GetOrderItems(checkoutIDs []string) ([]OrderItem, error) {
+	var items []OrderItem
+	if len(checkoutIDs) == 0 {
+		return items, nil
+	}
+	err := r.db.Table("marketplace_transactions mt").
+		Select("mt.id, mt.checkout_id, mt.product_id, p.name as product_name, mt.amount, mt.quantity, mt.total_amount, mt.status").
+		Joins("LEFT JOIN products p ON p.id = mt.product_id").
+		Where("mt.checkout_id IN ?", checkoutIDs).
+		Order("mt.id ASC").
+		Scan(&items).Error
+	return items, err
+}
+
+func (r *MarketplaceRepository) GetCategories() ([]ProductCategory, error) {
+	var categories []ProductCategory
+	err := r.db.Order("name ASC").Find(&categories).Error
+	return categories, err
+}
+
+func (r *MarketplaceRepository) FindCategory(categoryID uint) (*ProductCategory, error) {
+	var category ProductCategory
+	err := r.db.First(&category, categoryID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("category not found")
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *MarketplaceRepository) CreateCategory(category *ProductCategory) error {
+	return r.db.Create(category).Error
+}
+
+func (r *MarketplaceRepository) UpdateCategory(categoryID uint, updates map[string]interface{}) error {
+	return r.db.Model(&ProductCategory{}).Where("id = ?", categoryID).Updates(updates).Error
+}
+
+// DeleteCategory removes a category and moves its products up to its parent
+func (r *MarketplaceRepository) DeleteCategory(category *ProductCategory) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Product{}).
+			Where("category_id = ?", category.ID).
+			Update("category_id", category.ParentID).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&ProductCategory{}, category.ID).Error
+	})
+}
+
+// GetProductTags returns the tags of the given products by product ID
+func (r *MarketplaceRepository) GetProductTags(productIDs []uint) (map[uint][]string, error) {
+	tags := make(map[uint][]string)
+	if len(productIDs) == 0 {
+		return tags, nil
+	}
+
+	var rows []ProductTag
+	err := r.db.Where("product_id IN ?", productIDs).Order("tag ASC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.ProductID] = append(tags[row.ProductID], row.Tag)
+	}
+	return tags, nil
+}
+
+// SetProductTags replaces every tag of a product
+func (r *MarketplaceRepository) SetProductTags(productID uint, tags []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&ProductTag{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		rows := make([]ProductTag, 0, len(tags))
+		for _, tag := range tags {
+			rows = append(rows, ProductTag{ProductID: productID, Tag: tag})
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// GetTagCounts lists the tags of active products, most used first
+func (r *MarketplaceRepository) GetTagCounts() ([]TagCount, error) {
+	var counts []TagCount
+	err := r.db.Table("product_tags t").
+		Select("t.tag, COUNT(*) AS products").
+		Joins("JOIN products p ON p.id = t.product_id").
+		Where("p.status = ?", "active").
+		Group("t.tag").
+		Order("products DESC, t.tag ASC").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,25 @@
+package marketplace
+
+import "testing"
+
+func TestFullTextTerms(t *testing.T) {
+	tests := []struct {
+		search string
+		want   string
+	}{
+		{"kaos", "+kaos*"},
+		{"kaos  polos hitam", "+kaos* +polos* +hitam*"},
+		{"go pen", "+pen*"},
+		{"-kaos +\"polos\" hitam*", "+kaos* +polos* +hitam*"},
+		{"buku-tulis", "+bukutulis*"},
+		{"", ""},
+		{"@@ ~ ()", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.search, func(t *testing.T) {
+			if got := fullTextTerms(tt.search); got != tt.want {
+				t.Errorf("fullTextTerms(%q) = %q, want %q", tt.search, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"time"
+	"slices"
+	"strings"
 	"wallet-point/internal/auth"
+	"wallet-point/internal/limit"
 	"wallet-point/internal/wallet"
 
 	"gorm.io/gorm"
@@ -15,6 +17,7 @@ type MarketplaceService struct {
 	repo          *MarketplaceRepository
 	walletService *wallet.WalletService
 	authService   *auth.AuthService
+	limitService  *limit.LimitService
 	db            *gorm.DB
 }
 
@@ -36,11 +39,40 @@ func (s *MarketplaceService) GetAllProducts(params ProductListParams) (*ProductL
 	if params.Limit < 1 {
 		params.Limit = 20
 	}
+	if params.Sort == "" {
+		params.Sort = "newest"
+	}
+	if !slices.Contains(ProductSorts, params.Sort) {
+		return nil, fmt.Errorf("%w: sort must be one of %s", ErrInvalidProductFilter, strings.Join(ProductSorts, ", "))
+	}
+	if params.MinPrice > 0 && params.MaxPrice > 0 && params.MinPrice > params.MaxPrice {
+		return nil, fmt.Errorf("%w: min_price cannot be greater than max_price", ErrInvalidProductFilter)
+	}
+	params.Search = strings.TrimSpace(params.Search)
+
+	// A category lists the products of its subcategories too
+	if params.CategoryID != 0 {
+		ids, err := s.categoryAndDescendants(params.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		params.CategoryIDs = ids
+	}
+	if len(params.Tags) > 0 {
+		tags, err := normalizeTags(params.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProductFilter, err)
+		}
+		params.Tags = tags
+	}
 
 	products, total, err := s.repo.GetAll(params)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.attachTags(products); err != nil {
+		return nil, err
+	}
 
 	totalPages := int(math.Ceil(float64(total) / float64(params.Limit)))
 
@@ -55,11 +87,23 @@ func (s *MarketplaceService) GetAllProducts(params ProductListParams) (*ProductL
 
 // GetProductByID gets product by ID
 func (s *MarketplaceService) GetProductByID(productID uint) (*Product, error) {
-	return s.repo.FindByID(productID)
+	product, err := s.repo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withTags(product)
 }
 
 // CreateProduct creates a new product
 func (s *MarketplaceService) CreateProduct(req *CreateProductRequest, adminID uint) (*Product, error) {
+	if err := s.checkCategory(req.CategoryID); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
 	product := &Product{
 		Name:        req.Name,
 		Description: req.Description,
@@ -67,13 +111,18 @@ func (s *MarketplaceService) CreateProduct(req *CreateProductRequest, adminID ui
 		Stock:       req.Stock,
 		ImageURL:    req.ImageURL,
 		Status:      "active",
+		CategoryID:  normalizeParent(req.CategoryID),
 		CreatedBy:   adminID,
 	}
 
 	if err := s.repo.Create(product); err != nil {
 		return nil, errors.New("failed to create product")
 	}
+	if err := s.repo.SetProductTags(product.ID, tags); err != nil {
+		return nil, errors.New("failed to save product tags")
+	}
 
+	product.Tags = tags
 	return product, nil
 }
 
@@ -83,6 +132,15 @@ func (s *MarketplaceService) UpdateProduct(productID uint, req *UpdateProductReq
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkCategory(req.CategoryID); err != nil {
+		return nil, err
+	}
+	var tags []string
+	if req.Tags != nil {
+		if tags, err = normalizeTags(*req.Tags); err != nil {
+			return nil, err
+		}
+	}
 
 	updates := make(map[string]interface{})
 	if req.Name != "" {
@@ -103,14 +161,34 @@ func (s *MarketplaceService) UpdateProduct(productID uint, req *UpdateProductReq
 	if req.Status != "" {
 		updates["status"] = req.Status
 	}
+	if req.CategoryID != nil {
+		updates["category_id"] = normalizeParent(req.CategoryID)
+	}
 
 	if len(updates) > 0 {
 		if err := s.repo.Update(productID, updates); err != nil {
 			return nil, errors.New("failed to update product")
 		}
 	}
+	if req.Tags != nil {
+		if err := s.repo.SetProductTags(productID, tags); err != nil {
+			return nil, errors.New("failed to save product tags")
+		}
+	}
+
+	product, err := s.repo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withTags(product)
+}
 
-	return s.repo.FindByID(productID)
+func (s *MarketplaceService) withTags(product *Product) (*Product, error) {
+	products := []Product{*product}
+	if err := s.attachTags(products); err != nil {
+		return nil, err
+	}
+	return &products[0], nil
 }
 
 // DeleteProduct deletes product
@@ -122,30 +200,34 @@ func (s *MarketplaceService) DeleteProduct(productID uint) error {
 	return s.repo.Delete(productID)
 }
 
-// PurchaseProduct handles product purchase without a dedicated marketplace_transactions table
-func (s *MarketplaceService) PurchaseProduct(userID uint, req *PurchaseRequest) error {
+func (s *MarketplaceService) SetLimitService(limitService *limit.LimitService) {
+	s.limitService = limitService
+}
+
+// PurchaseProduct buys a product and places an order for its pickup
+func (s *MarketplaceService) PurchaseProduct(userID uint, req *PurchaseRequest) (*MarketplaceOrder, error) {
 	// 1. Verify PIN if using direct wallet
 	if req.PaymentMethod == "wallet" || req.PaymentMethod == "" {
 		if err := s.authService.VerifyPIN(userID, req.PIN); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	product, err := s.repo.FindByID(req.ProductID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if product.Status == "inactive" {
-		return errors.New("product is not active")
+		return nil, errors.New("product is not active")
 	}
 	if product.Stock < 1 {
-		return errors.New("product out of stock")
+		return nil, errors.New("product out of stock")
 	}
 
 	studentWallet, err := s.walletService.GetWalletByUserID(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	quantity := req.Quantity
@@ -154,44 +236,63 @@ func (s *MarketplaceService) PurchaseProduct(userID uint, req *PurchaseRequest)
 	}
 	totalPrice := product.Price * quantity
 
-	if studentWallet.Balance < totalPrice {
-		return fmt.Errorf("insufficient balance. Required: %d", totalPrice)
+	if studentWallet.AvailableBalance < totalPrice {
+		return nil, fmt.Errorf("%w. Required: %d", wallet.ErrInsufficientBalance, totalPrice)
 	}
 
-	err = s.db.Transaction(func(tx *gorm.DB) error {
+	order := &MarketplaceOrder{
+		CheckoutID:  newCheckoutID(userID),
+		UserID:      userID,
+		WalletID:    studentWallet.ID,
+		TotalAmount: totalPrice,
+		ItemCount:   quantity,
+	}
+
+	err = s.walletService.Transaction(func(tx *gorm.DB) error {
 		// 1. Debit Student Wallet
 		desc := fmt.Sprintf("Purchase: %dx %s", quantity, product.Name)
-		if err := s.walletService.DebitWithTransaction(tx, studentWallet.ID, totalPrice, "marketplace", desc); err != nil {
+		walletTxn, err := s.walletService.DebitWithTransaction(tx, studentWallet.ID, totalPrice, "marketplace", desc)
+		if err != nil {
+			return err
+		}
+		if err := s.checkSpendLimit(tx, userID, studentWallet.ID, totalPrice); err != nil {
 			return err
 		}
 
 		// 2. Reduce Stock
-		if err := s.repo.UpdateStock(tx, product.ID, -quantity); err != nil {
+		if err := s.repo.DecreaseStock(tx, product.ID, quantity); err != nil {
 			return err
 		}
 
 		// 3. Record in Marketplace Transactions
 		txn := &MarketplaceTransaction{
-			WalletID:      studentWallet.ID,
-			ProductID:     product.ID,
-			Amount:        product.Price,
-			TotalAmount:   totalPrice,
-			Quantity:      quantity,
-			StudentName:   req.StudentName,
-			StudentNPM:    req.StudentNPM,
-			StudentMajor:  req.StudentMajor,
-			StudentBatch:  req.StudentBatch,
-			PaymentMethod: "wallet",
-			Status:        "success",
+			CheckoutID:          order.CheckoutID,
+			WalletID:            studentWallet.ID,
+			ProductID:           product.ID,
+			Amount:              product.Price,
+			TotalAmount:         totalPrice,
+			Quantity:            quantity,
+			StudentName:         req.StudentName,
+			StudentNPM:          req.StudentNPM,
+			StudentMajor:        req.StudentMajor,
+			StudentBatch:        req.StudentBatch,
+			PaymentMethod:       "wallet",
+			Status:              "success",
+			WalletTransactionID: &walletTxn.ID,
 		}
 		if err := s.repo.CreateMarketplaceTransaction(tx, txn); err != nil {
 			return err
 		}
 
-		return nil
+		// 4. Place the order for pickup
+		order.WalletTransactionID = &walletTxn.ID
+		return s.placeOrder(tx, order)
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	return order, nil
 }
 
 // GetTransactions retrieves all marketplace transactions from consolidated wallet_transactions (Admin)
@@ -249,54 +350,69 @@ func (s *MarketplaceService) RemoveFromCart(userID, itemID uint) error {
 	return s.repo.RemoveFromCart(userID, itemID)
 }
 
-func (s *MarketplaceService) Checkout(userID uint, req CartCheckoutRequest) error {
+// Checkout buys every item in the cart with one debit and places a single
+// order for their pickup
+func (s *MarketplaceService) Checkout(userID uint, req CartCheckoutRequest) (*MarketplaceOrder, error) {
 	// 1. Verify PIN
 	if err := s.authService.VerifyPIN(userID, req.PIN); err != nil {
-		return err
+		return nil, err
 	}
 
 	// 2. Get Cart Items
 	items, err := s.repo.GetCart(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(items) == 0 {
-		return errors.New("keranjang belanja kosong")
+		return nil, errors.New("keranjang belanja kosong")
 	}
 
 	// 3. Calculate total and check stock
 	totalPrice := 0
+	itemCount := 0
 	for _, item := range items {
 		if item.Product == nil {
-			return fmt.Errorf("produk dengan ID %d tidak ditemukan", item.ProductID)
+			return nil, fmt.Errorf("produk dengan ID %d tidak ditemukan", item.ProductID)
 		}
 		if item.Product.Stock < item.Quantity {
-			return fmt.Errorf("stok produk '%s' tidak mencukupi", item.Product.Name)
+			return nil, fmt.Errorf("stok produk '%s' tidak mencukupi", item.Product.Name)
 		}
 		totalPrice += item.Product.Price * item.Quantity
+		itemCount += item.Quantity
 	}
 
 	// 4. Check balance
-	wallet, err := s.walletService.GetWalletByUserID(userID)
+	studentWallet, err := s.walletService.GetWalletByUserID(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if wallet.Balance < totalPrice {
-		return fmt.Errorf("saldo tidak cukup. Total: %d, Saldo: %d", totalPrice, wallet.Balance)
+	if studentWallet.AvailableBalance < totalPrice {
+		return nil, fmt.Errorf("%w. Total: %d, Saldo: %d", wallet.ErrInsufficientBalance, totalPrice, studentWallet.AvailableBalance)
+	}
+
+	order := &MarketplaceOrder{
+		CheckoutID:  newCheckoutID(userID),
+		UserID:      userID,
+		WalletID:    studentWallet.ID,
+		TotalAmount: totalPrice,
+		ItemCount:   itemCount,
 	}
 
 	// 5. Execute Transaction
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	err = s.walletService.Transaction(func(tx *gorm.DB) error {
 		// Single wallet debit for the entire checkout
-		checkoutID := fmt.Sprintf("CK-%d-%d", userID, time.Now().Unix())
 		checkoutDesc := fmt.Sprintf("Checkout: %d item(s)", len(items))
-		if err := s.walletService.DebitWithTransaction(tx, wallet.ID, totalPrice, "marketplace", checkoutDesc); err != nil {
+		walletTxn, err := s.walletService.DebitWithTransaction(tx, studentWallet.ID, totalPrice, "marketplace", checkoutDesc)
+		if err != nil {
+			return err
+		}
+		if err := s.checkSpendLimit(tx, userID, studentWallet.ID, totalPrice); err != nil {
 			return err
 		}
 
 		for _, item := range items {
 			// Reduce stock
-			if err := s.repo.UpdateStock(tx, item.ProductID, -item.Quantity); err != nil {
+			if err := s.repo.DecreaseStock(tx, item.ProductID, item.Quantity); err != nil {
 				return err
 			}
 
@@ -307,14 +423,15 @@ func (s *MarketplaceService) Checkout(userID uint, req CartCheckoutRequest) erro
 			}
 
 			txn := &MarketplaceTransaction{
-				CheckoutID:    checkoutID,
-				WalletID:      wallet.ID,
-				ProductID:     item.ProductID,
-				Amount:        amount,
-				TotalAmount:   amount * item.Quantity,
-				Quantity:      item.Quantity,
-				PaymentMethod: "wallet",
-				Status:        "success",
+				CheckoutID:          order.CheckoutID,
+				WalletID:            studentWallet.ID,
+				ProductID:           item.ProductID,
+				Amount:              amount,
+				TotalAmount:         amount * item.Quantity,
+				Quantity:            item.Quantity,
+				PaymentMethod:       "wallet",
+				Status:              "success",
+				WalletTransactionID: &walletTxn.ID,
 			}
 			if err := s.repo.CreateMarketplaceTransaction(tx, txn); err != nil {
 				return err
@@ -322,6 +439,65 @@ func (s *MarketplaceService) Checkout(userID uint, req CartCheckoutRequest) erro
 		}
 
 		// Clear cart
-		return s.repo.ClearCart(tx, userID)
+		if err := s.repo.ClearCart(tx, userID); err != nil {
+			return err
+		}
+
+		order.WalletTransactionID = &walletTxn.ID
+		return s.placeOrder(tx, order)
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// checkSpendLimit enforces the buyer's marketplace limits once the debit is posted
+func (s *MarketplaceService) checkSpendLimit(tx *gorm.DB, userID, walletID uint, amount int) error {
+	if s.limitService == nil {
+		return nil
+	}
+	return s.limitService.CheckMarketplace(tx, userID, walletID, amount)
+}
+
+// HandleReversal puts reversed purchases back in stock, cancels their order
+// and refunds reversed listing orders. It is registered as a wallet reversal
+// hook for marketplace transactions.
+func (s *MarketplaceService) HandleReversal(tx *gorm.DB, original *wallet.WalletTransaction) error {
+	// Student listings are paid through escrow and settled by their order
+	if handled, err := s.reverseListingPayment(tx, original); handled || err != nil {
+		return err
+	}
+
+	// Only the buyer's debit carries the sale, the seller's credit of a QR purchase does not
+	if original.Direction != "debit" {
+		return nil
+	}
+
+	sales, err := s.repo.FindByWalletTransaction(tx, original.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(sales) == 0 {
+		// QR purchases have no sale rows, one unit of the referenced product was sold
+		if original.ReferenceID != nil {
+			return s.repo.UpdateStock(tx, *original.ReferenceID, 1)
+		}
+		return nil
+	}
+
+	if err := s.cancelReversedOrder(tx, original); err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(sales))
+	for _, sale := range sales {
+		if err := s.repo.UpdateStock(tx, sale.ProductID, sale.Quantity); err != nil {
+			return err
+		}
+		ids = append(ids, sale.ID)
+	}
+	return s.repo.UpdateTransactionStatus(tx, ids, "refunded")
 }
@@ -227,7 +227,7 @@ func (s *MissionService) SubmitMission(req *SubmitMissionRequest, studentID uint
 		submission.Content = string(answersBytes)
 
 		// Use transaction to save submission and reward points
-		err = s.db.Transaction(func(tx *gorm.DB) error {
+		err = s.walletService.Transaction(func(tx *gorm.DB) error {
 			if err := tx.Create(submission).Error; err != nil {
 				return err
 			}
@@ -243,7 +243,7 @@ func (s *MissionService) SubmitMission(req *SubmitMissionRequest, studentID uint
 
 			if pointsReward > 0 {
 				desc := fmt.Sprintf("Kuis: %s (Skor: %d)", mission.Title, score)
-				err = s.walletService.ProcessMissionRewardWithTx(tx, studentID, pointsReward, desc, mission.ID, 0) // 0 for system/automatic
+				err = s.walletService.ProcessMissionRewardWithTx(tx, studentID, pointsReward, desc, mission.ID, mission.CreatorID, 0) // 0 for system/automatic
 				if err != nil {
 					return err
 				}
@@ -282,7 +282,7 @@ func (s *MissionService) ReviewSubmission(submissionID uint, req *ReviewSubmissi
 	}
 
 	// Start a transaction for the review and potential wallet reward
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	return s.walletService.Transaction(func(tx *gorm.DB) error {
 		updates := map[string]interface{}{
 			"status":          req.Status,
 			"score":           req.Score,
@@ -304,7 +304,7 @@ func (s *MissionService) ReviewSubmission(submissionID uint, req *ReviewSubmissi
 
 			// for now we'll assume it handles its own internal transaction if needed,
 			// though nested transactions in GORM/MySQL are safe.
-			err = s.walletService.ProcessMissionRewardWithTx(tx, submission.StudentID, mission.Points, mission.Title, mission.ID, reviewerID)
+			err = s.walletService.ProcessMissionRewardWithTx(tx, submission.StudentID, mission.Points, mission.Title, mission.ID, mission.CreatorID, reviewerID)
 			if err != nil {
 				return err
 			}
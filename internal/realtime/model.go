@@ -0,0 +1,20 @@
+package realtime
+
+import "time"
+
+// Event types pushed to subscribers
+const (
+	EventBalanceUpdated  = "balance.updated"
+	EventTokenConsumed   = "token.consumed"
+	EventTokenAuthorized = "token.authorized"
+	EventTokenVoided     = "token.voided"
+	EventTokenExpired    = "token.expired"
+	EventTokenPaid       = "token.paid" // A static token received a payment
+)
+
+// Event is a message pushed to the connected clients of a user
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	At   time.Time   `json:"at"`
+}
@@ -0,0 +1,61 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a slow client may fall behind before
+// new events to it are dropped
+const subscriberBuffer = 16
+
+// Broker is an in-process pub/sub delivering events to the clients of a
+// user. It only reaches clients connected to the same instance.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[uint]map[chan Event]struct{})}
+}
+
+// Subscribe registers a client of a user. The returned function must be
+// called when the client goes away.
+func (b *Broker) Subscribe(userID uint) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userID], ch)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends an event to every client of a user without blocking
+func (b *Broker) Publish(userID uint, eventType string, data interface{}) {
+	event := Event{Type: eventType, Data: data, At: time.Now()}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
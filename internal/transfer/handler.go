@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strconv"
 	"wallet-point/internal/audit"
+	"wallet-point/internal/fraud"
+	"wallet-point/internal/limit"
 	"wallet-point/utils"
 
 	"github.com/gin-gonic/gin"
@@ -35,8 +37,23 @@ func (h *Handler) CreateTransfer(c *gin.Context) {
 		return
 	}
 
+	if req.ReceiverUserID == 0 {
+		recipient, err := h.service.ResolveRecipient(req.Recipient)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		req.ReceiverUserID = recipient.ID
+	}
+
 	transfer, err := h.service.CreateTransfer(senderUserID.(uint), req.ReceiverUserID, req.Amount, req.Description, req.PIN)
 	if err != nil {
+		if limit.RespondIfLimitError(c, err) {
+			return
+		}
+		if fraud.RespondIfFraudError(c, err) {
+			return
+		}
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
@@ -55,27 +72,84 @@ func (h *Handler) CreateTransfer(c *gin.Context) {
 	})
 }
 
-// GetMyTransfers handles GET /transfer/history
-func (h *Handler) GetMyTransfers(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+// QuoteTransfer handles POST /transfer/quote
+func (h *Handler) QuoteTransfer(c *gin.Context) {
+	userID := c.GetUint("user_id")
 
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if page < 1 {
-		page = 1
+	var req TransferQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
 	}
 
-	transfers, total, err := h.service.GetUserTransfers(userID.(uint), limit, page)
+	quote, err := h.service.QuoteTransfer(userID, &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		statusCode := http.StatusBadRequest
+		if err.Error() == "recipient not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Check the recipient and confirm the transfer", quote)
+}
+
+// ConfirmTransfer handles POST /transfer/confirm
+func (h *Handler) ConfirmTransfer(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req ConfirmTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	transfer, quote, err := h.service.ConfirmTransfer(userID, &req)
+	if err != nil {
+		if limit.RespondIfLimitError(c, err) {
+			return
+		}
+		if fraud.RespondIfFraudError(c, err) {
+			return
+		}
+		statusCode := http.StatusBadRequest
+		if err.Error() == "transfer quote not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transfer completed successfully", gin.H{
+		"transfer": transfer,
+	})
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "TRANSFER_POINTS",
+		Entity:    "WALLET_TRANSACTION",
+		Details:   fmt.Sprintf("Transferred %d points to %s (%s, user %d) via quote", quote.Amount, quote.ReceiverName, quote.Identifier, quote.ReceiverID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetMyTransfers handles GET /transfer/history
+func (h *Handler) GetMyTransfers(c *gin.Context) {
+	params := transferListParams(c)
+
+	transfers, total, err := h.service.GetUserTransfers(c.GetUint("user_id"), params)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Transfer history retrieved successfully", gin.H{
 		"transfers": transfers,
 		"total":     total,
-		"limit":     limit,
-		"page":      page,
+		"limit":     params.Limit,
+		"page":      params.Page,
 	})
 }
 
@@ -97,21 +171,317 @@ func (h *Handler) GetRecipientInfo(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Recipient found", recipient)
 }
 
-// GetAllTransfers handles GET /admin/transfers
+// GetAllTransfers handles GET /admin/transfers. user_id narrows the list to
+// one user's transfers, which direction and the counterparty filters are
+// then relative to.
 func (h *Handler) GetAllTransfers(c *gin.Context) {
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	params := transferListParams(c)
+	if userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32); err == nil {
+		params.UserID = uint(userID)
+	}
 
-	transfers, total, err := h.service.GetAllTransfers(limit, page)
+	transfers, total, err := h.service.GetAllTransfers(params)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "All transfers retrieved", gin.H{
 		"transfers": transfers,
 		"total":     total,
-		"limit":     limit,
-		"page":      page,
+		"limit":     params.Limit,
+		"page":      params.Page,
 	})
 }
+
+// transferListParams reads the history filters shared by the student and
+// admin transfer lists
+func transferListParams(c *gin.Context) TransferListParams {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	counterpartyID, _ := strconv.ParseUint(c.Query("counterparty_id"), 10, 32)
+	minAmount, _ := strconv.Atoi(c.Query("min_amount"))
+	maxAmount, _ := strconv.Atoi(c.Query("max_amount"))
+
+	return TransferListParams{
+		Direction:      c.Query("direction"),
+		CounterpartyID: uint(counterpartyID),
+		Counterparty:   c.Query("counterparty"),
+		FromDate:       c.Query("from_date"),
+		ToDate:         c.Query("to_date"),
+		MinAmount:      minAmount,
+		MaxAmount:      maxAmount,
+		Page:           page,
+		Limit:          limit,
+	}
+}
+
+// CreatePaymentRequest handles POST /transfer/requests
+func (h *Handler) CreatePaymentRequest(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req CreatePaymentRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	request, err := h.service.CreatePaymentRequest(userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Payment request sent successfully", request)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "CREATE_PAYMENT_REQUEST",
+		Entity:    "PAYMENT_REQUEST",
+		EntityID:  request.ID,
+		Details:   fmt.Sprintf("Requested %d points from user %d", req.Amount, req.PayerUserID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetPaymentRequests handles GET /transfer/requests
+func (h *Handler) GetPaymentRequests(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	requests, total, err := h.service.GetPaymentRequests(userID, c.Query("direction"), c.Query("status"), limit, page)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment requests retrieved successfully", gin.H{
+		"requests": requests,
+		"total":    total,
+		"limit":    limit,
+		"page":     page,
+	})
+}
+
+// AcceptPaymentRequest handles POST /transfer/requests/:id/accept
+func (h *Handler) AcceptPaymentRequest(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid ID format", nil)
+		return
+	}
+
+	var req AcceptPaymentRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	request, err := h.service.AcceptPaymentRequest(uint(requestID), userID, req.PIN)
+	if err != nil {
+		if limit.RespondIfLimitError(c, err) {
+			return
+		}
+		if fraud.RespondIfFraudError(c, err) {
+			return
+		}
+		respondRequestError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment request paid successfully", request)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "ACCEPT_PAYMENT_REQUEST",
+		Entity:    "PAYMENT_REQUEST",
+		EntityID:  request.ID,
+		Details:   fmt.Sprintf("Paid %d points to user %d", request.Amount, request.RequesterID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// DeclinePaymentRequest handles POST /transfer/requests/:id/decline
+func (h *Handler) DeclinePaymentRequest(c *gin.Context) {
+	h.closePaymentRequest(c, "DECLINE_PAYMENT_REQUEST", "Payment request declined", h.service.DeclinePaymentRequest)
+}
+
+// CancelPaymentRequest handles POST /transfer/requests/:id/cancel
+func (h *Handler) CancelPaymentRequest(c *gin.Context) {
+	h.closePaymentRequest(c, "CANCEL_PAYMENT_REQUEST", "Payment request cancelled", h.service.CancelPaymentRequest)
+}
+
+func (h *Handler) closePaymentRequest(c *gin.Context, action, message string, closeRequest func(requestID, userID uint) (*PaymentRequest, error)) {
+	userID := c.GetUint("user_id")
+
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid ID format", nil)
+		return
+	}
+
+	request, err := closeRequest(uint(requestID), userID)
+	if err != nil {
+		respondRequestError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, message, request)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    action,
+		Entity:    "PAYMENT_REQUEST",
+		EntityID:  request.ID,
+		Details:   fmt.Sprintf("Payment request of %d points %s", request.Amount, request.Status),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+func respondRequestError(c *gin.Context, err error) {
+	statusCode := http.StatusBadRequest
+	if err.Error() == "payment request not found" {
+		statusCode = http.StatusNotFound
+	}
+	utils.ErrorResponse(c, statusCode, err.Error(), nil)
+}
+
+// ScheduleTransfer handles POST /transfer/schedules
+func (h *Handler) ScheduleTransfer(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req ScheduleTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	schedule, err := h.service.ScheduleTransfer(userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Transfer scheduled successfully", schedule)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "SCHEDULE_TRANSFER",
+		Entity:    "SCHEDULED_TRANSFER",
+		EntityID:  schedule.ID,
+		Details:   fmt.Sprintf("Scheduled %s transfer of %d points to user %d", req.Interval, req.Amount, req.ReceiverUserID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetScheduledTransfers handles GET /transfer/schedules
+func (h *Handler) GetScheduledTransfers(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	schedules, err := h.service.GetScheduledTransfers(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled transfers retrieved successfully", schedules)
+}
+
+// GetScheduleRuns handles GET /transfer/schedules/:id/runs
+func (h *Handler) GetScheduleRuns(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	scheduleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid ID format", nil)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	runs, total, err := h.service.GetScheduleRuns(uint(scheduleID), userID, limit, page)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled transfer runs retrieved successfully", gin.H{
+		"runs":  runs,
+		"total": total,
+		"limit": limit,
+		"page":  page,
+	})
+}
+
+// PauseScheduledTransfer handles POST /transfer/schedules/:id/pause
+func (h *Handler) PauseScheduledTransfer(c *gin.Context) {
+	h.changeSchedule(c, "PAUSE_SCHEDULED_TRANSFER", "Scheduled transfer paused", h.service.PauseScheduledTransfer)
+}
+
+// ResumeScheduledTransfer handles POST /transfer/schedules/:id/resume
+func (h *Handler) ResumeScheduledTransfer(c *gin.Context) {
+	h.changeSchedule(c, "RESUME_SCHEDULED_TRANSFER", "Scheduled transfer resumed", h.service.ResumeScheduledTransfer)
+}
+
+// CancelScheduledTransfer handles POST /transfer/schedules/:id/cancel
+func (h *Handler) CancelScheduledTransfer(c *gin.Context) {
+	h.changeSchedule(c, "CANCEL_SCHEDULED_TRANSFER", "Scheduled transfer cancelled", h.service.CancelScheduledTransfer)
+}
+
+func (h *Handler) changeSchedule(c *gin.Context, action, message string, change func(scheduleID, userID uint) (*ScheduledTransfer, error)) {
+	userID := c.GetUint("user_id")
+
+	scheduleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid ID format", nil)
+		return
+	}
+
+	schedule, err := change(uint(scheduleID), userID)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, message, schedule)
+
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    action,
+		Entity:    "SCHEDULED_TRANSFER",
+		EntityID:  schedule.ID,
+		Details:   fmt.Sprintf("Scheduled transfer to user %d is now %s", schedule.ReceiverID, schedule.Status),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+func respondScheduleError(c *gin.Context, err error) {
+	statusCode := http.StatusBadRequest
+	if err.Error() == "scheduled transfer not found" {
+		statusCode = http.StatusNotFound
+	}
+	utils.ErrorResponse(c, statusCode, err.Error(), nil)
+}
@@ -14,11 +14,23 @@ type Transfer struct {
 	Status      string    `json:"status" gorm:"type:enum('success','failed');default:'success'"`
 	CreatedAt   time.Time `json:"created_at"`
 
-	// Virtual fields for response
-	SenderName   string `json:"sender_name,omitempty" gorm:"-"`
-	ReceiverName string `json:"receiver_name,omitempty" gorm:"-"`
-	SenderNIM    string `json:"sender_nim,omitempty" gorm:"-"`
-	ReceiverNIM  string `json:"receiver_nim,omitempty" gorm:"-"`
+	// The wallet transactions posted for the transfer, which carry its ID
+	// as their reference
+	OutTransactionID *uint `json:"out_transaction_id,omitempty" gorm:"index"`
+	InTransactionID  *uint `json:"in_transaction_id,omitempty" gorm:"index"`
+
+	// Read from the users table when listing, never written
+	SenderName   string `json:"sender_name,omitempty" gorm:"->;-:migration"`
+	ReceiverName string `json:"receiver_name,omitempty" gorm:"->;-:migration"`
+	SenderNIM    string `json:"sender_nim,omitempty" gorm:"->;-:migration"`
+	ReceiverNIM  string `json:"receiver_nim,omitempty" gorm:"->;-:migration"`
+
+	// Set in a user's own history: whether they sent or received the
+	// transfer and who was on the other side
+	Direction        string `json:"direction,omitempty" gorm:"-"`
+	CounterpartyID   uint   `json:"counterparty_id,omitempty" gorm:"-"`
+	CounterpartyName string `json:"counterparty_name,omitempty" gorm:"-"`
+	CounterpartyNIM  string `json:"counterparty_nim,omitempty" gorm:"-"`
 }
 
 func (Transfer) TableName() string {
@@ -28,9 +40,28 @@ func (Transfer) TableName() string {
 // TransferInfo is kept as a DTO for compatibility if needed, or simply use Transfer
 type TransferInfo = Transfer
 
-// TransferRequest represents the request body for creating a transfer
+// TransferListParams filters transfer history. A non-zero UserID limits it
+// to the transfers the user sent or received, and Direction ("sent" or
+// "received") to one of the two. Counterparty matches the name or NIM of
+// the other side, or of either side when no user is given.
+type TransferListParams struct {
+	UserID         uint
+	Direction      string
+	CounterpartyID uint
+	Counterparty   string
+	FromDate       string
+	ToDate         string
+	MinAmount      int
+	MaxAmount      int
+	Page           int
+	Limit          int
+}
+
+// TransferRequest represents the request body for creating a transfer. The
+// receiver is given by user ID or by NIM, email or @handle.
 type TransferRequest struct {
-	ReceiverUserID uint   `json:"receiver_user_id" binding:"required"`
+	ReceiverUserID uint   `json:"receiver_user_id" binding:"required_without=Recipient"`
+	Recipient      string `json:"recipient"`
 	Amount         int    `json:"amount" binding:"required,gt=0"`
 	Description    string `json:"description" binding:"max=255"`
 	PIN            string `json:"pin"`
@@ -42,4 +73,8 @@ type RecipientSummary struct {
 	FullName string `json:"full_name"`
 	Role     string `json:"role"`
 	NIM      string `json:"nim,omitempty"`
+	Handle   string `json:"handle,omitempty"`
 }
+
+// recipientColumns selects a RecipientSummary from the users table
+const recipientColumns = "id, full_name, role, nim_nip as nim, COALESCE(handle, '') as handle"
@@ -0,0 +1,162 @@
+package transfer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ResolveRecipient finds the active user a NIM, email or @handle refers to.
+// A bare handle without the @ is tried after the NIM.
+func (s *Service) ResolveRecipient(identifier string) (*RecipientSummary, error) {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return nil, errors.New("recipient is required")
+	}
+
+	var lookups []func(*gorm.DB) *gorm.DB
+	switch {
+	case strings.HasPrefix(identifier, "@"):
+		lookups = append(lookups, byColumn("handle", strings.ToLower(identifier[1:])))
+	case strings.Contains(identifier, "@"):
+		lookups = append(lookups, byColumn("email", identifier))
+	default:
+		lookups = append(lookups, byColumn("nim_nip", identifier), byColumn("handle", strings.ToLower(identifier)))
+	}
+
+	for _, lookup := range lookups {
+		var ids []uint
+		err := s.db.Table("users").Scopes(lookup).Where("status = ?", "active").Limit(1).Pluck("id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) > 0 {
+			return s.FindRecipient(ids[0])
+		}
+	}
+	return nil, errors.New("recipient not found")
+}
+
+func byColumn(column, value string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(column+" = ?", value)
+	}
+}
+
+// QuoteTransfer resolves the recipient of a transfer and returns who it is
+// together with a short-lived confirmation ID. No points move until the
+// sender confirms the quote with their PIN.
+func (s *Service) QuoteTransfer(senderUserID uint, req *TransferQuoteRequest) (*TransferQuoteResponse, error) {
+	recipient, err := s.ResolveRecipient(req.Recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail early on what would fail at confirmation anyway
+	if _, _, err := s.transferWallets(senderUserID, recipient.ID, req.Amount); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.New("failed to generate confirmation ID")
+	}
+
+	quote := &TransferQuote{
+		ConfirmationID: hex.EncodeToString(b),
+		SenderID:       senderUserID,
+		ReceiverID:     recipient.ID,
+		Identifier:     strings.TrimSpace(req.Recipient),
+		ReceiverName:   recipient.FullName,
+		ReceiverRole:   recipient.Role,
+		ReceiverNIM:    recipient.NIM,
+		Amount:         req.Amount,
+		Description:    req.Description,
+		ExpiresAt:      time.Now().Add(QuoteLifetime).Truncate(time.Second),
+	}
+	if err := s.db.Create(quote).Error; err != nil {
+		return nil, err
+	}
+
+	return &TransferQuoteResponse{
+		ConfirmationID: quote.ConfirmationID,
+		Recipient:      recipient,
+		Amount:         quote.Amount,
+		Description:    quote.Description,
+		ExpiresAt:      quote.ExpiresAt,
+	}, nil
+}
+
+// ConfirmTransfer executes a quoted transfer. A quote is used at most once
+// and only by the sender who asked for it.
+func (s *Service) ConfirmTransfer(senderUserID uint, req *ConfirmTransferRequest) (*Transfer, *TransferQuote, error) {
+	quote, err := s.findQuote(s.db, req.ConfirmationID, senderUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkQuote(quote); err != nil {
+		return nil, nil, err
+	}
+
+	senderWallet, receiverWallet, err := s.prepareTransfer(senderUserID, quote.ReceiverID, quote.Amount, req.PIN)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var record *Transfer
+	err = s.walletService.Transaction(func(tx *gorm.DB) error {
+		// Re-read under lock so two confirmations cannot both go through
+		quote, err = s.findQuote(tx.Clauses(clause.Locking{Strength: "UPDATE"}), req.ConfirmationID, senderUserID)
+		if err != nil {
+			return err
+		}
+		if err := checkQuote(quote); err != nil {
+			return err
+		}
+
+		record, err = s.transferWithTx(tx, senderWallet, receiverWallet, quote.Amount, quote.Description)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		quote.ConfirmedAt = &now
+		quote.TransferID = &record.ID
+		return tx.Model(&TransferQuote{}).Where("id = ?", quote.ID).Updates(map[string]interface{}{
+			"confirmed_at": quote.ConfirmedAt,
+			"transfer_id":  quote.TransferID,
+		}).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return record, quote, nil
+}
+
+func (s *Service) findQuote(tx *gorm.DB, confirmationID string, senderUserID uint) (*TransferQuote, error) {
+	var quote TransferQuote
+	err := tx.Where("confirmation_id = ? AND sender_id = ?", confirmationID, senderUserID).First(&quote).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("transfer quote not found")
+		}
+		return nil, err
+	}
+	return &quote, nil
+}
+
+func checkQuote(quote *TransferQuote) error {
+	if quote.ConfirmedAt != nil {
+		return errors.New("transfer quote has already been confirmed")
+	}
+	if !quote.ExpiresAt.After(time.Now()) {
+		return errors.New("transfer quote has expired, request a new one")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package transfer
+
+import "time"
+
+// QuoteLifetime is how long a transfer quote can be confirmed
+const QuoteLifetime = 5 * time.Minute
+
+// TransferQuote holds a transfer the sender has looked up but not yet
+// confirmed, with a snapshot of the recipient they were shown
+type TransferQuote struct {
+	ID             uint       `json:"-" gorm:"primaryKey"`
+	ConfirmationID string     `json:"confirmation_id" gorm:"type:varchar(32);uniqueIndex;not null"`
+	SenderID       uint       `json:"sender_id" gorm:"not null;index"`
+	ReceiverID     uint       `json:"receiver_id" gorm:"not null"`
+	Identifier     string     `json:"identifier" gorm:"size:191"` // What the sender typed: NIM, email or @handle
+	ReceiverName   string     `json:"receiver_name" gorm:"size:255"`
+	ReceiverRole   string     `json:"receiver_role" gorm:"size:20"`
+	ReceiverNIM    string     `json:"receiver_nim" gorm:"size:191"`
+	Amount         int        `json:"amount" gorm:"not null"`
+	Description    string     `json:"description" gorm:"size:255"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null"`
+	ConfirmedAt    *time.Time `json:"confirmed_at"`
+	TransferID     *uint      `json:"transfer_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (TransferQuote) TableName() string {
+	return "transfer_quotes"
+}
+
+// TransferQuoteRequest represents the request body for quoting a transfer
+type TransferQuoteRequest struct {
+	Recipient   string `json:"recipient" binding:"required"` // NIM, email or @handle
+	Amount      int    `json:"amount" binding:"required,gt=0"`
+	Description string `json:"description" binding:"max=255"`
+}
+
+// ConfirmTransferRequest represents the request body for confirming a quote
+type ConfirmTransferRequest struct {
+	ConfirmationID string `json:"confirmation_id" binding:"required"`
+	PIN            string `json:"pin"`
+}
+
+// TransferQuoteResponse is what the sender checks before confirming
+type TransferQuoteResponse struct {
+	ConfirmationID string            `json:"confirmation_id"`
+	Recipient      *RecipientSummary `json:"recipient"`
+	Amount         int               `json:"amount"`
+	Description    string            `json:"description"`
+	ExpiresAt      time.Time         `json:"expires_at"`
+}
@@ -0,0 +1,233 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreatePaymentRequest asks another user to send points to the requester
+func (s *Service) CreatePaymentRequest(requesterID uint, req *CreatePaymentRequestRequest) (*PaymentRequest, error) {
+	if requesterID == req.PayerUserID {
+		return nil, errors.New("cannot request points from yourself")
+	}
+
+	payer, err := s.FindRecipient(req.PayerUserID)
+	if err != nil {
+		return nil, err
+	}
+	requester, err := s.FindRecipient(requesterID)
+	if err != nil {
+		return nil, err
+	}
+
+	hours := req.ExpiresInHours
+	if hours == 0 {
+		hours = DefaultRequestExpiryHours
+	}
+
+	request := &PaymentRequest{
+		RequesterID: requesterID,
+		PayerID:     req.PayerUserID,
+		Amount:      req.Amount,
+		Note:        req.Note,
+		Status:      "pending",
+		ExpiresAt:   time.Now().Add(time.Duration(hours) * time.Hour),
+	}
+	if err := s.db.Create(request).Error; err != nil {
+		return nil, err
+	}
+
+	request.Requester = requester
+	request.Payer = payer
+	return request, nil
+}
+
+// AcceptPaymentRequest pays a request through the same path as CreateTransfer
+func (s *Service) AcceptPaymentRequest(requestID, payerID uint, pin string) (*PaymentRequest, error) {
+	request, err := s.findPaymentRequest(s.db, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.PayerID != payerID {
+		return nil, errors.New("payment request not found")
+	}
+	if err := checkRequestOpen(request); err != nil {
+		return nil, err
+	}
+
+	payerWallet, requesterWallet, err := s.prepareTransfer(payerID, request.RequesterID, request.Amount, pin)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.walletService.Transaction(func(tx *gorm.DB) error {
+		// Lock the request so it can only be paid once
+		locked, err := s.findPaymentRequest(tx.Clauses(clause.Locking{Strength: "UPDATE"}), requestID)
+		if err != nil {
+			return err
+		}
+		if err := checkRequestOpen(locked); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Payment request #%d", request.ID)
+		if request.Note != "" {
+			description += ": " + request.Note
+		}
+		record, err := s.transferWithTx(tx, payerWallet, requesterWallet, request.Amount, description)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		request.Status = "accepted"
+		request.TransferID = &record.ID
+		request.RespondedAt = &now
+		return tx.Model(&PaymentRequest{}).Where("id = ?", request.ID).Updates(map[string]interface{}{
+			"status":       request.Status,
+			"transfer_id":  record.ID,
+			"responded_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The summaries are only for display, the request is paid either way
+	s.attachSummaries([]*PaymentRequest{request})
+	return request, nil
+}
+
+// DeclinePaymentRequest lets the payer turn a request down
+func (s *Service) DeclinePaymentRequest(requestID, payerID uint) (*PaymentRequest, error) {
+	return s.closePaymentRequest(requestID, "declined", func(r *PaymentRequest) bool { return r.PayerID == payerID })
+}
+
+// CancelPaymentRequest lets the requester withdraw a request
+func (s *Service) CancelPaymentRequest(requestID, requesterID uint) (*PaymentRequest, error) {
+	return s.closePaymentRequest(requestID, "cancelled", func(r *PaymentRequest) bool { return r.RequesterID == requesterID })
+}
+
+// GetPaymentRequests lists the requests a user sent ("outgoing"), received
+// ("incoming") or both
+func (s *Service) GetPaymentRequests(userID uint, direction, status string, limit, page int) ([]PaymentRequest, int64, error) {
+	// Requests past their expiry are closed before listing
+	s.db.Model(&PaymentRequest{}).
+		Where("status = ? AND expires_at < ?", "pending", time.Now()).
+		Update("status", "expired")
+
+	query := s.db.Model(&PaymentRequest{})
+	switch direction {
+	case "incoming":
+		query = query.Where("payer_id = ?", userID)
+	case "outgoing":
+		query = query.Where("requester_id = ?", userID)
+	default:
+		query = query.Where("payer_id = ? OR requester_id = ?", userID, userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var requests []PaymentRequest
+	err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&requests).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	refs := make([]*PaymentRequest, len(requests))
+	for i := range requests {
+		refs[i] = &requests[i]
+	}
+	return requests, total, s.attachSummaries(refs)
+}
+
+func (s *Service) closePaymentRequest(requestID uint, status string, allowed func(*PaymentRequest) bool) (*PaymentRequest, error) {
+	request, err := s.findPaymentRequest(s.db, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed(request) {
+		return nil, errors.New("payment request not found")
+	}
+	if err := checkRequestOpen(request); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := s.db.Model(&PaymentRequest{}).
+		Where("id = ? AND status = ?", request.ID, "pending").
+		Updates(map[string]interface{}{"status": status, "responded_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("payment request is no longer pending")
+	}
+
+	request.Status = status
+	request.RespondedAt = &now
+	s.attachSummaries([]*PaymentRequest{request})
+	return request, nil
+}
+
+func (s *Service) findPaymentRequest(tx *gorm.DB, requestID uint) (*PaymentRequest, error) {
+	var request PaymentRequest
+	if err := tx.First(&request, requestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("payment request not found")
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+func checkRequestOpen(request *PaymentRequest) error {
+	if request.Status != "pending" {
+		return errors.New("payment request is no longer pending")
+	}
+	if time.Now().After(request.ExpiresAt) {
+		return errors.New("payment request has expired")
+	}
+	return nil
+}
+
+// attachSummaries fills in the requester and payer of each request
+func (s *Service) attachSummaries(requests []*PaymentRequest) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(requests)*2)
+	for _, r := range requests {
+		ids = append(ids, r.RequesterID, r.PayerID)
+	}
+
+	var summaries []RecipientSummary
+	err := s.db.Table("users").
+		Select(recipientColumns).
+		Where("id IN ?", ids).
+		Scan(&summaries).Error
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[uint]*RecipientSummary, len(summaries))
+	for i := range summaries {
+		byID[summaries[i].ID] = &summaries[i]
+	}
+	for _, r := range requests {
+		r.Requester = byID[r.RequesterID]
+		r.Payer = byID[r.PayerID]
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package transfer
+
+import "time"
+
+const (
+	// DefaultRequestExpiryHours is how long a payment request stays open
+	// unless the requester picks another expiry
+	DefaultRequestExpiryHours = 72
+)
+
+// PaymentRequest asks another student to send points. Accepting it runs a
+// regular transfer from the payer to the requester.
+type PaymentRequest struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	RequesterID uint       `json:"requester_id" gorm:"not null;index"`
+	PayerID     uint       `json:"payer_id" gorm:"not null;index"`
+	Amount      int        `json:"amount" gorm:"not null"`
+	Note        string     `json:"note" gorm:"size:255"`
+	Status      string     `json:"status" gorm:"type:enum('pending','accepted','declined','cancelled','expired');default:'pending';index"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null;index"`
+	TransferID  *uint      `json:"transfer_id"` // Set once accepted
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Virtual fields for response
+	Requester *RecipientSummary `json:"requester,omitempty" gorm:"-"`
+	Payer     *RecipientSummary `json:"payer,omitempty" gorm:"-"`
+}
+
+func (PaymentRequest) TableName() string {
+	return "payment_requests"
+}
+
+// CreatePaymentRequestRequest represents the request body for asking points
+type CreatePaymentRequestRequest struct {
+	PayerUserID    uint   `json:"payer_user_id" binding:"required"`
+	Amount         int    `json:"amount" binding:"required,gt=0"`
+	Note           string `json:"note" binding:"max=255"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,gt=0,lte=720"`
+}
+
+// AcceptPaymentRequestRequest represents the request body for paying a request
+type AcceptPaymentRequestRequest struct {
+	PIN string `json:"pin"`
+}
@@ -0,0 +1,325 @@
+package transfer
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errScheduleNotDue means another worker already ran or changed a schedule
+var errScheduleNotDue = errors.New("scheduled transfer is not due")
+
+// ScheduleTransfer registers a one-off or recurring transfer. The PIN is
+// checked once here, the runs are made by the background worker.
+func (s *Service) ScheduleTransfer(senderUserID uint, req *ScheduleTransferRequest) (*ScheduledTransfer, error) {
+	if err := s.authService.VerifyPIN(senderUserID, req.PIN); err != nil {
+		return nil, err
+	}
+	if senderUserID == req.ReceiverUserID {
+		return nil, errors.New("cannot transfer points to yourself")
+	}
+	if req.StartAt.Before(time.Now().Add(-time.Minute)) {
+		return nil, errors.New("start_at must be in the future")
+	}
+	if req.EndAt != nil && req.EndAt.Before(req.StartAt) {
+		return nil, errors.New("end_at must be after start_at")
+	}
+
+	receiver, err := s.FindRecipient(req.ReceiverUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &ScheduledTransfer{
+		SenderID:    senderUserID,
+		ReceiverID:  req.ReceiverUserID,
+		Amount:      req.Amount,
+		Description: req.Description,
+		Interval:    req.Interval,
+		NextRunAt:   req.StartAt,
+		EndAt:       req.EndAt,
+		MaxRuns:     req.MaxRuns,
+		Status:      "active",
+	}
+	if req.Interval == "once" {
+		schedule.EndAt, schedule.MaxRuns = nil, nil
+	}
+
+	if err := s.db.Create(schedule).Error; err != nil {
+		return nil, err
+	}
+
+	schedule.Receiver = receiver
+	return schedule, nil
+}
+
+// GetScheduledTransfers lists the scheduled transfers of a sender
+func (s *Service) GetScheduledTransfers(senderUserID uint) ([]ScheduledTransfer, error) {
+	var schedules []ScheduledTransfer
+	if err := s.db.Where("sender_id = ?", senderUserID).Order("created_at DESC").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range schedules {
+		if receiver, err := s.FindRecipient(schedules[i].ReceiverID); err == nil {
+			schedules[i].Receiver = receiver
+		}
+	}
+	return schedules, nil
+}
+
+// GetScheduleRuns lists the run history of a scheduled transfer
+func (s *Service) GetScheduleRuns(scheduleID, senderUserID uint, limit, page int) ([]ScheduledTransferRun, int64, error) {
+	if _, err := s.findSchedule(s.db, scheduleID, senderUserID); err != nil {
+		return nil, 0, err
+	}
+
+	var runs []ScheduledTransferRun
+	var total int64
+	query := s.db.Model(&ScheduledTransferRun{}).Where("schedule_id = ?", scheduleID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&runs).Error
+	return runs, total, err
+}
+
+// PauseScheduledTransfer stops the runs of a schedule until it is resumed
+func (s *Service) PauseScheduledTransfer(scheduleID, senderUserID uint) (*ScheduledTransfer, error) {
+	return s.changeScheduleStatus(scheduleID, senderUserID, []string{"active"}, "paused")
+}
+
+// ResumeScheduledTransfer restarts a paused schedule. Runs missed while it
+// was paused are skipped.
+func (s *Service) ResumeScheduledTransfer(scheduleID, senderUserID uint) (*ScheduledTransfer, error) {
+	return s.changeScheduleStatus(scheduleID, senderUserID, []string{"paused"}, "active")
+}
+
+// CancelScheduledTransfer stops a schedule for good
+func (s *Service) CancelScheduledTransfer(scheduleID, senderUserID uint) (*ScheduledTransfer, error) {
+	return s.changeScheduleStatus(scheduleID, senderUserID, []string{"active", "paused"}, "cancelled")
+}
+
+func (s *Service) changeScheduleStatus(scheduleID, senderUserID uint, from []string, to string) (*ScheduledTransfer, error) {
+	var schedule *ScheduledTransfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		schedule, err = s.findSchedule(tx.Clauses(clause.Locking{Strength: "UPDATE"}), scheduleID, senderUserID)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(from, schedule.Status) {
+			return errors.New("scheduled transfer is " + schedule.Status)
+		}
+
+		updates := map[string]interface{}{"status": to}
+		if to == "active" {
+			now := time.Now()
+			for !schedule.NextRunAt.After(now) && schedule.Interval != "once" {
+				schedule.NextRunAt = nextRun(schedule.NextRunAt, schedule.Interval)
+			}
+			updates["next_run_at"] = schedule.NextRunAt
+			updates["consecutive_failures"] = 0
+		}
+		schedule.Status = to
+		return tx.Model(&ScheduledTransfer{}).Where("id = ?", schedule.ID).Updates(updates).Error
+	})
+	return schedule, err
+}
+
+// RunDueTransfers executes every active schedule whose next run has come
+func (s *Service) RunDueTransfers() (*ScheduleRunResult, error) {
+	var ids []uint
+	err := s.db.Model(&ScheduledTransfer{}).
+		Where("status = ? AND next_run_at <= ?", "active", time.Now()).
+		Order("next_run_at ASC").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ScheduleRunResult{}
+	for _, id := range ids {
+		err := s.runSchedule(id)
+		if errors.Is(err, errScheduleNotDue) {
+			continue
+		}
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		result.Succeeded++
+	}
+	return result, nil
+}
+
+// RunScheduledTransfers runs due transfers on a fixed interval. It blocks,
+// so start it in its own goroutine.
+func (s *Service) RunScheduledTransfers(interval time.Duration) {
+	for {
+		result, err := s.RunDueTransfers()
+		if err != nil {
+			log.Printf("[ScheduledTransfer] Run failed: %v", err)
+		} else if result.Succeeded > 0 || result.Failed > 0 {
+			log.Printf("[ScheduledTransfer] Executed %d transfers (%d failed)", result.Succeeded, result.Failed)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// runSchedule makes one run of a schedule. The transfer, its run record and
+// the next run time are written together, so a run cannot happen twice.
+// A failed transfer is recorded on its own afterwards.
+func (s *Service) runSchedule(scheduleID uint) error {
+	var schedule *ScheduledTransfer
+	err := s.walletService.Transaction(func(tx *gorm.DB) error {
+		var err error
+		schedule, err = s.lockDueSchedule(tx, scheduleID)
+		if err != nil {
+			return err
+		}
+
+		senderWallet, receiverWallet, err := s.transferWallets(schedule.SenderID, schedule.ReceiverID, schedule.Amount)
+		if err != nil {
+			return err
+		}
+		record, err := s.transferWithTx(tx, senderWallet, receiverWallet, schedule.Amount, schedule.Description)
+		if err != nil {
+			return err
+		}
+
+		run := &ScheduledTransferRun{
+			ScheduleID:   schedule.ID,
+			ScheduledFor: schedule.NextRunAt,
+			Status:       "success",
+			TransferID:   &record.ID,
+			Amount:       schedule.Amount,
+		}
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+
+		schedule.RunCount++
+		schedule.ConsecutiveFailures = 0
+		return s.advanceSchedule(tx, schedule, "")
+	})
+	if err == nil || errors.Is(err, errScheduleNotDue) {
+		return err
+	}
+
+	// The transfer was rolled back, keep the failure in the run history
+	if recordErr := s.recordScheduleFailure(scheduleID, err); recordErr != nil {
+		log.Printf("[ScheduledTransfer] Failed to record failure of schedule %d: %v", scheduleID, recordErr)
+	}
+	return err
+}
+
+func (s *Service) recordScheduleFailure(scheduleID uint, runErr error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		schedule, err := s.lockDueSchedule(tx, scheduleID)
+		if err != nil {
+			return err
+		}
+
+		run := &ScheduledTransferRun{
+			ScheduleID:   schedule.ID,
+			ScheduledFor: schedule.NextRunAt,
+			Status:       "failed",
+			Amount:       schedule.Amount,
+			Error:        truncate(runErr.Error(), 500),
+		}
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+
+		schedule.FailureCount++
+		schedule.ConsecutiveFailures++
+		return s.advanceSchedule(tx, schedule, run.Error)
+	})
+}
+
+// advanceSchedule moves a schedule to its next run after a success or
+// failure, finishing or pausing it when due
+func (s *Service) advanceSchedule(tx *gorm.DB, schedule *ScheduledTransfer, lastError string) error {
+	now := time.Now()
+	schedule.LastRunAt = &now
+	schedule.LastError = lastError
+
+	switch {
+	case schedule.Interval == "once":
+		schedule.Status = "completed"
+		if lastError != "" {
+			schedule.Status = "failed"
+		}
+	case schedule.ConsecutiveFailures >= MaxConsecutiveFailures:
+		schedule.Status = "paused"
+	default:
+		// Runs missed while the worker was down are skipped, not paid at once
+		for !schedule.NextRunAt.After(now) {
+			schedule.NextRunAt = nextRun(schedule.NextRunAt, schedule.Interval)
+		}
+		if schedule.MaxRuns != nil && schedule.RunCount >= *schedule.MaxRuns {
+			schedule.Status = "completed"
+		}
+		if schedule.EndAt != nil && schedule.NextRunAt.After(*schedule.EndAt) {
+			schedule.Status = "completed"
+		}
+	}
+
+	return tx.Model(&ScheduledTransfer{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+		"status":               schedule.Status,
+		"next_run_at":          schedule.NextRunAt,
+		"run_count":            schedule.RunCount,
+		"failure_count":        schedule.FailureCount,
+		"consecutive_failures": schedule.ConsecutiveFailures,
+		"last_run_at":          schedule.LastRunAt,
+		"last_error":           schedule.LastError,
+	}).Error
+}
+
+func (s *Service) lockDueSchedule(tx *gorm.DB, scheduleID uint) (*ScheduledTransfer, error) {
+	var schedule ScheduledTransfer
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, scheduleID).Error
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != "active" || schedule.NextRunAt.After(time.Now()) {
+		return nil, errScheduleNotDue
+	}
+	return &schedule, nil
+}
+
+func (s *Service) findSchedule(tx *gorm.DB, scheduleID, senderUserID uint) (*ScheduledTransfer, error) {
+	var schedule ScheduledTransfer
+	err := tx.Where("id = ? AND sender_id = ?", scheduleID, senderUserID).First(&schedule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("scheduled transfer not found")
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func nextRun(from time.Time, interval string) time.Time {
+	switch interval {
+	case "daily":
+		return from.AddDate(0, 0, 1)
+	case "weekly":
+		return from.AddDate(0, 0, 7)
+	default:
+		return from.AddDate(0, 1, 0)
+	}
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit]
+}
@@ -0,0 +1,72 @@
+package transfer
+
+import "time"
+
+const (
+	// MaxConsecutiveFailures pauses a recurring transfer after this many
+	// failed runs in a row
+	MaxConsecutiveFailures = 3
+)
+
+// ScheduledTransfer is a transfer run later by the background worker, once
+// or on a fixed interval
+type ScheduledTransfer struct {
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	SenderID            uint       `json:"sender_id" gorm:"not null;index"`
+	ReceiverID          uint       `json:"receiver_id" gorm:"not null;index"`
+	Amount              int        `json:"amount" gorm:"not null"`
+	Description         string     `json:"description" gorm:"size:255"`
+	Interval            string     `json:"interval" gorm:"type:enum('once','daily','weekly','monthly');not null"`
+	NextRunAt           time.Time  `json:"next_run_at" gorm:"not null;index"`
+	EndAt               *time.Time `json:"end_at"`   // No runs are scheduled after this time
+	MaxRuns             *int       `json:"max_runs"` // Completed after this many successful runs
+	RunCount            int        `json:"run_count" gorm:"default:0"`
+	FailureCount        int        `json:"failure_count" gorm:"default:0"`
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"default:0"`
+	LastRunAt           *time.Time `json:"last_run_at"`
+	LastError           string     `json:"last_error" gorm:"size:500"`
+	Status              string     `json:"status" gorm:"type:enum('active','paused','cancelled','completed','failed');default:'active';index"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+
+	// Virtual fields for response
+	Receiver *RecipientSummary `json:"receiver,omitempty" gorm:"-"`
+}
+
+func (ScheduledTransfer) TableName() string {
+	return "scheduled_transfers"
+}
+
+// ScheduledTransferRun is the outcome of one execution of a scheduled transfer
+type ScheduledTransferRun struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ScheduleID   uint      `json:"schedule_id" gorm:"not null;index"`
+	ScheduledFor time.Time `json:"scheduled_for" gorm:"not null"`
+	Status       string    `json:"status" gorm:"type:enum('success','failed');not null"`
+	TransferID   *uint     `json:"transfer_id"`
+	Amount       int       `json:"amount" gorm:"not null"`
+	Error        string    `json:"error" gorm:"size:500"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (ScheduledTransferRun) TableName() string {
+	return "scheduled_transfer_runs"
+}
+
+// ScheduleTransferRequest represents the request body for scheduling a transfer
+type ScheduleTransferRequest struct {
+	ReceiverUserID uint       `json:"receiver_user_id" binding:"required"`
+	Amount         int        `json:"amount" binding:"required,gt=0"`
+	Description    string     `json:"description" binding:"max=255"`
+	Interval       string     `json:"interval" binding:"required,oneof=once daily weekly monthly"`
+	StartAt        time.Time  `json:"start_at" binding:"required"` // RFC 3339, first run
+	EndAt          *time.Time `json:"end_at"`
+	MaxRuns        *int       `json:"max_runs" binding:"omitempty,gt=0"`
+	PIN            string     `json:"pin"`
+}
+
+// ScheduleRunResult counts the scheduled transfers handled by one worker run
+type ScheduleRunResult struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
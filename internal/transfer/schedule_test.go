@@ -0,0 +1,26 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	from := time.Date(2026, 3, 15, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		interval string
+		want     time.Time
+	}{
+		{"daily", time.Date(2026, 3, 16, 8, 30, 0, 0, time.UTC)},
+		{"weekly", time.Date(2026, 3, 22, 8, 30, 0, 0, time.UTC)},
+		{"monthly", time.Date(2026, 4, 15, 8, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.interval, func(t *testing.T) {
+			if got := nextRun(from, tt.interval); !got.Equal(tt.want) {
+				t.Errorf("nextRun(%s) = %v, want %v", tt.interval, got, tt.want)
+			}
+		})
+	}
+}
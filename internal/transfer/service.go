@@ -3,8 +3,13 @@ package transfer
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 	"wallet-point/internal/auth"
+	"wallet-point/internal/fraud"
+	"wallet-point/internal/limit"
 	"wallet-point/internal/wallet"
+	"wallet-point/utils"
 
 	"gorm.io/gorm"
 )
@@ -13,6 +18,8 @@ type Service struct {
 	walletRepo    *wallet.WalletRepository
 	walletService *wallet.WalletService
 	authService   *auth.AuthService
+	limitService  *limit.LimitService
+	fraudService  *fraud.FraudService
 	db            *gorm.DB
 }
 
@@ -25,92 +32,143 @@ func NewService(walletRepo *wallet.WalletRepository, walletService *wallet.Walle
 	}
 }
 
+func (s *Service) SetLimitService(limitService *limit.LimitService) {
+	s.limitService = limitService
+}
+
+func (s *Service) SetFraudService(fraudService *fraud.FraudService) {
+	s.fraudService = fraudService
+}
+
 func (s *Service) CreateTransfer(senderUserID, receiverUserID uint, amount int, description string, pin string) (*TransferInfo, error) {
+	senderWallet, receiverWallet, err := s.prepareTransfer(senderUserID, receiverUserID, amount, pin)
+	if err != nil {
+		return nil, err
+	}
+
+	var record *Transfer
+	err = s.walletService.Transaction(func(tx *gorm.DB) error {
+		record, err = s.transferWithTx(tx, senderWallet, receiverWallet, amount, description)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// prepareTransfer verifies the sender's PIN and finds both wallets
+func (s *Service) prepareTransfer(senderUserID, receiverUserID uint, amount int, pin string) (*wallet.Wallet, *wallet.Wallet, error) {
 	// 1. Verify PIN
 	if err := s.authService.VerifyPIN(senderUserID, pin); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	return s.transferWallets(senderUserID, receiverUserID, amount)
+}
+
+// transferWallets finds the wallets of a transfer and checks the sender can cover it
+func (s *Service) transferWallets(senderUserID, receiverUserID uint, amount int) (*wallet.Wallet, *wallet.Wallet, error) {
 	if senderUserID == receiverUserID {
-		return nil, errors.New("cannot transfer points to yourself")
+		return nil, nil, errors.New("cannot transfer points to yourself")
 	}
 
 	senderWallet, err := s.walletService.GetWalletByUserID(senderUserID)
 	if err != nil {
-		return nil, errors.New("sender wallet not found")
+		return nil, nil, errors.New("sender wallet not found")
 	}
 
 	receiverWallet, err := s.walletService.GetWalletByUserID(receiverUserID)
 	if err != nil {
-		return nil, errors.New("receiver wallet not found: check if user exists and has a wallet")
+		return nil, nil, errors.New("receiver wallet not found: check if user exists and has a wallet")
 	}
 
-	if senderWallet.Balance < amount {
-		return nil, errors.New("insufficient balance")
+	if senderWallet.AvailableBalance < amount {
+		return nil, nil, wallet.ErrInsufficientBalance
 	}
 
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		// 1. Deduct from sender
-		if err := s.walletService.DebitWithTransaction(tx, senderWallet.ID, amount, "transfer_out", fmt.Sprintf("Transfer to user %d: %s", receiverUserID, description)); err != nil {
-			return err
-		}
+	return senderWallet, receiverWallet, nil
+}
 
-		// 2. Credit to receiver
-		if err := s.walletService.CreditWithTransaction(tx, receiverWallet.ID, amount, "transfer_in", fmt.Sprintf("Transfer from user %d: %s", senderUserID, description)); err != nil {
-			return err
-		}
+// transferWithTx records the transfer and moves the points inside tx. Both
+// wallet transactions reference the transfer so each side can be traced.
+func (s *Service) transferWithTx(tx *gorm.DB, senderWallet, receiverWallet *wallet.Wallet, amount int, description string) (*Transfer, error) {
+	senderUserID, receiverUserID := senderWallet.UserID, receiverWallet.UserID
 
-		// 3. Record in Transfers table
-		transferRecord := &Transfer{
+	// 1. Record in Transfers table, first so the postings can reference it
+	transferRecord := &Transfer{
+		SenderID:    senderUserID,
+		ReceiverID:  receiverUserID,
+		Amount:      amount,
+		Description: description,
+		Status:      "success",
+	}
+	if err := tx.Create(transferRecord).Error; err != nil {
+		return nil, err
+	}
+
+	if s.fraudService != nil {
+		_, err := s.fraudService.Check(tx, fraud.Activity{
+			Kind:        "transfer",
 			SenderID:    senderUserID,
 			ReceiverID:  receiverUserID,
 			Amount:      amount,
-			Description: description,
-			Status:      "success",
-		}
-		if err := tx.Create(transferRecord).Error; err != nil {
-			return err
+			ReferenceID: &transferRecord.ID,
+		})
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		return nil
-	})
+	// 2. Move points from sender to receiver
+	outDesc := fmt.Sprintf("Transfer to user %d: %s", receiverUserID, description)
+	inDesc := fmt.Sprintf("Transfer from user %d: %s", senderUserID, description)
+	outTxn, inTxn, err := s.walletService.TransferWithTransaction(tx, senderWallet.ID, receiverWallet.ID, amount, outDesc, inDesc, &transferRecord.ID)
+	if err != nil {
+		return nil, err
+	}
 
+	// Checked after the debit so it counts towards the sender's totals
+	if s.limitService != nil {
+		if err := s.limitService.CheckTransfer(tx, senderUserID, senderWallet.ID, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	transferRecord.OutTransactionID = &outTxn.ID
+	transferRecord.InTransactionID = &inTxn.ID
+	err = tx.Model(&Transfer{}).Where("id = ?", transferRecord.ID).Updates(map[string]interface{}{
+		"out_transaction_id": transferRecord.OutTransactionID,
+		"in_transaction_id":  transferRecord.InTransactionID,
+	}).Error
 	if err != nil {
 		return nil, err
 	}
 
-	return &TransferInfo{
-		SenderID:    senderUserID,
-		ReceiverID:  receiverUserID,
-		Amount:      amount,
-		Description: description,
-		Status:      "success",
-	}, nil
+	return transferRecord, nil
 }
 
-func (s *Service) GetUserTransfers(userID uint, limit, page int) ([]wallet.TransactionWithDetails, int64, error) {
-	walletData, err := s.walletService.GetWalletByUserID(userID)
+// GetUserTransfers lists the transfers a user sent or received, each with the
+// user on the other side
+func (s *Service) GetUserTransfers(userID uint, params TransferListParams) ([]Transfer, int64, error) {
+	params.UserID = userID
+	transfers, total, err := s.listTransfers(params)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// We query wallet_transactions for types transfer_in/transfer_out
-	// Since GetTransactions only takes one type, we might need to modify it or do a manual query here.
-	// For now, let's just query everything and filter in our head, or do a custom query.
-
-	var txs []wallet.TransactionWithDetails
-	var total int64
-
-	baseQuery := s.db.Table("wallet_transactions").
-		Select("wallet_transactions.*, users.email as user_email, users.full_name as user_name, users.nim_nip").
-		Joins("INNER JOIN wallets ON wallet_transactions.wallet_id = wallets.id").
-		Joins("INNER JOIN users ON wallets.user_id = users.id").
-		Where("wallet_transactions.wallet_id = ? AND wallet_transactions.type IN ('transfer_in', 'transfer_out', 'marketplace')", walletData.ID)
-
-	baseQuery.Count(&total)
-
-	err = baseQuery.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Scan(&txs).Error
-	return txs, total, err
+	for i := range transfers {
+		t := &transfers[i]
+		if t.SenderID == userID {
+			t.Direction = "sent"
+			t.CounterpartyID, t.CounterpartyName, t.CounterpartyNIM = t.ReceiverID, t.ReceiverName, t.ReceiverNIM
+		} else {
+			t.Direction = "received"
+			t.CounterpartyID, t.CounterpartyName, t.CounterpartyNIM = t.SenderID, t.SenderName, t.SenderNIM
+		}
+	}
+	return transfers, total, nil
 }
 
 func (s *Service) FindRecipient(userID uint) (*RecipientSummary, error) {
@@ -122,7 +180,7 @@ func (s *Service) FindRecipient(userID uint) (*RecipientSummary, error) {
 
 	var recipient RecipientSummary
 	err = s.db.Table("users").
-		Select("id, full_name, role, nim_nip as nim").
+		Select(recipientColumns).
 		Where("id = ?", w.UserID).
 		Scan(&recipient).Error
 
@@ -136,33 +194,90 @@ func (s *Service) FindRecipient(userID uint) (*RecipientSummary, error) {
 	return &recipient, nil
 }
 
-func (s *Service) GetAllTransfers(limit, page int) ([]TransferInfo, int64, error) {
-	params := wallet.TransactionListParams{
-		Page:  page,
-		Limit: limit,
+// GetAllTransfers lists every transfer with both users
+func (s *Service) GetAllTransfers(params TransferListParams) ([]TransferInfo, int64, error) {
+	return s.listTransfers(params)
+}
+
+func (s *Service) listTransfers(params TransferListParams) ([]Transfer, int64, error) {
+	var transfers []Transfer
+	var total int64
+
+	query := s.db.Table("transfers").
+		Select("transfers.*, sender.full_name as sender_name, sender.nim_nip as sender_nim, receiver.full_name as receiver_name, receiver.nim_nip as receiver_nim").
+		Joins("LEFT JOIN users sender ON sender.id = transfers.sender_id").
+		Joins("LEFT JOIN users receiver ON receiver.id = transfers.receiver_id")
+
+	// Apply filters
+	if params.UserID != 0 {
+		switch params.Direction {
+		case "sent":
+			query = query.Where("transfers.sender_id = ?", params.UserID)
+		case "received":
+			query = query.Where("transfers.receiver_id = ?", params.UserID)
+		case "":
+			query = query.Where("(transfers.sender_id = ? OR transfers.receiver_id = ?)", params.UserID, params.UserID)
+		default:
+			return nil, 0, errors.New("direction must be sent or received")
+		}
+	} else if params.Direction != "" {
+		return nil, 0, errors.New("direction needs a user to be relative to")
 	}
 
-	// Fetch all transactions of type transfer_out (the sender's perspective) to list unique transfers
-	params.Type = "transfer_out"
+	if params.CounterpartyID != 0 {
+		query = query.Where(counterpartyCondition(params.UserID, "transfers.receiver_id = @id", "transfers.sender_id = @id"),
+			map[string]interface{}{"user": params.UserID, "id": params.CounterpartyID})
+	}
+	if counterparty := strings.TrimSpace(params.Counterparty); counterparty != "" {
+		query = query.Where(counterpartyCondition(params.UserID,
+			"(receiver.full_name LIKE @search OR receiver.nim_nip LIKE @search)",
+			"(sender.full_name LIKE @search OR sender.nim_nip LIKE @search)"),
+			map[string]interface{}{"user": params.UserID, "search": "%" + counterparty + "%"})
+	}
 
-	txns, total, err := s.walletService.GetTransactions(params)
-	if err != nil {
+	if params.FromDate != "" {
+		from, err := time.ParseInLocation("2006-01-02", params.FromDate, utils.Location())
+		if err != nil {
+			return nil, 0, errors.New("invalid from_date, use YYYY-MM-DD")
+		}
+		query = query.Where("transfers.created_at >= ?", from)
+	}
+	if params.ToDate != "" {
+		to, err := time.ParseInLocation("2006-01-02", params.ToDate, utils.Location())
+		if err != nil {
+			return nil, 0, errors.New("invalid to_date, use YYYY-MM-DD")
+		}
+		// The end date is inclusive
+		query = query.Where("transfers.created_at < ?", to.AddDate(0, 0, 1))
+	}
+	if params.MinAmount > 0 {
+		query = query.Where("transfers.amount >= ?", params.MinAmount)
+	}
+	if params.MaxAmount > 0 {
+		query = query.Where("transfers.amount <= ?", params.MaxAmount)
+	}
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	var transfers []TransferInfo
-	for _, t := range txns {
-		transfers = append(transfers, TransferInfo{
-			ID:          t.ID,
-			SenderID:    t.UserID,
-			Amount:      t.Amount,
-			Description: t.Description,
-			CreatedAt:   t.CreatedAt,
-			SenderName:  t.UserName,
-			SenderNIM:   t.NimNip,
-			Status:      "success",
-		})
+	if params.Limit > 0 {
+		query = query.Limit(params.Limit).Offset((params.Page - 1) * params.Limit)
 	}
+	query = query.Order("transfers.created_at DESC, transfers.id DESC")
 
+	if err := query.Scan(&transfers).Error; err != nil {
+		return nil, 0, err
+	}
 	return transfers, total, nil
 }
+
+// counterpartyCondition matches the other side of a user's transfers with
+// onReceiver or onSender, or either side when there is no user
+func counterpartyCondition(userID uint, onReceiver, onSender string) string {
+	if userID == 0 {
+		return "(" + onReceiver + " OR " + onSender + ")"
+	}
+	return "((transfers.sender_id = @user AND " + onReceiver + ") OR (transfers.receiver_id = @user AND " + onSender + "))"
+}
@@ -13,6 +13,7 @@ type User struct {
 	Role         string    `json:"role" gorm:"type:enum('admin','dosen','mahasiswa');not null"`
 	Status       string    `json:"status" gorm:"type:enum('active','inactive','suspended');default:'active'"`
 	PinHash      string    `json:"-" gorm:"column:pin_hash"`
+	Handle       *string   `json:"handle" gorm:"type:varchar(30);uniqueIndex"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
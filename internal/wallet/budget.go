@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"wallet-point/internal/budget"
+
+	"gorm.io/gorm"
+)
+
+// chargeBudget takes a lecturer's reward from their point budget. A returned
+// request means the reward was queued for admin approval and must not be paid.
+func (s *WalletService) chargeBudget(tx *gorm.DB, c budget.Charge) (*budget.RewardRequest, error) {
+	if s.budgetService == nil {
+		return nil, nil
+	}
+	return s.budgetService.Charge(tx, c)
+}
+
+// ReviewRewardRequest approves or rejects a reward that exceeded a lecturer's
+// budget. An approved reward is paid the way it would have been originally.
+func (s *WalletService) ReviewRewardRequest(requestID uint, req *budget.ReviewRewardRequest, adminID uint) (*budget.RewardRequest, error) {
+	var request *budget.RewardRequest
+	err := s.Transaction(func(tx *gorm.DB) error {
+		var err error
+		request, err = s.budgetService.LockPendingRequest(tx, requestID)
+		if err != nil {
+			return err
+		}
+
+		var txnID *uint
+		if req.Status == "approved" {
+			wallet, err := s.repo.FindByUserID(request.UserID)
+			if err != nil {
+				return err
+			}
+
+			txns, err := s.PostJournal(tx, Journal{
+				Type:        request.Kind,
+				Description: request.Description,
+				ReferenceID: request.ReferenceID,
+				CreatedBy:   "dosen",
+				Postings: []Posting{
+					{WalletID: wallet.ID, Direction: "credit", Amount: request.Amount},
+					{Account: contraAccount(request.Kind), Direction: "debit", Amount: request.Amount},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			txnID = &txns[0].ID
+		}
+
+		return s.budgetService.ResolveRequest(tx, request, req.Status, adminID, req.Note, txnID)
+	})
+	return request, err
+}
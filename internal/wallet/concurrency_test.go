@@ -0,0 +1,117 @@
+//go:build integration
+
+package wallet_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"wallet-point/internal/marketplace"
+	"wallet-point/internal/wallet"
+)
+
+// The concurrency tests fire parallel transfers and purchases and check that
+// no wallet ever ends up below zero or out of sync with its ledger.
+
+func TestConcurrentTransfersNeverOverdraw(t *testing.T) {
+	env := setupIntegration(t)
+	sender := env.createStudent(t, "sender", 100)
+	receiver := env.createStudent(t, "receiver", 0)
+
+	succeeded := runParallel(t, 50, func(i int) error {
+		_, err := env.transfers.CreateTransfer(sender.UserID, receiver.UserID, 10, "concurrency test", "123456")
+		return err
+	})
+
+	s, r := env.reload(t, sender.ID), env.reload(t, receiver.ID)
+	if s.Balance < 0 {
+		t.Errorf("sender balance %d, want at least 0", s.Balance)
+	}
+	if s.Balance+r.Balance != 100 {
+		t.Errorf("sender %d + receiver %d, want 100 in total", s.Balance, r.Balance)
+	}
+	if succeeded != 10 {
+		t.Errorf("%d of 50 transfers succeeded, want 10", succeeded)
+	}
+	env.checkLedger(t, s, r)
+}
+
+func TestCrossedTransfersDoNotDeadlock(t *testing.T) {
+	env := setupIntegration(t)
+	a := env.createStudent(t, "ping", 200)
+	b := env.createStudent(t, "pong", 200)
+
+	runParallel(t, 40, func(i int) error {
+		from, to := a.UserID, b.UserID
+		if i%2 == 1 {
+			from, to = to, from
+		}
+		_, err := env.transfers.CreateTransfer(from, to, 7, "concurrency test", "123456")
+		return err
+	})
+
+	a, b = env.reload(t, a.ID), env.reload(t, b.ID)
+	if a.Balance+b.Balance != 400 {
+		t.Errorf("%d + %d, want 400 in total", a.Balance, b.Balance)
+	}
+	env.checkLedger(t, a, b)
+}
+
+func TestConcurrentPurchasesNeverOversell(t *testing.T) {
+	env := setupIntegration(t)
+	buyer := env.createStudent(t, "buyer", 100)
+	product := &marketplace.Product{Name: "Concurrency Test Item", Price: 10, Stock: 5, Status: "active", CreatedBy: buyer.UserID}
+	if err := env.db.Create(product).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.db.Model(product).Update("status", "inactive") })
+
+	purchased := runParallel(t, 30, func(i int) error {
+		_, err := env.marketplace.PurchaseProduct(buyer.UserID, &marketplace.PurchaseRequest{ProductID: product.ID, Quantity: 1, PIN: "123456"})
+		return err
+	})
+
+	buyer = env.reload(t, buyer.ID)
+	if err := env.db.First(product, product.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if buyer.Balance < 0 {
+		t.Errorf("buyer balance %d, want at least 0", buyer.Balance)
+	}
+	if product.Stock < 0 || purchased != 5 {
+		t.Errorf("%d purchased with stock %d left, want 5 and 0", purchased, product.Stock)
+	}
+	if buyer.Balance != 100-purchased*10 {
+		t.Errorf("buyer balance %d, want %d", buyer.Balance, 100-purchased*10)
+	}
+	env.checkLedger(t, buyer)
+}
+
+// runParallel starts n calls at once and returns how many of them succeeded
+func runParallel(t *testing.T, n int, fn func(i int) error) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	start := make(chan struct{})
+	succeeded := 0
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			err := fn(i)
+			if err != nil && !errors.Is(err, wallet.ErrInsufficientBalance) && err.Error() != "product out of stock" {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+	return succeeded
+}
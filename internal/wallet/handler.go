@@ -1,10 +1,18 @@
 package wallet
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"strconv"
+	"time"
 	"wallet-point/internal/audit"
+	"wallet-point/internal/budget"
+	"wallet-point/internal/fraud"
+	"wallet-point/internal/limit"
+	"wallet-point/internal/realtime"
 	"wallet-point/utils"
 
 	"github.com/gin-gonic/gin"
@@ -85,7 +93,11 @@ func (h *WalletHandler) AdjustPoints(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.AdjustPoints(&req, adminID); err != nil {
+	queued, err := h.service.AdjustPoints(&req, adminID)
+	if err != nil {
+		if budget.RespondIfBudgetError(c, err) {
+			return
+		}
 		statusCode := http.StatusBadRequest
 		if err.Error() == "wallet not found" {
 			statusCode = http.StatusNotFound
@@ -94,6 +106,22 @@ func (h *WalletHandler) AdjustPoints(c *gin.Context) {
 		return
 	}
 
+	if queued != nil {
+		utils.SuccessResponse(c, http.StatusAccepted, "Reward exceeds your point budget and was queued for admin approval", queued)
+
+		// Log activity
+		h.auditService.LogActivity(audit.CreateAuditParams{
+			UserID:    adminID,
+			Action:    "QUEUE_REWARD",
+			Entity:    "REWARD_REQUEST",
+			EntityID:  queued.ID,
+			Details:   "Reward of " + strconv.Itoa(req.Amount) + " points to wallet " + strconv.FormatUint(uint64(req.WalletID), 10) + " queued over budget | Reason: " + req.Description,
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		return
+	}
+
 	utils.SuccessResponse(c, http.StatusOK, "Points adjusted successfully", nil)
 
 	// Log activity
@@ -233,6 +261,416 @@ func (h *WalletHandler) GetWalletTransactions(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Transactions retrieved successfully", transactions)
 }
 
+// GetWalletStatement handles getting the monthly statement of a wallet
+// @Summary Get wallet statement
+// @Description Get the statement of a wallet for a month with opening and closing balances and subtotals per type, as JSON, CSV or PDF (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Produce json,text/csv,application/pdf
+// @Param id path int true "Wallet ID"
+// @Param month query string false "Month as YYYY-MM, defaults to the current month"
+// @Param format query string false "Output format (json, csv, pdf)" default(json)
+// @Success 200 {object} utils.Response{data=Statement}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /admin/wallets/{id}/statement [get]
+func (h *WalletHandler) GetWalletStatement(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", nil)
+		return
+	}
+
+	statement, err := h.service.GetStatement(uint(walletID), c.Query("month"))
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "wallet not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	writeStatement(c, statement)
+}
+
+// GetTokenSweeperStats handles getting the payment token sweeper metrics
+// @Summary Get token sweeper stats
+// @Description Get how many payment tokens the background sweeper expired, cleared and purged since the server started (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=TokenSweeperStats}
+// @Router /admin/payment-tokens/sweeper [get]
+func (h *WalletHandler) GetTokenSweeperStats(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Token sweeper stats retrieved successfully", h.service.GetTokenSweeperStats())
+}
+
+// GetWalletHolds handles getting the holds of a wallet
+// @Summary Get wallet holds
+// @Description Get points reserved on a wallet by pending operations (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Wallet ID"
+// @Param status query string false "Filter by status (active, captured, voided, expired)"
+// @Success 200 {object} utils.Response{data=[]WalletHold}
+// @Router /admin/wallets/{id}/holds [get]
+func (h *WalletHandler) GetWalletHolds(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", nil)
+		return
+	}
+
+	holds, err := h.service.GetWalletHolds(uint(walletID), c.Query("status"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve holds", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Holds retrieved successfully", holds)
+}
+
+// ReleaseHold handles voiding a hold
+// @Summary Release hold
+// @Description Void an active hold and release the reserved points (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Hold ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /admin/holds/{id}/void [post]
+func (h *WalletHandler) ReleaseHold(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	holdID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid hold ID", nil)
+		return
+	}
+
+	if err := h.service.ReleaseHold(uint(holdID)); err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "hold not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Hold released successfully", nil)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "RELEASE_HOLD",
+		Entity:    "WALLET_HOLD",
+		EntityID:  uint(holdID),
+		Details:   "Admin voided hold #" + strconv.FormatUint(holdID, 10),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetWalletLedger handles getting the ledger lines of a wallet
+// @Summary Get wallet ledger
+// @Description Get double-entry ledger lines and balance check for a wallet (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Wallet ID"
+// @Param limit query int false "Number of lines" default(100)
+// @Success 200 {object} utils.Response{data=WalletLedger}
+// @Failure 404 {object} utils.Response
+// @Router /admin/wallets/{id}/ledger [get]
+func (h *WalletHandler) GetWalletLedger(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", nil)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	ledger, err := h.service.GetWalletLedger(uint(walletID), limit)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "wallet not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Ledger retrieved successfully", ledger)
+}
+
+// FreezeWallet handles freezing a wallet
+// @Summary Freeze wallet
+// @Description Block transfers, purchases and payments on a wallet, optionally until a given time (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Wallet ID"
+// @Param request body FreezeWalletRequest true "Freeze details"
+// @Success 200 {object} utils.Response{data=Wallet}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /admin/wallets/{id}/freeze [post]
+func (h *WalletHandler) FreezeWallet(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", nil)
+		return
+	}
+
+	var req FreezeWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	wallet, err := h.service.FreezeWallet(uint(walletID), &req)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "wallet not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Wallet frozen successfully", wallet)
+
+	until := "until lifted"
+	if req.Until != nil {
+		until = "until " + req.Until.Format(time.RFC3339)
+	}
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "FREEZE_WALLET",
+		Entity:    "WALLET",
+		EntityID:  uint(walletID),
+		Details:   "Admin froze wallet " + until + " | Reason: " + req.Reason,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// UnfreezeWallet handles lifting a wallet freeze
+// @Summary Unfreeze wallet
+// @Description Make a frozen wallet active again (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Wallet ID"
+// @Success 200 {object} utils.Response{data=Wallet}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /admin/wallets/{id}/freeze [delete]
+func (h *WalletHandler) UnfreezeWallet(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", nil)
+		return
+	}
+
+	wallet, err := h.service.UnfreezeWallet(uint(walletID))
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "wallet not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Wallet unfrozen successfully", wallet)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "UNFREEZE_WALLET",
+		Entity:    "WALLET",
+		EntityID:  uint(walletID),
+		Details:   "Admin lifted wallet freeze",
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// CloseWallet handles closing a wallet
+// @Summary Close wallet
+// @Description Permanently close an empty wallet (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Wallet ID"
+// @Param request body CloseWalletRequest true "Close details"
+// @Success 200 {object} utils.Response{data=Wallet}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /admin/wallets/{id}/close [post]
+func (h *WalletHandler) CloseWallet(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", nil)
+		return
+	}
+
+	var req CloseWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	wallet, err := h.service.CloseWallet(uint(walletID), &req)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "wallet not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Wallet closed successfully", wallet)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "CLOSE_WALLET",
+		Entity:    "WALLET",
+		EntityID:  uint(walletID),
+		Details:   "Admin closed wallet | Reason: " + req.Reason,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// ReverseTransaction handles reversing a wallet transaction
+// @Summary Reverse a transaction
+// @Description Undo a transaction with compensating entries, restocking products for marketplace purchases (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Transaction ID"
+// @Param request body ReverseTransactionRequest true "Reversal reason"
+// @Success 200 {object} utils.Response{data=ReversalResult}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /admin/transactions/{id}/reverse [post]
+func (h *WalletHandler) ReverseTransaction(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	txnID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid transaction ID", nil)
+		return
+	}
+
+	var req ReverseTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	result, err := h.service.ReverseTransaction(uint(txnID), req.Reason, "admin")
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "transaction not found" {
+			statusCode = http.StatusNotFound
+		} else if errors.Is(err, ErrAlreadyReversed) {
+			statusCode = http.StatusConflict
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transaction reversed successfully", result)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "REVERSE_TRANSACTION",
+		Entity:    "WALLET_TRANSACTION",
+		EntityID:  uint(txnID),
+		Details:   fmt.Sprintf("Admin reversed transaction #%d (%d entries) | Reason: %s", txnID, len(result.ReversedIDs), req.Reason),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetReconciliation handles the balance reconciliation report
+// @Summary Balance reconciliation report
+// @Description Compare each wallet balance with the sum of its successful transactions (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=ReconciliationReport}
+// @Router /admin/reconciliation [get]
+func (h *WalletHandler) GetReconciliation(c *gin.Context) {
+	report, err := h.service.Reconcile(false, "")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reconcile balances", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reconciliation report generated", report)
+}
+
+// RepairReconciliation handles repairing balance drift
+// @Summary Repair balance drift
+// @Description Post correction entries so every wallet's transaction history adds up to its balance (Admin only)
+// @Tags Admin - Wallets
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=ReconciliationReport}
+// @Router /admin/reconciliation/repair [post]
+func (h *WalletHandler) RepairReconciliation(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	report, err := h.service.Reconcile(true, "admin")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reconcile balances", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Balance drift repaired", report)
+
+	corrected := 0
+	for _, d := range report.Drifts {
+		if d.Corrected {
+			corrected++
+		}
+	}
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "REPAIR_RECONCILIATION",
+		Entity:    "WALLET",
+		Details:   fmt.Sprintf("Admin repaired balance drift: %d of %d drifting wallets corrected, total drift %d", corrected, report.DriftCount, report.TotalDrift),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
 // GetLeaderboard handles getting leaderboard
 // @Summary Get leaderboard
 // @Description Get top users by wallet balance
@@ -256,16 +694,16 @@ func (h *WalletHandler) GetLeaderboard(c *gin.Context) {
 
 // GetMyWallet handles getting current user's wallet
 // @Summary Get my wallet
-// @Description Get current authenticated user's wallet details
+// @Description Get current authenticated user's wallet details, including points expiring in the next 30 days
 // @Tags Wallet
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {object} utils.Response{data=Wallet}
+// @Success 200 {object} utils.Response{data=MyWalletResponse}
 // @Router /mahasiswa/wallet [get]
 func (h *WalletHandler) GetMyWallet(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
-	wallet, err := h.service.GetWalletByUserID(userID)
+	wallet, err := h.service.GetMyWallet(userID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found", nil)
 		return
@@ -307,7 +745,7 @@ func (h *WalletHandler) GetMyTransactions(c *gin.Context) {
 
 // GeneratePaymentToken handles generating a QR payment token
 // @Summary Generate payment token
-// @Description Generate a secure token for QR payment (Mahasiswa only)
+// @Description Generate a secure token for QR payment. A static token is a long-lived merchant QR paid to its creator, the payer enters the amount within the optional min/max bounds. A split token is paid in shares by several payers until its amount is covered (Mahasiswa only)
 // @Tags Wallet
 // @Security BearerAuth
 // @Accept json
@@ -346,21 +784,42 @@ func (h *WalletHandler) CheckTokenStatus(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Token info retrieved", token)
 }
 
+// VerifyQRPayload checks a scanned QR payload so apps can show the verified
+// recipient before asking for the PIN
+func (h *WalletHandler) VerifyQRPayload(c *gin.Context) {
+	var req VerifyQRRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	result, err := h.service.VerifyQRPayload(req.Payload)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "QR verification completed", result)
+}
+
 // ExecuteStudentPayment allows a student to pay for a scanned token
 func (h *WalletHandler) ExecuteStudentPayment(c *gin.Context) {
 	userID := c.GetUint("user_id")
-	var req struct {
-		Token string `json:"token" binding:"required"`
-		PIN   string `json:"pin" binding:"required"`
-	}
+	var req PaymentExecuteRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ValidationErrorResponse(c, err.Error())
 		return
 	}
 
-	err := h.service.StudentPayToken(req.Token, userID, req.PIN)
+	err := h.service.StudentPayToken(req, userID)
 	if err != nil {
+		if limit.RespondIfLimitError(c, err) {
+			return
+		}
+		if fraud.RespondIfFraudError(c, err) {
+			return
+		}
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
@@ -368,6 +827,219 @@ func (h *WalletHandler) ExecuteStudentPayment(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Pembayaran berhasil!", nil)
 }
 
+// CaptureTokenPayment lets the creator of a manual capture token charge the reserved points
+func (h *WalletHandler) CaptureTokenPayment(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	token, err := h.service.CaptureTokenPayment(c.Param("token"), userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Pembayaran berhasil diselesaikan", token)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "CAPTURE_TOKEN_PAYMENT",
+		Entity:    "PAYMENT_TOKEN",
+		EntityID:  token.ID,
+		Details:   fmt.Sprintf("Captured %d points authorized through token %s", token.Amount, token.Token),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// VoidTokenPayment cancels an authorized token payment and releases the reserved points
+func (h *WalletHandler) VoidTokenPayment(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	token, err := h.service.VoidTokenPayment(c.Param("token"), userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Pembayaran dibatalkan", token)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "VOID_TOKEN_PAYMENT",
+		Entity:    "PAYMENT_TOKEN",
+		EntityID:  token.ID,
+		Details:   fmt.Sprintf("Voided the payment authorized through token %s, %d points released", token.Token, token.Amount),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetMyHolds lists the points currently reserved on the student's wallet
+func (h *WalletHandler) GetMyHolds(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	wallet, err := h.service.GetWalletByUserID(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found", nil)
+		return
+	}
+
+	holds, err := h.service.GetWalletHolds(wallet.ID, c.DefaultQuery("status", "active"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve holds", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Holds retrieved successfully", holds)
+}
+
+// GetMyStatement returns the student's statement for a month as JSON, CSV or PDF
+func (h *WalletHandler) GetMyStatement(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	wallet, err := h.service.GetWalletByUserID(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found", nil)
+		return
+	}
+
+	statement, err := h.service.GetStatement(wallet.ID, c.Query("month"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	writeStatement(c, statement)
+}
+
+// GetStaticTokens lists the static merchant QR codes of the current user
+func (h *WalletHandler) GetStaticTokens(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	tokens, err := h.service.GetStaticTokens(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found", nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Static QR codes retrieved successfully", tokens)
+}
+
+// GetStaticTokenPayments lists the payments received through a static QR
+func (h *WalletHandler) GetStaticTokenPayments(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	history, err := h.service.GetStaticTokenPayments(c.Param("token"), userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payments retrieved successfully", history)
+}
+
+// RevokeStaticToken stops a static QR from accepting payments
+func (h *WalletHandler) RevokeStaticToken(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	token, err := h.service.RevokeStaticToken(c.Param("token"), userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "QR statis berhasil dinonaktifkan", token)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "REVOKE_STATIC_TOKEN",
+		Entity:    "PAYMENT_TOKEN",
+		EntityID:  token.ID,
+		Details:   fmt.Sprintf("Revoked static QR %s (%s)", token.Token, token.Merchant),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// IssueStreamTicket returns a ticket to open the event stream with, as
+// /events?ticket=<ticket>, within the next minute
+func (h *WalletHandler) IssueStreamTicket(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	ticket, expiresAt := utils.IssueStreamTicket(userID)
+	utils.SuccessResponse(c, http.StatusOK, "Stream ticket issued", gin.H{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+	})
+}
+
+// StreamEvents pushes payment token and balance events to the current user as
+// Server-Sent Events, starting with the current balance
+func (h *WalletHandler) StreamEvents(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	events, unsubscribe, err := h.service.Subscribe(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error(), nil)
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	if wallet, err := h.service.GetWalletByUserID(userID); err == nil {
+		c.SSEvent(realtime.EventBalanceUpdated, realtime.Event{Type: realtime.EventBalanceUpdated, Data: balanceEvent(wallet), At: time.Now()})
+		c.Writer.Flush()
+	}
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		}
+	})
+}
+
+// writeStatement responds with a statement in the format asked for by ?format=
+func writeStatement(c *gin.Context, statement *Statement) {
+	filename := fmt.Sprintf("statement-%d-%s", statement.WalletID, statement.Month)
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		utils.SuccessResponse(c, http.StatusOK, "Statement retrieved successfully", statement)
+	case "csv":
+		data, err := RenderStatementCSV(statement)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to render statement", err.Error())
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, filename))
+		c.Data(http.StatusOK, "application/pdf", RenderStatementPDF(statement))
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid format, use json, csv or pdf", nil)
+	}
+}
+
 // GetAdminStats handles retrieving administrative dashboard statistics
 func (h *WalletHandler) GetAdminStats(c *gin.Context) {
 	stats, err := h.service.GetAdminStats()
@@ -378,3 +1050,203 @@ func (h *WalletHandler) GetAdminStats(c *gin.Context) {
 
 	utils.SuccessResponse(c, http.StatusOK, "Admin stats retrieved", stats)
 }
+
+// CreateRewardBatch handles bulk rewards
+// @Summary Reward students in bulk
+// @Description Credit points to many students by NIM from a JSON list or a CSV upload (nim, amount, description). Set dry_run to only validate the rows. Atomic batches credit all rows or none, partial batches credit the valid rows.
+// @Tags Dosen - Rewards
+// @Security BearerAuth
+// @Accept json,mpfd,text/csv
+// @Produce json
+// @Param request body RewardBatchRequest false "Reward rows (JSON)"
+// @Param file formData file false "CSV file (multipart)"
+// @Param mode query string false "atomic or partial (CSV uploads)"
+// @Param dry_run query bool false "Only validate the rows (CSV uploads)"
+// @Success 200 {object} utils.Response{data=RewardBatchResult}
+// @Success 201 {object} utils.Response{data=RewardBatchResult}
+// @Failure 422 {object} utils.Response{errors=RewardBatchResult}
+// @Router /dosen/reward/batch [post]
+func (h *WalletHandler) CreateRewardBatch(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	req, err := bindRewardBatch(c)
+	if err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	result, err := h.service.ProcessRewardBatch(req, userID, c.GetString("role"))
+	if errors.Is(err, ErrRewardBatchInvalid) {
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error(), result)
+		return
+	}
+	if err != nil {
+		if budget.RespondIfBudgetError(c, err) {
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if result.DryRun {
+		utils.SuccessResponse(c, http.StatusOK, "Reward batch validated", result)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Reward batch processed", result)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    userID,
+		Action:    "REWARD_BATCH",
+		Entity:    "REWARD_BATCH",
+		EntityID:  result.Batch.ID,
+		Details:   fmt.Sprintf("Bulk reward (%s): %d of %d rows credited, %d queued, %d points", result.Mode, result.Batch.SucceededRows, result.Batch.TotalRows, result.Batch.QueuedRows, result.Batch.TotalAmount),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// bindRewardBatch reads a reward batch from JSON, a multipart CSV upload or a
+// raw CSV body. CSV options come from form fields or the query string.
+func bindRewardBatch(c *gin.Context) (*RewardBatchRequest, error) {
+	var req RewardBatchRequest
+
+	var csvData io.Reader
+	switch c.ContentType() {
+	case "multipart/form-data":
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			return nil, errors.New("file is required")
+		}
+		defer file.Close()
+		csvData = file
+	case "text/csv":
+		csvData = c.Request.Body
+	default:
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+
+	rows, err := ParseRewardCSV(csvData)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("CSV has no rows")
+	}
+
+	req.Rows = rows
+	req.Mode = c.DefaultPostForm("mode", c.Query("mode"))
+	req.Description = c.DefaultPostForm("description", c.Query("description"))
+	req.DryRun, _ = strconv.ParseBool(c.DefaultPostForm("dry_run", c.DefaultQuery("dry_run", "false")))
+
+	if req.Mode != "" && req.Mode != "atomic" && req.Mode != "partial" {
+		return nil, errors.New("mode must be atomic or partial")
+	}
+	if len(req.Description) > 255 {
+		return nil, errors.New("description must be at most 255 characters")
+	}
+	return &req, nil
+}
+
+// GetRewardBatches handles listing reward batches
+// @Summary Get reward batches
+// @Description Get the bulk reward batches of the current lecturer, or of everyone for admins
+// @Tags Dosen - Rewards
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]RewardBatch}
+// @Router /dosen/reward/batches [get]
+func (h *WalletHandler) GetRewardBatches(c *gin.Context) {
+	batches, err := h.service.GetRewardBatches(c.GetUint("user_id"), c.GetString("role"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve reward batches", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reward batches retrieved successfully", batches)
+}
+
+// GetRewardBatch handles getting a reward batch with its per-row results
+// @Summary Get reward batch
+// @Description Get a bulk reward batch with the result of every row
+// @Tags Dosen - Rewards
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Batch ID"
+// @Success 200 {object} utils.Response{data=RewardBatch}
+// @Failure 404 {object} utils.Response
+// @Router /dosen/reward/batches/{id} [get]
+func (h *WalletHandler) GetRewardBatch(c *gin.Context) {
+	batchID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid batch ID", nil)
+		return
+	}
+
+	batch, err := h.service.GetRewardBatch(uint(batchID), c.GetUint("user_id"), c.GetString("role"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "reward batch not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reward batch retrieved successfully", batch)
+}
+
+// ReviewRewardRequest handles approving or rejecting a queued reward
+// @Summary Review reward request
+// @Description Approve or reject a reward that exceeded a lecturer's point budget. Approved rewards are credited right away (Admin only)
+// @Tags Admin - Budgets
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Reward request ID"
+// @Param request body budget.ReviewRewardRequest true "Decision"
+// @Success 200 {object} utils.Response{data=budget.RewardRequest}
+// @Failure 404 {object} utils.Response
+// @Router /admin/budgets/requests/{id}/review [post]
+func (h *WalletHandler) ReviewRewardRequest(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid reward request ID", nil)
+		return
+	}
+
+	var req budget.ReviewRewardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	request, err := h.service.ReviewRewardRequest(uint(requestID), &req, adminID)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "reward request not found" {
+			statusCode = http.StatusNotFound
+		}
+		utils.ErrorResponse(c, statusCode, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reward request "+request.Status, request)
+
+	// Log activity
+	h.auditService.LogActivity(audit.CreateAuditParams{
+		UserID:    adminID,
+		Action:    "REVIEW_REWARD_REQUEST",
+		Entity:    "REWARD_REQUEST",
+		EntityID:  request.ID,
+		Details:   fmt.Sprintf("Admin %s reward of %d points from dosen %d to user %d", request.Status, request.Amount, request.DosenID, request.UserID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
@@ -0,0 +1,233 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrHoldNotActive = errors.New("hold is no longer active")
+
+// AuthorizeHoldWithTx reserves points on a wallet. The reservation shows up in
+// the wallet's history as a pending transaction and lowers the available
+// balance, the balance itself only changes when the hold is captured.
+func (s *WalletService) AuthorizeHoldWithTx(tx *gorm.DB, params HoldParams) (*WalletHold, error) {
+	if params.Amount <= 0 {
+		return nil, errors.New("hold amount must be positive")
+	}
+
+	wallet, err := s.repo.LockByID(tx, params.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkMovable(tx, wallet, true); err != nil {
+		return nil, err
+	}
+	if wallet.AvailableBalance < params.Amount {
+		return nil, ErrInsufficientBalance
+	}
+
+	createdBy := firstNonEmpty(params.CreatedBy, "system")
+
+	pending := &WalletTransaction{
+		WalletID:    params.WalletID,
+		Type:        params.Type,
+		Amount:      params.Amount,
+		Direction:   "debit",
+		ReferenceID: params.ReferenceID,
+		Status:      "pending",
+		Description: params.Description,
+		CreatedBy:   createdBy,
+	}
+	if err := s.repo.CreateTransaction(tx, pending); err != nil {
+		return nil, err
+	}
+
+	touchWallet(tx, params.WalletID)
+	if err := s.repo.AdjustHeldBalance(tx, params.WalletID, params.Amount); err != nil {
+		return nil, err
+	}
+
+	hold := &WalletHold{
+		WalletID:      params.WalletID,
+		Amount:        params.Amount,
+		Type:          params.Type,
+		Description:   params.Description,
+		ReferenceID:   params.ReferenceID,
+		TransactionID: pending.ID,
+		Status:        "active",
+		ExpiresAt:     params.ExpiresAt,
+		CreatedBy:     createdBy,
+	}
+	if err := s.repo.CreateHold(tx, hold); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// CaptureHoldWithTx charges a hold. amount may be less than the hold, the
+// rest is released; 0 captures the full hold. The journal carries the credit
+// side of the charge, an empty journal credits the system account matching
+// the hold type. The pending transaction becomes the debit.
+func (s *WalletService) CaptureHoldWithTx(tx *gorm.DB, holdID uint, amount int, j Journal) ([]WalletTransaction, error) {
+	hold, err := s.repo.FindHoldForUpdate(tx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != "active" {
+		return nil, ErrHoldNotActive
+	}
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount < 0 || amount > hold.Amount {
+		return nil, fmt.Errorf("capture amount must be between 1 and %d", hold.Amount)
+	}
+
+	// Lock every wallet in ID order like PostJournal does, then release the
+	// reservation so the debit can spend the held points
+	walletIDs := []uint{hold.WalletID}
+	for _, p := range j.Postings {
+		if p.WalletID != 0 {
+			walletIDs = append(walletIDs, p.WalletID)
+		}
+	}
+	sort.Slice(walletIDs, func(i, k int) bool { return walletIDs[i] < walletIDs[k] })
+	for _, id := range walletIDs {
+		if _, err := s.repo.LockByID(tx, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.AdjustHeldBalance(tx, hold.WalletID, -hold.Amount); err != nil {
+		return nil, err
+	}
+
+	if j.Type == "" {
+		j.Type = hold.Type
+	}
+	if j.Description == "" {
+		j.Description = hold.Description
+	}
+	if len(j.Postings) == 0 {
+		j.Postings = []Posting{{Account: contraAccount(hold.Type), Direction: "credit", Amount: amount}}
+	}
+	j.Postings = append([]Posting{{
+		WalletID:             hold.WalletID,
+		Direction:            "debit",
+		Amount:               amount,
+		PendingTransactionID: &hold.TransactionID,
+	}}, j.Postings...)
+
+	txns, err := s.PostJournal(tx, j)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.repo.UpdateHold(tx, hold.ID, map[string]interface{}{
+		"status":          "captured",
+		"captured_amount": amount,
+		"resolved_at":     now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txns, nil
+}
+
+// VoidHoldWithTx releases a hold without charging it. status is "voided"
+// for a cancellation or "expired" when the hold ran out.
+func (s *WalletService) VoidHoldWithTx(tx *gorm.DB, holdID uint, status string) (*WalletHold, error) {
+	hold, err := s.repo.FindHoldForUpdate(tx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != "active" {
+		return nil, ErrHoldNotActive
+	}
+
+	if _, err := s.repo.LockByID(tx, hold.WalletID); err != nil {
+		return nil, err
+	}
+	touchWallet(tx, hold.WalletID)
+	if err := s.repo.AdjustHeldBalance(tx, hold.WalletID, -hold.Amount); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateTransactionStatus(tx, hold.TransactionID, "failed"); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.repo.UpdateHold(tx, hold.ID, map[string]interface{}{"status": status, "resolved_at": now}); err != nil {
+		return nil, err
+	}
+	hold.Status = status
+	hold.ResolvedAt = &now
+	return hold, nil
+}
+
+// ReleaseHold voids a hold on behalf of an admin. Holds backing a QR payment
+// also cancel the payment.
+func (s *WalletService) ReleaseHold(holdID uint) error {
+	return s.Transaction(func(tx *gorm.DB) error {
+		return s.releaseHoldWithTx(tx, holdID, "voided")
+	})
+}
+
+func (s *WalletService) releaseHoldWithTx(tx *gorm.DB, holdID uint, status string) error {
+	token, err := s.findTokenByHold(tx, holdID)
+	if err != nil {
+		return err
+	}
+	if token != nil {
+		return s.voidTokenPaymentWithTx(tx, token, status)
+	}
+	_, err = s.VoidHoldWithTx(tx, holdID, status)
+	return err
+}
+
+// GetWalletHolds lists the holds of a wallet, optionally filtered by status
+func (s *WalletService) GetWalletHolds(walletID uint, status string) ([]WalletHold, error) {
+	return s.repo.GetHolds(walletID, status)
+}
+
+// ExpireHolds releases every active hold whose expiry has passed
+func (s *WalletService) ExpireHolds() (int, error) {
+	ids, err := s.repo.GetExpiredHoldIDs(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, id := range ids {
+		err := s.Transaction(func(tx *gorm.DB) error {
+			return s.releaseHoldWithTx(tx, id, "expired")
+		})
+		if err != nil {
+			if !errors.Is(err, ErrHoldNotActive) {
+				log.Printf("[HoldExpiry] Failed to release hold %d: %v", id, err)
+			}
+			continue
+		}
+		released++
+	}
+	return released, nil
+}
+
+// RunHoldExpiry releases expired holds on a fixed interval. It blocks, so
+// start it in its own goroutine.
+func (s *WalletService) RunHoldExpiry(interval time.Duration) {
+	for {
+		released, err := s.ExpireHolds()
+		if err != nil {
+			log.Printf("[HoldExpiry] Run failed: %v", err)
+		} else if released > 0 {
+			log.Printf("[HoldExpiry] Released %d expired holds", released)
+		}
+		time.Sleep(interval)
+	}
+}
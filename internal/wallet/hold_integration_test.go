@@ -0,0 +1,102 @@
+//go:build integration
+
+package wallet_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"wallet-point/internal/wallet"
+
+	"gorm.io/gorm"
+)
+
+func (env *integrationEnv) authorize(t *testing.T, w *wallet.Wallet, amount int, expiresAt *time.Time) (*wallet.WalletHold, error) {
+	t.Helper()
+	var hold *wallet.WalletHold
+	err := env.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		hold, err = env.wallets.AuthorizeHoldWithTx(tx, wallet.HoldParams{
+			WalletID:    w.ID,
+			Amount:      amount,
+			Type:        "marketplace",
+			Description: "Hold test",
+			ExpiresAt:   expiresAt,
+		})
+		return err
+	})
+	return hold, err
+}
+
+func TestHoldReservesUntilCaptured(t *testing.T) {
+	env := setupIntegration(t)
+	w := env.createStudent(t, "hold", 100)
+
+	hold, err := env.authorize(t, w, 60, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	held := env.reload(t, w.ID)
+	if held.Balance != 100 || held.AvailableBalance != 40 {
+		t.Errorf("after authorizing balance %d, available %d, want 100 and 40", held.Balance, held.AvailableBalance)
+	}
+	if _, err := env.authorize(t, w, 50, nil); !errors.Is(err, wallet.ErrInsufficientBalance) {
+		t.Errorf("hold beyond the available balance error = %v, want ErrInsufficientBalance", err)
+	}
+
+	// Capture less than reserved, the rest is released
+	err = env.db.Transaction(func(tx *gorm.DB) error {
+		_, err := env.wallets.CaptureHoldWithTx(tx, hold.ID, 45, wallet.Journal{})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	captured := env.reload(t, w.ID)
+	if captured.Balance != 55 || captured.AvailableBalance != 55 {
+		t.Errorf("after capturing balance %d, available %d, want 55 and 55", captured.Balance, captured.AvailableBalance)
+	}
+
+	var pending wallet.WalletTransaction
+	env.db.First(&pending, hold.TransactionID)
+	if pending.Status != "success" || pending.Amount != 45 {
+		t.Errorf("hold transaction %s for %d, want success for 45", pending.Status, pending.Amount)
+	}
+
+	err = env.db.Transaction(func(tx *gorm.DB) error {
+		_, err := env.wallets.CaptureHoldWithTx(tx, hold.ID, 0, wallet.Journal{})
+		return err
+	})
+	if !errors.Is(err, wallet.ErrHoldNotActive) {
+		t.Errorf("second capture error = %v, want ErrHoldNotActive", err)
+	}
+	env.checkLedger(t, captured)
+}
+
+func TestExpiredHoldIsReleased(t *testing.T) {
+	env := setupIntegration(t)
+	w := env.createStudent(t, "hold-expiry", 100)
+
+	past := time.Now().Add(-time.Minute)
+	hold, err := env.authorize(t, w, 30, &past)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.wallets.ExpireHolds(); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloaded wallet.WalletHold
+	env.db.First(&reloaded, hold.ID)
+	var pending wallet.WalletTransaction
+	env.db.First(&pending, hold.TransactionID)
+	if reloaded.Status != "expired" || pending.Status != "failed" {
+		t.Errorf("hold %s with transaction %s, want expired and failed", reloaded.Status, pending.Status)
+	}
+
+	released := env.reload(t, w.ID)
+	if released.Balance != 100 || released.AvailableBalance != 100 {
+		t.Errorf("after expiry balance %d, available %d, want 100 and 100", released.Balance, released.AvailableBalance)
+	}
+	env.checkLedger(t, released)
+}
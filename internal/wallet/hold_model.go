@@ -0,0 +1,42 @@
+package wallet
+
+import "time"
+
+// PaymentHoldTTL is how long a QR payment stays authorized before the
+// reserved points are released automatically
+const PaymentHoldTTL = 24 * time.Hour
+
+// WalletHold reserves points for an operation that completes later. The
+// points stay in the balance but are excluded from the available balance
+// until the hold is captured (charged) or voided (released).
+type WalletHold struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	WalletID       uint       `json:"wallet_id" gorm:"not null;index"`
+	Amount         int        `json:"amount" gorm:"not null"`
+	CapturedAmount int        `json:"captured_amount" gorm:"default:0;not null"`
+	Type           string     `json:"type" gorm:"size:50;not null"` // Transaction type charged on capture
+	Description    string     `json:"description" gorm:"size:500"`
+	ReferenceID    *uint      `json:"reference_id"`
+	TransactionID  uint       `json:"transaction_id" gorm:"not null;index"` // Pending wallet transaction shown in history
+	Status         string     `json:"status" gorm:"type:enum('active','captured','voided','expired');default:'active';index"`
+	ExpiresAt      *time.Time `json:"expires_at" gorm:"index"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	CreatedBy      string     `json:"created_by" gorm:"size:20"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (WalletHold) TableName() string {
+	return "wallet_holds"
+}
+
+// HoldParams describes the points to reserve on a wallet
+type HoldParams struct {
+	WalletID    uint
+	Amount      int
+	Type        string
+	Description string
+	ReferenceID *uint
+	ExpiresAt   *time.Time
+	CreatedBy   string
+}
@@ -0,0 +1,138 @@
+//go:build integration
+
+package wallet_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+	"wallet-point/internal/auth"
+	"wallet-point/internal/database"
+	"wallet-point/internal/marketplace"
+	"wallet-point/internal/transfer"
+	"wallet-point/internal/wallet"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The integration tests run the wallet services against a real MySQL
+// database. They create throwaway users, so point TEST_DB_DSN at a
+// development database only:
+//
+//	TEST_DB_DSN='user:pass@tcp(localhost:3306)/wallet_test?parseTime=True&loc=Local' \
+//		go test -tags integration ./internal/wallet/
+
+type integrationEnv struct {
+	db          *gorm.DB
+	auth        *auth.AuthService
+	wallets     *wallet.WalletService
+	transfers   *transfer.Service
+	marketplace *marketplace.MarketplaceService
+}
+
+func setupIntegration(t *testing.T) *integrationEnv {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(50)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	database.Migrate(db)
+
+	authService := auth.NewAuthService(auth.NewAuthRepository(db), 1)
+	walletRepo := wallet.NewWalletRepository(db)
+	walletService := wallet.NewWalletService(walletRepo, db)
+	walletService.SetAuthService(authService)
+
+	return &integrationEnv{
+		db:          db,
+		auth:        authService,
+		wallets:     walletService,
+		transfers:   transfer.NewService(walletRepo, walletService, authService, db),
+		marketplace: marketplace.NewMarketplaceService(marketplace.NewMarketplaceRepository(db), walletService, authService, db),
+	}
+}
+
+func (env *integrationEnv) createStudent(t *testing.T, name string, balance int) *wallet.Wallet {
+	t.Helper()
+	tag := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	user, err := env.auth.Register(&auth.RegisterRequest{
+		Email:    tag + "@concurrency.test",
+		Password: "Password123!",
+		FullName: "Concurrency " + name,
+		NimNip:   tag,
+		Role:     "mahasiswa",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := env.wallets.GetWalletByUserID(user.ID)
+	if err != nil {
+		w = &wallet.Wallet{UserID: user.ID}
+		if err := env.db.Create(w).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if balance > 0 {
+		_, err := env.wallets.AdjustPoints(&wallet.AdjustmentRequest{
+			WalletID:    w.ID,
+			Amount:      balance,
+			Direction:   "credit",
+			Description: "Concurrency test funding",
+		}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return env.reload(t, w.ID)
+}
+
+func (env *integrationEnv) reload(t *testing.T, walletID uint) *wallet.Wallet {
+	t.Helper()
+	w, err := env.wallets.GetWalletByID(walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// checkLedger checks that every wallet still agrees with its ledger account
+func (env *integrationEnv) checkLedger(t *testing.T, wallets ...*wallet.Wallet) {
+	t.Helper()
+	for _, w := range wallets {
+		ledger, err := env.wallets.GetWalletLedger(w.ID, 1)
+		if err != nil {
+			t.Errorf("wallet %d ledger: %v", w.ID, err)
+		} else if !ledger.Balanced {
+			t.Errorf("wallet %d balance %d, ledger %d", w.ID, ledger.WalletBalance, ledger.LedgerBalance)
+		}
+	}
+}
+
+// accountBalance returns the balance of a system ledger account
+func (env *integrationEnv) accountBalance(t *testing.T, code string) int {
+	t.Helper()
+	var balance int
+	err := env.db.Table("ledger_accounts").Where("code = ?", code).Select("COALESCE(SUM(balance), 0)").Scan(&balance).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
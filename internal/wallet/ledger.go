@@ -0,0 +1,457 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrUnbalancedJournal   = errors.New("journal entry is not balanced")
+	ErrLedgerMismatch      = errors.New("wallet balance does not match ledger")
+)
+
+// PostJournal writes a balanced journal entry and applies it to every wallet it
+// touches. Wallet legs also produce the WalletTransaction rows shown in the
+// user's history, so callers must not create those separately.
+//
+// Every wallet involved is locked for the rest of the transaction and debits
+// fail with ErrInsufficientBalance instead of driving a balance negative.
+// Frozen or closed wallets are rejected unless the journal allows them.
+// Debits spend the oldest lots first and credits open lots that keep the
+// expiry of the points passed on, see addLots.
+func (s *WalletService) PostJournal(tx *gorm.DB, j Journal) ([]WalletTransaction, error) {
+	if err := validateJournal(j); err != nil {
+		return nil, err
+	}
+
+	if err := s.lockWallets(tx, j); err != nil {
+		return nil, err
+	}
+
+	createdBy := j.CreatedBy
+	if createdBy == "" {
+		createdBy = "system"
+	}
+
+	journal := &LedgerJournal{
+		Type:        j.Type,
+		Description: j.Description,
+		ReferenceID: j.ReferenceID,
+		CreatedBy:   createdBy,
+	}
+	if err := s.repo.CreateJournal(tx, journal); err != nil {
+		return nil, err
+	}
+
+	var txns []WalletTransaction
+	touched := make(map[uint]uint) // wallet ID -> ledger account ID
+
+	// Credits open their lots once every debit has been spent, so they can
+	// pass on the expiry of the points this journal moves
+	var spent []LotSpend
+	var credits []lotCredit
+
+	for _, p := range j.Postings {
+		delta := p.Amount
+		if p.Direction == "debit" {
+			delta = -p.Amount
+		}
+
+		var account *LedgerAccount
+		var walletTxnID *uint
+		var err error
+
+		if p.WalletID != 0 {
+			account, err = s.walletAccount(tx, p.WalletID)
+			if err != nil {
+				return nil, err
+			}
+
+			if p.Direction == "debit" {
+				err = s.repo.DebitBalance(tx, p.WalletID, p.Amount)
+			} else {
+				err = s.repo.UpdateBalance(tx, p.WalletID, delta)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			txn, err := s.walletTransaction(tx, j, p, createdBy)
+			if err != nil {
+				return nil, err
+			}
+			walletTxnID = &txn.ID
+			txns = append(txns, *txn)
+
+			if p.Direction == "debit" {
+				used, err := s.consumeLots(tx, p.WalletID, p.Amount, txn.ID)
+				if err != nil {
+					return nil, err
+				}
+				spent = append(spent, used...)
+			} else {
+				credits = append(credits, lotCredit{txn: txn, sources: p.LotSources})
+			}
+			touched[p.WalletID] = account.ID
+		} else {
+			account, err = s.systemAccount(tx, p.Account)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if err := s.repo.UpdateLedgerAccountBalance(tx, account.ID, delta); err != nil {
+			return nil, err
+		}
+
+		line := &LedgerLine{
+			JournalID:           journal.ID,
+			AccountID:           account.ID,
+			Direction:           p.Direction,
+			Amount:              p.Amount,
+			WalletTransactionID: walletTxnID,
+		}
+		if err := s.repo.CreateLedgerLine(tx, line); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range credits {
+		var err error
+		if len(c.sources) == 0 {
+			spent, err = s.addLots(tx, c.txn, spent)
+		} else {
+			err = s.addLotsFromSources(tx, c.txn, c.sources)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Enforce the invariant: the cached wallet balance must equal its ledger account
+	for walletID, accountID := range touched {
+		var balances struct {
+			WalletBalance int
+			LedgerBalance int
+		}
+		err := tx.Table("wallets").
+			Select("wallets.balance as wallet_balance, ledger_accounts.balance as ledger_balance").
+			Joins("INNER JOIN ledger_accounts ON ledger_accounts.id = ?", accountID).
+			Where("wallets.id = ?", walletID).
+			Scan(&balances).Error
+		if err != nil {
+			return nil, err
+		}
+		if balances.WalletBalance != balances.LedgerBalance {
+			return nil, fmt.Errorf("%w (wallet %d: balance %d, ledger %d)", ErrLedgerMismatch, walletID, balances.WalletBalance, balances.LedgerBalance)
+		}
+	}
+
+	return txns, nil
+}
+
+// lotCredit is a wallet credit of a journal waiting for its lots
+type lotCredit struct {
+	txn     *WalletTransaction
+	sources []uint
+}
+
+// addLotsFromSources opens the lots of a credit that passes on points spent
+// by earlier debits, e.g. a refund or an escrow payout
+func (s *WalletService) addLotsFromSources(tx *gorm.DB, txn *WalletTransaction, sources []uint) error {
+	pool, err := s.repo.GetLotSpends(tx, sources)
+	if err != nil {
+		return err
+	}
+	_, err = s.addLots(tx, txn, pool)
+	return err
+}
+
+// walletTransaction writes the history row of a wallet posting
+func (s *WalletService) walletTransaction(tx *gorm.DB, j Journal, p Posting, createdBy string) (*WalletTransaction, error) {
+	if p.PendingTransactionID != nil {
+		txn, err := s.repo.ConfirmPendingTransaction(tx, *p.PendingTransactionID, p.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if txn.WalletID != p.WalletID || txn.Direction != p.Direction {
+			return nil, fmt.Errorf("pending transaction %d does not match posting", txn.ID)
+		}
+		return txn, nil
+	}
+
+	referenceID := j.ReferenceID
+	if p.ReferenceID != nil {
+		referenceID = p.ReferenceID
+	}
+
+	txn := &WalletTransaction{
+		WalletID:    p.WalletID,
+		Type:        firstNonEmpty(p.TxnType, j.Type),
+		Amount:      p.Amount,
+		Direction:   p.Direction,
+		ReferenceID: referenceID,
+		Status:      "success",
+		Description: firstNonEmpty(p.Description, j.Description),
+		CreatedBy:   createdBy,
+	}
+	if err := s.repo.CreateTransaction(tx, txn); err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+// GetWalletLedger returns the ledger view of a wallet for auditing
+func (s *WalletService) GetWalletLedger(walletID uint, limit int) (*WalletLedger, error) {
+	wallet, err := s.repo.FindByID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &WalletLedger{
+		WalletID:      wallet.ID,
+		WalletBalance: wallet.Balance,
+		Lines:         []LedgerLineWithJournal{},
+	}
+
+	account, err := s.repo.FindLedgerAccountByWallet(nil, walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// No movement has been posted yet, the opening balance is created lazily
+			ledger.LedgerBalance = wallet.Balance
+			ledger.Balanced = true
+			return ledger, nil
+		}
+		return nil, err
+	}
+
+	lines, err := s.repo.GetLedgerLines(account.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if lines != nil {
+		ledger.Lines = lines
+	}
+	ledger.LedgerBalance = account.Balance
+	ledger.Balanced = account.Balance == wallet.Balance
+
+	return ledger, nil
+}
+
+// lockWallets locks the wallets of a journal in ID order, so concurrent
+// journals touching the same pair of wallets cannot deadlock, and checks that
+// each one is active and its available balance can cover its debits.
+func (s *WalletService) lockWallets(tx *gorm.DB, j Journal) error {
+	debits := make(map[uint]int)
+	for _, p := range j.Postings {
+		if p.WalletID == 0 {
+			continue
+		}
+		if p.Direction == "debit" {
+			debits[p.WalletID] += p.Amount
+		} else if _, ok := debits[p.WalletID]; !ok {
+			debits[p.WalletID] = 0
+		}
+	}
+
+	walletIDs := make([]uint, 0, len(debits))
+	for id := range debits {
+		walletIDs = append(walletIDs, id)
+	}
+	sort.Slice(walletIDs, func(i, k int) bool { return walletIDs[i] < walletIDs[k] })
+
+	for _, id := range walletIDs {
+		wallet, err := s.repo.LockByID(tx, id)
+		if err != nil {
+			return err
+		}
+		touchWallet(tx, id)
+		switch {
+		case j.AllowInactive:
+		case j.AllowFrozen:
+			if wallet.Status == "closed" {
+				return ErrWalletClosed
+			}
+		default:
+			if err := s.checkMovable(tx, wallet, debits[id] > 0); err != nil {
+				return err
+			}
+		}
+		if debits[id] > 0 && wallet.AvailableBalance < debits[id] {
+			return ErrInsufficientBalance
+		}
+		if err := s.seedLots(tx, wallet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walletAccount returns the ledger account of a wallet, creating it with an
+// opening balance journal the first time the wallet is touched.
+func (s *WalletService) walletAccount(tx *gorm.DB, walletID uint) (*LedgerAccount, error) {
+	account, err := s.repo.FindLedgerAccountByWallet(tx, walletID)
+	if err == nil {
+		return account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var wallet Wallet
+	if err := tx.First(&wallet, walletID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("wallet not found")
+		}
+		return nil, err
+	}
+
+	account = &LedgerAccount{
+		Code:     fmt.Sprintf("WALLET_%d", walletID),
+		Name:     fmt.Sprintf("Wallet #%d", walletID),
+		Type:     "wallet",
+		WalletID: &walletID,
+	}
+	if err := s.repo.CreateLedgerAccount(tx, account); err != nil {
+		return nil, err
+	}
+
+	if wallet.Balance == 0 {
+		return account, nil
+	}
+
+	// Bring the points that existed before the ledger into it
+	opening, err := s.systemAccount(tx, AccountOpeningBalance)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &LedgerJournal{
+		Type:        "opening",
+		Description: fmt.Sprintf("Opening balance for wallet #%d", walletID),
+		CreatedBy:   "system",
+	}
+	if err := s.repo.CreateJournal(tx, journal); err != nil {
+		return nil, err
+	}
+
+	walletDir, openingDir, amount := "credit", "debit", wallet.Balance
+	if wallet.Balance < 0 {
+		walletDir, openingDir, amount = "debit", "credit", -wallet.Balance
+	}
+
+	lines := []LedgerLine{
+		{JournalID: journal.ID, AccountID: account.ID, Direction: walletDir, Amount: amount},
+		{JournalID: journal.ID, AccountID: opening.ID, Direction: openingDir, Amount: amount},
+	}
+	for i := range lines {
+		if err := s.repo.CreateLedgerLine(tx, &lines[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.UpdateLedgerAccountBalance(tx, account.ID, wallet.Balance); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateLedgerAccountBalance(tx, opening.ID, -wallet.Balance); err != nil {
+		return nil, err
+	}
+	account.Balance = wallet.Balance
+
+	return account, nil
+}
+
+// systemAccount returns a system ledger account, creating it on first use
+func (s *WalletService) systemAccount(tx *gorm.DB, code string) (*LedgerAccount, error) {
+	account, err := s.repo.FindLedgerAccountByCode(tx, code)
+	if err == nil {
+		return account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	name, ok := systemAccountNames[code]
+	if !ok {
+		return nil, fmt.Errorf("unknown ledger account: %s", code)
+	}
+
+	account = &LedgerAccount{
+		Code: code,
+		Name: name,
+		Type: "system",
+	}
+	if err := s.repo.CreateLedgerAccount(tx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func validateJournal(j Journal) error {
+	if len(j.Postings) < 2 {
+		return ErrUnbalancedJournal
+	}
+
+	credits, debits := 0, 0
+	for _, p := range j.Postings {
+		if (p.WalletID == 0) == (p.Account == "") {
+			return errors.New("posting must target either a wallet or a system account")
+		}
+		if p.Amount <= 0 {
+			return errors.New("posting amount must be positive")
+		}
+		switch p.Direction {
+		case "credit":
+			credits += p.Amount
+		case "debit":
+			debits += p.Amount
+		default:
+			return fmt.Errorf("invalid posting direction: %s", p.Direction)
+		}
+	}
+
+	if credits != debits {
+		return fmt.Errorf("%w (credits %d, debits %d)", ErrUnbalancedJournal, credits, debits)
+	}
+	return nil
+}
+
+// contraAccount picks the system account that offsets a one-sided wallet movement
+func contraAccount(txnType string) string {
+	switch txnType {
+	case "mission":
+		return AccountMissionRewards
+	case "reward":
+		return AccountLecturerRewards
+	case "marketplace":
+		return AccountMarketplaceRevenue
+	case "topup":
+		return AccountTopup
+	case "expiry":
+		return AccountExpiredPoints
+	case "transfer_in", "transfer_out":
+		return AccountTransferClearing
+	default:
+		return AccountAdjustments
+	}
+}
+
+func oppositeDirection(direction string) string {
+	if direction == "debit" {
+		return "credit"
+	}
+	return "debit"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
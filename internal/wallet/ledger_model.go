@@ -0,0 +1,121 @@
+package wallet
+
+import "time"
+
+// System ledger accounts. Every wallet movement is posted against another
+// wallet or one of these accounts so that each journal balances to zero.
+const (
+	AccountMissionRewards     = "SYS_MISSION_REWARDS"
+	AccountMarketplaceRevenue = "SYS_MARKETPLACE_REVENUE"
+	AccountAdjustments        = "SYS_ADJUSTMENTS"
+	AccountTopup              = "SYS_TOPUP"
+	AccountTransferClearing   = "SYS_TRANSFER_CLEARING"
+	AccountOpeningBalance     = "SYS_OPENING_BALANCE"
+	AccountExpiredPoints      = "SYS_EXPIRED_POINTS"
+	AccountLecturerRewards    = "SYS_LECTURER_REWARDS"
+	AccountMarketplaceEscrow  = "SYS_MARKETPLACE_ESCROW"
+	AccountSplitBillEscrow    = "SYS_SPLIT_BILL_ESCROW"
+	AccountCorrections        = "SYS_CORRECTIONS"
+)
+
+var systemAccountNames = map[string]string{
+	AccountMissionRewards:     "Mission Rewards Pool",
+	AccountMarketplaceRevenue: "Marketplace Revenue",
+	AccountAdjustments:        "Manual Adjustments",
+	AccountTopup:              "Top Up",
+	AccountTransferClearing:   "Transfer Clearing",
+	AccountOpeningBalance:     "Opening Balances",
+	AccountExpiredPoints:      "Expired Points",
+	AccountLecturerRewards:    "Lecturer Rewards",
+	AccountMarketplaceEscrow:  "Marketplace Escrow",
+	AccountSplitBillEscrow:    "Split Bill Escrow",
+	AccountCorrections:        "Reconciliation Corrections",
+}
+
+// LedgerAccount holds the running balance (credits minus debits) of a wallet
+// or a system account.
+type LedgerAccount struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Code      string    `json:"code" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Name      string    `json:"name" gorm:"size:255"`
+	Type      string    `json:"type" gorm:"type:enum('wallet','system');not null"`
+	WalletID  *uint     `json:"wallet_id" gorm:"uniqueIndex"`
+	Balance   int       `json:"balance" gorm:"default:0;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+type LedgerJournal struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Type        string    `json:"type" gorm:"size:50;not null"`
+	Description string    `json:"description" gorm:"size:500"`
+	ReferenceID *uint     `json:"reference_id"`
+	CreatedBy   string    `json:"created_by" gorm:"size:20"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (LedgerJournal) TableName() string {
+	return "ledger_journals"
+}
+
+type LedgerLine struct {
+	ID                  uint      `json:"id" gorm:"primaryKey"`
+	JournalID           uint      `json:"journal_id" gorm:"not null;index"`
+	AccountID           uint      `json:"account_id" gorm:"not null;index"`
+	Direction           string    `json:"direction" gorm:"type:enum('credit','debit');not null"`
+	Amount              int       `json:"amount" gorm:"not null"`
+	WalletTransactionID *uint     `json:"wallet_transaction_id" gorm:"index"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func (LedgerLine) TableName() string {
+	return "ledger_lines"
+}
+
+// Posting is one leg of a journal before it is written. Exactly one of
+// WalletID or Account must be set.
+type Posting struct {
+	WalletID    uint
+	Account     string
+	Direction   string
+	Amount      int
+	TxnType     string // Wallet transaction type, defaults to the journal type
+	Description string // Wallet transaction description, defaults to the journal description
+	ReferenceID *uint  // Wallet transaction reference, defaults to the journal reference
+
+	// PendingTransactionID completes a pending wallet transaction, e.g. of a
+	// captured hold, instead of creating a new one
+	PendingTransactionID *uint
+
+	// LotSources are earlier debits whose points a credit passes on. Its lots
+	// keep their expiry instead of that of the debits in the same journal.
+	LotSources []uint
+}
+
+type Journal struct {
+	Type          string
+	Description   string
+	ReferenceID   *uint
+	CreatedBy     string
+	Postings      []Posting
+	AllowInactive bool // Admin and system corrections also apply to frozen or closed wallets
+	AllowFrozen   bool // Admin adjustments also apply to frozen wallets, but never to closed ones
+}
+
+type LedgerLineWithJournal struct {
+	LedgerLine
+	JournalType        string `json:"journal_type"`
+	JournalDescription string `json:"journal_description"`
+}
+
+type WalletLedger struct {
+	WalletID      uint                    `json:"wallet_id"`
+	WalletBalance int                     `json:"wallet_balance"`
+	LedgerBalance int                     `json:"ledger_balance"`
+	Balanced      bool                    `json:"balanced"`
+	Lines         []LedgerLineWithJournal `json:"lines"`
+}
@@ -0,0 +1,97 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateJournal(t *testing.T) {
+	tests := []struct {
+		name       string
+		postings   []Posting
+		wantErr    bool
+		unbalanced bool
+	}{
+		{
+			name: "balanced wallet to wallet",
+			postings: []Posting{
+				{WalletID: 1, Direction: "debit", Amount: 10},
+				{WalletID: 2, Direction: "credit", Amount: 10},
+			},
+		},
+		{
+			name: "balanced against system account",
+			postings: []Posting{
+				{WalletID: 1, Direction: "credit", Amount: 25},
+				{Account: AccountMissionRewards, Direction: "debit", Amount: 25},
+			},
+		},
+		{
+			name: "split over several postings",
+			postings: []Posting{
+				{WalletID: 1, Direction: "debit", Amount: 30},
+				{WalletID: 2, Direction: "credit", Amount: 20},
+				{Account: AccountAdjustments, Direction: "credit", Amount: 10},
+			},
+		},
+		{
+			name:       "single posting",
+			postings:   []Posting{{WalletID: 1, Direction: "credit", Amount: 10}},
+			wantErr:    true,
+			unbalanced: true,
+		},
+		{
+			name: "credits exceed debits",
+			postings: []Posting{
+				{WalletID: 1, Direction: "debit", Amount: 10},
+				{WalletID: 2, Direction: "credit", Amount: 11},
+			},
+			wantErr:    true,
+			unbalanced: true,
+		},
+		{
+			name: "posting without target",
+			postings: []Posting{
+				{Direction: "debit", Amount: 10},
+				{WalletID: 2, Direction: "credit", Amount: 10},
+			},
+			wantErr: true,
+		},
+		{
+			name: "posting with wallet and account",
+			postings: []Posting{
+				{WalletID: 1, Account: AccountTopup, Direction: "debit", Amount: 10},
+				{WalletID: 2, Direction: "credit", Amount: 10},
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero amount",
+			postings: []Posting{
+				{WalletID: 1, Direction: "debit", Amount: 0},
+				{WalletID: 2, Direction: "credit", Amount: 0},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown direction",
+			postings: []Posting{
+				{WalletID: 1, Direction: "out", Amount: 10},
+				{WalletID: 2, Direction: "credit", Amount: 10},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateJournal(Journal{Type: "adjustment", Postings: tt.postings})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateJournal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.unbalanced && !errors.Is(err, ErrUnbalancedJournal) {
+				t.Errorf("validateJournal() error = %v, want ErrUnbalancedJournal", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package wallet
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// seedLots brings a balance that predates lot tracking into a single lot, so
+// those points expire a full lifetime after tracking started rather than
+// immediately. Must be called with the wallet locked.
+func (s *WalletService) seedLots(tx *gorm.DB, wallet *Wallet) error {
+	if wallet.Balance <= 0 {
+		return nil
+	}
+
+	count, err := s.repo.CountLots(tx, wallet.ID)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	now := time.Now()
+	return s.repo.CreateLot(tx, &PointLot{
+		WalletID:  wallet.ID,
+		Amount:    wallet.Balance,
+		Remaining: wallet.Balance,
+		EarnedAt:  now,
+		ExpiresAt: now.AddDate(0, PointLifetimeMonths, 0),
+	})
+}
+
+// earningTypes are the credits that bring newly earned points into a wallet
+// and open a lot with a full lifetime. Every other credit passes on points
+// earned before, e.g. a transfer or a refund, and keeps their expiry so
+// moving points around never extends it.
+var earningTypes = map[string]bool{
+	"mission":    true,
+	"reward":     true,
+	"adjustment": true,
+	"topup":      true,
+}
+
+// addLots opens the lots for points credited by a wallet transaction. A
+// passed-on credit takes its lots from pool, the lots its points were spent
+// from, and returns what is left of it. Points the pool cannot cover, e.g.
+// spent before lot spends were recorded, get a full lifetime.
+func (s *WalletService) addLots(tx *gorm.DB, txn *WalletTransaction, pool []LotSpend) ([]LotSpend, error) {
+	remaining := txn.Amount
+	if !earningTypes[txn.Type] {
+		for remaining > 0 && len(pool) > 0 {
+			used := min(pool[0].Amount, remaining)
+			err := s.repo.CreateLot(tx, &PointLot{
+				WalletID:            txn.WalletID,
+				WalletTransactionID: &txn.ID,
+				Amount:              used,
+				Remaining:           used,
+				EarnedAt:            pool[0].EarnedAt,
+				ExpiresAt:           pool[0].ExpiresAt,
+			})
+			if err != nil {
+				return nil, err
+			}
+			remaining -= used
+			pool[0].Amount -= used
+			if pool[0].Amount == 0 {
+				pool = pool[1:]
+			}
+		}
+	}
+	if remaining == 0 {
+		return pool, nil
+	}
+
+	now := time.Now()
+	return pool, s.repo.CreateLot(tx, &PointLot{
+		WalletID:            txn.WalletID,
+		WalletTransactionID: &txn.ID,
+		Amount:              remaining,
+		Remaining:           remaining,
+		EarnedAt:            now,
+		ExpiresAt:           now.AddDate(0, PointLifetimeMonths, 0),
+	})
+}
+
+// consumeLots spends points of a debit from the lots that expire first and
+// returns what it took from each
+func (s *WalletService) consumeLots(tx *gorm.DB, walletID uint, amount int, txnID uint) ([]LotSpend, error) {
+	lots, err := s.repo.GetOpenLots(tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var spends []LotSpend
+	for _, lot := range lots {
+		if amount == 0 {
+			break
+		}
+		used := lot.Remaining
+		if used > amount {
+			used = amount
+		}
+		if err := s.repo.UpdateLotRemaining(tx, lot.ID, lot.Remaining-used); err != nil {
+			return nil, err
+		}
+		spend := LotSpend{
+			LotID:               lot.ID,
+			WalletTransactionID: txnID,
+			Amount:              used,
+			EarnedAt:            lot.EarnedAt,
+			ExpiresAt:           lot.ExpiresAt,
+		}
+		if err := s.repo.CreateLotSpend(tx, &spend); err != nil {
+			return nil, err
+		}
+		spends = append(spends, spend)
+		amount -= used
+	}
+
+	if amount > 0 {
+		return nil, fmt.Errorf("%w (wallet %d: %d points not covered by lots)", ErrLedgerMismatch, walletID, amount)
+	}
+	return spends, nil
+}
+
+// ExpirePoints removes every lot past its expiry date from the wallets
+// holding it, one wallet per database transaction.
+func (s *WalletService) ExpirePoints() (*ExpiryRunResult, error) {
+	now := time.Now()
+	walletIDs, err := s.repo.GetWalletsWithExpiredLots(now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ExpiryRunResult{}
+	for _, walletID := range walletIDs {
+		expired := 0
+		err := s.Transaction(func(tx *gorm.DB) error {
+			wallet, err := s.repo.LockByID(tx, walletID)
+			if err != nil {
+				return err
+			}
+
+			lots, err := s.repo.GetExpiredLots(tx, walletID, now)
+			if err != nil || len(lots) == 0 {
+				return err
+			}
+
+			// Points reserved by a hold stay until the hold is captured or
+			// released, a later run expires what is left of them
+			ids := make([]uint, 0, len(lots))
+			for _, lot := range lots {
+				amount := min(lot.Remaining, wallet.AvailableBalance-expired)
+				if amount <= 0 {
+					break
+				}
+				expired += amount
+				if amount == lot.Remaining {
+					ids = append(ids, lot.ID)
+				}
+			}
+			if expired == 0 {
+				return nil
+			}
+
+			// Expired lots have the earliest expiry, so the debit consumes exactly them
+			_, err = s.PostJournal(tx, Journal{
+				Type:          "expiry",
+				Description:   fmt.Sprintf("%d points expired after %d months", expired, PointLifetimeMonths),
+				AllowInactive: true,
+				Postings: []Posting{
+					{WalletID: walletID, Direction: "debit", Amount: expired},
+					{Account: AccountExpiredPoints, Direction: "credit", Amount: expired},
+				},
+			})
+			if err != nil || len(ids) == 0 {
+				return err
+			}
+
+			return s.repo.MarkLotsExpired(tx, ids, now)
+		})
+		if err != nil {
+			log.Printf("[PointExpiry] Failed to expire points of wallet %d: %v", walletID, err)
+			result.Failed++
+			continue
+		}
+		if expired > 0 {
+			result.Wallets++
+			result.Points += expired
+		}
+	}
+
+	return result, nil
+}
+
+// RunPointExpiry expires stale points on a fixed interval. It blocks, so
+// start it in its own goroutine.
+func (s *WalletService) RunPointExpiry(interval time.Duration) {
+	for {
+		result, err := s.ExpirePoints()
+		if err != nil {
+			log.Printf("[PointExpiry] Run failed: %v", err)
+		} else if result.Wallets > 0 || result.Failed > 0 {
+			log.Printf("[PointExpiry] Expired %d points from %d wallets (%d failed)", result.Points, result.Wallets, result.Failed)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// GetMyWallet returns a student's wallet together with the points expiring soon
+func (s *WalletService) GetMyWallet(userID uint) (*MyWalletResponse, error) {
+	wallet, err := s.repo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	lots, err := s.repo.GetExpiringLots(wallet.ID, time.Now().AddDate(0, 0, ExpiringSoonDays))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &MyWalletResponse{
+		Wallet:       *wallet,
+		ExpiringLots: []ExpiringPoints{},
+	}
+	for _, lot := range lots {
+		response.ExpiringSoon += lot.Remaining
+		response.ExpiringLots = append(response.ExpiringLots, ExpiringPoints{
+			Amount:    lot.Remaining,
+			ExpiresAt: lot.ExpiresAt,
+		})
+	}
+
+	return response, nil
+}
@@ -0,0 +1,63 @@
+package wallet
+
+import "time"
+
+const (
+	// PointLifetimeMonths is how long credited points stay spendable
+	PointLifetimeMonths = 12
+	// ExpiringSoonDays is the window shown to students as "expiring soon"
+	ExpiringSoonDays = 30
+)
+
+// PointLot tracks a batch of credited points until it is spent or expires.
+// Debits consume lots oldest first, so the sum of the open lots of a wallet
+// always equals its balance.
+type PointLot struct {
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	WalletID            uint       `json:"wallet_id" gorm:"not null;index"`
+	WalletTransactionID *uint      `json:"wallet_transaction_id" gorm:"index"` // Nil for balances that predate lot tracking
+	Amount              int        `json:"amount" gorm:"not null"`
+	Remaining           int        `json:"remaining" gorm:"not null"`
+	EarnedAt            time.Time  `json:"earned_at" gorm:"not null"`
+	ExpiresAt           time.Time  `json:"expires_at" gorm:"not null;index"`
+	ExpiredAt           *time.Time `json:"expired_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+func (PointLot) TableName() string {
+	return "point_lots"
+}
+
+// LotSpend records how much of a lot a debit used, so points passed on to
+// another wallet keep the expiry of the lots they came from.
+type LotSpend struct {
+	ID                  uint      `json:"id" gorm:"primaryKey"`
+	LotID               uint      `json:"lot_id" gorm:"not null;index"`
+	WalletTransactionID uint      `json:"wallet_transaction_id" gorm:"not null;index"` // The debit
+	Amount              int       `json:"amount" gorm:"not null"`
+	EarnedAt            time.Time `json:"earned_at" gorm:"not null"`
+	ExpiresAt           time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func (LotSpend) TableName() string {
+	return "point_lot_spends"
+}
+
+type ExpiringPoints struct {
+	Amount    int       `json:"amount"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MyWalletResponse is the student's wallet with the points about to expire
+type MyWalletResponse struct {
+	Wallet
+	ExpiringSoon int              `json:"expiring_soon"`
+	ExpiringLots []ExpiringPoints `json:"expiring_lots"`
+}
+
+type ExpiryRunResult struct {
+	Wallets int `json:"wallets"`
+	Points  int `json:"points"`
+	Failed  int `json:"failed"`
+}
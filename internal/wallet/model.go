@@ -2,31 +2,52 @@ package wallet
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Wallet struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserID    uint      `json:"user_id" gorm:"uniqueIndex;not null"`
-	Balance   int       `json:"balance" gorm:"default:0;not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"uniqueIndex;not null"`
+	Balance      int        `json:"balance" gorm:"default:0;not null"`
+	HeldBalance  int        `json:"held_balance" gorm:"default:0;not null"` // Reserved by active holds
+	Status       string     `json:"status" gorm:"type:enum('active','frozen','closed');default:'active';not null"`
+	StatusReason string     `json:"status_reason" gorm:"size:500"`
+	FrozenUntil  *time.Time `json:"frozen_until"` // Nil freezes the wallet until an admin lifts it
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	AvailableBalance int `json:"available_balance" gorm:"-"` // Balance minus held points
 }
 
 func (Wallet) TableName() string {
 	return "wallets"
 }
 
+// AfterFind computes the available balance
+func (w *Wallet) AfterFind(tx *gorm.DB) error {
+	w.AvailableBalance = w.Balance - w.HeldBalance
+	return nil
+}
+
+// isFrozen reports whether a freeze still applies at now. An expired freeze
+// stays on the row until RunFreezeExpiry lifts it.
+func (w *Wallet) isFrozen(now time.Time) bool {
+	return w.Status == "frozen" && (w.FrozenUntil == nil || w.FrozenUntil.After(now))
+}
+
 type WalletTransaction struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	WalletID    uint      `json:"wallet_id" gorm:"not null"`
-	Type        string    `json:"type" gorm:"type:enum('mission','transfer_in','transfer_out','marketplace','adjustment','topup');not null"`
-	Amount      int       `json:"amount" gorm:"not null"`
-	Direction   string    `json:"direction" gorm:"type:enum('credit','debit');not null"`
-	ReferenceID *uint     `json:"reference_id"`
-	Status      string    `json:"status" gorm:"type:enum('success','failed','pending');default:'success'"`
-	Description string    `json:"description" gorm:"size:500"`
-	CreatedBy   string    `json:"created_by" gorm:"type:enum('system','admin','dosen');default:'system'"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	WalletID    uint       `json:"wallet_id" gorm:"not null"`
+	Type        string     `json:"type" gorm:"type:enum('mission','transfer_in','transfer_out','marketplace','adjustment','topup','reversal','expiry','correction','reward');not null"`
+	Amount      int        `json:"amount" gorm:"not null"`
+	Direction   string     `json:"direction" gorm:"type:enum('credit','debit');not null"`
+	ReferenceID *uint      `json:"reference_id"`
+	Status      string     `json:"status" gorm:"type:enum('success','failed','pending');default:'success'"`
+	Description string     `json:"description" gorm:"size:500"`
+	CreatedBy   string     `json:"created_by" gorm:"type:enum('system','admin','dosen');default:'system'"`
+	ReversedAt  *time.Time `json:"reversed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
 
 func (WalletTransaction) TableName() string {
@@ -41,23 +62,25 @@ type WalletWithUser struct {
 	NimNip   string `json:"nim_nip"`
 	Role     string `json:"role"`
 	Balance  int    `json:"balance"`
+	Status   string `json:"status,omitempty"`
 }
 
 type TransactionWithDetails struct {
-	ID          uint      `json:"id"`
-	WalletID    uint      `json:"wallet_id"`
-	UserID      uint      `json:"user_id"`
-	UserEmail   string    `json:"user_email"`
-	UserName    string    `json:"user_name"`
-	NimNip      string    `json:"nim_nip"`
-	Type        string    `json:"type"`
-	Amount      int       `json:"amount"`
-	Direction   string    `json:"direction"`
-	ReferenceID *uint     `json:"reference_id"`
-	Status      string    `json:"status"`
-	Description string    `json:"description"`
-	CreatedBy   string    `json:"created_by"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uint       `json:"id"`
+	WalletID    uint       `json:"wallet_id"`
+	UserID      uint       `json:"user_id"`
+	UserEmail   string     `json:"user_email"`
+	UserName    string     `json:"user_name"`
+	NimNip      string     `json:"nim_nip"`
+	Type        string     `json:"type"`
+	Amount      int        `json:"amount"`
+	Direction   string     `json:"direction"`
+	ReferenceID *uint      `json:"reference_id"`
+	Status      string     `json:"status"`
+	Description string     `json:"description"`
+	CreatedBy   string     `json:"created_by"`
+	ReversedAt  *time.Time `json:"reversed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
 
 type AdjustmentRequest struct {
@@ -67,6 +90,24 @@ type AdjustmentRequest struct {
 	Description string `json:"description" binding:"required"`
 }
 
+type ReverseTransactionRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ReversalResult struct {
+	ReversedIDs  []uint              `json:"reversed_ids"`
+	Compensating []WalletTransaction `json:"compensating"`
+}
+
+type FreezeWalletRequest struct {
+	Reason string     `json:"reason" binding:"required"`
+	Until  *time.Time `json:"until"` // Optional, RFC 3339
+}
+
+type CloseWalletRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
 type ResetWalletRequest struct {
 	WalletID   uint   `json:"wallet_id" binding:"required"`
 	NewBalance int    `json:"new_balance" binding:"gte=0"`
@@ -74,6 +115,7 @@ type ResetWalletRequest struct {
 }
 
 type TransactionListParams struct {
+	WalletID  uint
 	Type      string
 	Status    string
 	Direction string
@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"wallet-point/internal/realtime"
+
+	"gorm.io/gorm"
+)
+
+type touchedWalletsKey struct{}
+
+// touchedWallets collects the wallets whose balance changed in a transaction
+type touchedWallets struct {
+	mu  sync.Mutex
+	ids map[uint]struct{}
+}
+
+// Transaction runs fn in a database transaction and, once it commits, pushes
+// the new balance of every wallet it posted to or reserved points on
+func (s *WalletService) Transaction(fn func(tx *gorm.DB) error) error {
+	touched := &touchedWallets{ids: make(map[uint]struct{})}
+	ctx := context.WithValue(context.Background(), touchedWalletsKey{}, touched)
+
+	if err := s.db.WithContext(ctx).Transaction(fn); err != nil {
+		return err
+	}
+
+	touched.mu.Lock()
+	walletIDs := make([]uint, 0, len(touched.ids))
+	for id := range touched.ids {
+		walletIDs = append(walletIDs, id)
+	}
+	touched.mu.Unlock()
+
+	s.publishBalances(walletIDs)
+	return nil
+}
+
+// Subscribe registers a realtime client of a user. The returned function
+// must be called once the client disconnects.
+func (s *WalletService) Subscribe(userID uint) (<-chan realtime.Event, func(), error) {
+	if s.broker == nil {
+		return nil, nil, errors.New("realtime updates are not available")
+	}
+	events, unsubscribe := s.broker.Subscribe(userID)
+	return events, unsubscribe, nil
+}
+
+// touchWallet records a balance change for Transaction. Outside of it there
+// is nobody to tell and nothing happens.
+func touchWallet(tx *gorm.DB, walletID uint) {
+	if tx.Statement.Context == nil {
+		return
+	}
+	touched, ok := tx.Statement.Context.Value(touchedWalletsKey{}).(*touchedWallets)
+	if !ok {
+		return
+	}
+	touched.mu.Lock()
+	touched.ids[walletID] = struct{}{}
+	touched.mu.Unlock()
+}
+
+func (s *WalletService) publishBalances(walletIDs []uint) {
+	if s.broker == nil {
+		return
+	}
+	for _, id := range walletIDs {
+		wallet, err := s.repo.FindByID(id)
+		if err != nil {
+			continue
+		}
+		s.broker.Publish(wallet.UserID, realtime.EventBalanceUpdated, balanceEvent(wallet))
+	}
+}
+
+// publishTokenEvent tells the creator of a token that its status changed
+func (s *WalletService) publishTokenEvent(token *PaymentToken, eventType string) {
+	if s.broker == nil {
+		return
+	}
+	creator, err := s.repo.FindByID(token.WalletID)
+	if err != nil {
+		return
+	}
+	s.broker.Publish(creator.UserID, eventType, TokenEvent{
+		Token:         token.Token,
+		Type:          token.Type,
+		Status:        token.Status,
+		Amount:        token.Amount,
+		PaidAmount:    token.PaidAmount,
+		PayerWalletID: token.PayerWalletID,
+	})
+}
+
+func balanceEvent(wallet *Wallet) BalanceEvent {
+	return BalanceEvent{
+		WalletID:         wallet.ID,
+		Balance:          wallet.Balance,
+		HeldBalance:      wallet.HeldBalance,
+		AvailableBalance: wallet.AvailableBalance,
+	}
+}
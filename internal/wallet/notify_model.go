@@ -0,0 +1,20 @@
+package wallet
+
+// BalanceEvent is pushed to a wallet owner whenever the balance changes
+type BalanceEvent struct {
+	WalletID         uint `json:"wallet_id"`
+	Balance          int  `json:"balance"`
+	HeldBalance      int  `json:"held_balance"`
+	AvailableBalance int  `json:"available_balance"`
+}
+
+// TokenEvent is pushed to the creator of a payment token when it is paid,
+// authorized, voided or expires
+type TokenEvent struct {
+	Token         string `json:"token"`
+	Type          string `json:"type"`
+	Status        string `json:"status"`
+	Amount        int    `json:"amount"`
+	PaidAmount    int    `json:"paid_amount,omitempty"` // Collected so far by a split token
+	PayerWalletID *uint  `json:"payer_wallet_id,omitempty"`
+}
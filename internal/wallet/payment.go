@@ -0,0 +1,216 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"wallet-point/internal/realtime"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tokenPayment holds the transaction types and descriptions of a QR payment
+type tokenPayment struct {
+	txnType       string
+	payerType     string
+	recipientType string
+	payerDesc     string
+	recipientDesc string
+	refID         *uint
+}
+
+// resolveTokenRecipient returns who gets paid for a token, falling back to the
+// first admin for bills without a recipient
+func (s *WalletService) resolveTokenRecipient(token *PaymentToken) (uint, error) {
+	if token.RecipientID != 0 {
+		return token.RecipientID, nil
+	}
+
+	var adminUser struct {
+		ID       uint
+		FullName string
+	}
+	err := s.db.Table("users").Where("role = ?", "admin").Select("id, full_name").Order("id asc").First(&adminUser).Error
+	if err != nil {
+		log.Printf("[StudentPayToken] No admin user found in database: %v", err)
+		return 0, errors.New("sistem gagal menemukan admin sebagai penerima")
+	}
+	return adminUser.ID, nil
+}
+
+// recipientWallet finds the wallet of a payment recipient, creating it if missing
+func (s *WalletService) recipientWallet(tx *gorm.DB, recipientID uint) (*Wallet, error) {
+	wallet, err := s.repo.FindByUserID(recipientID)
+	if err == nil {
+		return wallet, nil
+	}
+
+	// If wallet doesn't exist, create it (every user should have one)
+	log.Printf("[StudentPayToken] Recipient (User %d) has no wallet, creating one...", recipientID)
+	wallet = &Wallet{
+		UserID:  recipientID,
+		Balance: 0,
+	}
+	if err := tx.Create(wallet).Error; err != nil {
+		return nil, errors.New("gagal menyiapkan wallet penerima")
+	}
+	return wallet, nil
+}
+
+func (s *WalletService) describeTokenPayment(tx *gorm.DB, token *PaymentToken, scannerUserID, recipientID uint) tokenPayment {
+	p := tokenPayment{
+		txnType:       "marketplace",
+		payerDesc:     "Pembayaran QR",
+		recipientDesc: fmt.Sprintf("Terima Pembayaran QR dari User #%d", scannerUserID),
+	}
+
+	if token.Type == "transfer" {
+		p.txnType = "transfer"
+		p.payerDesc = fmt.Sprintf("Transfer QR ke User #%d", recipientID)
+		p.recipientDesc = fmt.Sprintf("Terima Transfer QR dari User #%d", scannerUserID)
+	} else if token.Type == "static" && token.Merchant != "" {
+		p.payerDesc = "Bayar: " + token.Merchant
+		p.recipientDesc = fmt.Sprintf("Terima Pembayaran QR %s dari User #%d", token.Merchant, scannerUserID)
+	} else if token.Type == "purchase" && token.ProductID != 0 {
+		p.refID = &token.ProductID
+		var prodName string
+		tx.Table("products").Where("id = ?", token.ProductID).Select("name").Scan(&prodName)
+		if prodName != "" {
+			p.payerDesc = "Beli: " + prodName
+			p.recipientDesc = fmt.Sprintf("Penjualan: %s ke User #%d", prodName, scannerUserID)
+		}
+	}
+
+	p.payerType, p.recipientType = p.txnType, p.txnType
+	if p.txnType == "transfer" {
+		p.payerType = "transfer_out"
+		p.recipientType = "transfer_in"
+	}
+	return p
+}
+
+// CaptureTokenPayment charges the points reserved when a manual capture token
+// was scanned. Only the creator of the token can capture it.
+func (s *WalletService) CaptureTokenPayment(tokenCode string, userID uint) (*PaymentToken, error) {
+	var token *PaymentToken
+	err := s.Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, err = s.lockAuthorizedToken(tx, tokenCode)
+		if err != nil {
+			return err
+		}
+
+		creatorWallet, err := s.repo.FindByUserID(userID)
+		if err != nil || creatorWallet.ID != token.WalletID {
+			return errors.New("hanya pembuat token yang dapat menyelesaikan pembayaran")
+		}
+
+		payerWallet, err := s.repo.FindByID(*token.PayerWalletID)
+		if err != nil {
+			return err
+		}
+
+		recipientID, err := s.resolveTokenRecipient(token)
+		if err != nil {
+			return err
+		}
+		recipientWallet, err := s.recipientWallet(tx, recipientID)
+		if err != nil {
+			return err
+		}
+
+		payment := s.describeTokenPayment(tx, token, payerWallet.UserID, recipientID)
+		_, err = s.CaptureHoldWithTx(tx, *token.HoldID, 0, Journal{
+			Type:        payment.txnType,
+			Description: fmt.Sprintf("QR payment %s", token.Token),
+			ReferenceID: payment.refID,
+			Postings: []Posting{
+				{WalletID: recipientWallet.ID, Direction: "credit", Amount: token.Amount, TxnType: payment.recipientType, Description: payment.recipientDesc},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		token.Status = "consumed"
+		return tx.Model(&PaymentToken{}).Where("id = ?", token.ID).Update("status", "consumed").Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishTokenEvent(token, realtime.EventTokenConsumed)
+	return token, nil
+}
+
+// VoidTokenPayment cancels an authorized QR payment and releases the reserved
+// points. The creator or the payer of the token can void it.
+func (s *WalletService) VoidTokenPayment(tokenCode string, userID uint) (*PaymentToken, error) {
+	var token *PaymentToken
+	err := s.Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, err = s.lockAuthorizedToken(tx, tokenCode)
+		if err != nil {
+			return err
+		}
+
+		wallet, err := s.repo.FindByUserID(userID)
+		if err != nil || (wallet.ID != token.WalletID && wallet.ID != *token.PayerWalletID) {
+			return errors.New("token bukan milik anda")
+		}
+
+		if err := s.voidTokenPaymentWithTx(tx, token, "voided"); err != nil {
+			return err
+		}
+		token.Status = "voided"
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishTokenEvent(token, realtime.EventTokenVoided)
+	return token, nil
+}
+
+func (s *WalletService) lockAuthorizedToken(tx *gorm.DB, tokenCode string) (*PaymentToken, error) {
+	var token PaymentToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token = ?", tokenCode).First(&token).Error
+	if err != nil {
+		return nil, errors.New("token tidak ditemukan")
+	}
+	if token.Status != "authorized" || token.HoldID == nil || token.PayerWalletID == nil {
+		return nil, errors.New("token tidak menunggu konfirmasi pembayaran")
+	}
+	return &token, nil
+}
+
+// voidTokenPaymentWithTx releases the hold of a token and puts back the
+// product reserved by a purchase
+func (s *WalletService) voidTokenPaymentWithTx(tx *gorm.DB, token *PaymentToken, holdStatus string) error {
+	if _, err := s.VoidHoldWithTx(tx, *token.HoldID, holdStatus); err != nil {
+		return err
+	}
+
+	if token.Type == "purchase" && token.ProductID != 0 {
+		if err := tx.Table("products").Where("id = ?", token.ProductID).Update("stock", gorm.Expr("stock + ?", 1)).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&PaymentToken{}).Where("id = ?", token.ID).Update("status", "voided").Error
+}
+
+// findTokenByHold locks and returns the token a hold was authorized for, or nil
+func (s *WalletService) findTokenByHold(tx *gorm.DB, holdID uint) (*PaymentToken, error) {
+	var token PaymentToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hold_id = ? AND status = ?", holdID, "authorized").First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
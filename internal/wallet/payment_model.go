@@ -3,18 +3,26 @@ package wallet
 import "time"
 
 type PaymentToken struct {
-	ID           uint      `json:"id" gorm:"primaryKey"`
-	Token        string    `json:"token" gorm:"type:varchar(191);uniqueIndex;not null"`
-	QRCodeBase64 string    `json:"qr_code_base64" gorm:"type:text"`
-	Amount       int       `json:"amount" gorm:"not null"`
-	Merchant     string    `json:"merchant" gorm:"size:255"` // Added for UX
-	Expiry       time.Time `json:"expiry" gorm:"not null"`
-	WalletID     uint      `json:"wallet_id" gorm:"not null"` // Creator
-	RecipientID  uint      `json:"recipient_id"`              // Who gets the money
-	Status       string    `json:"status" gorm:"type:enum('active','consumed','expired');default:'active'"`
-	Type         string    `json:"type" gorm:"size:50"` // "purchase" or "transfer"
-	ProductID    uint      `json:"product_id"`          // For marketplace purchases
-	CreatedAt    time.Time `json:"created_at"`
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Token          string    `json:"token" gorm:"type:varchar(191);uniqueIndex;not null"`
+	QRCodeBase64   string    `json:"qr_code_base64" gorm:"type:text"`
+	QRPayload      string    `json:"qr_payload" gorm:"type:text"` // Signed content of the QR code
+	Amount         int       `json:"amount" gorm:"not null"`
+	Merchant       string    `json:"merchant" gorm:"size:255"` // Added for UX
+	Expiry         time.Time `json:"expiry" gorm:"not null"`
+	WalletID       uint      `json:"wallet_id" gorm:"not null"` // Creator
+	RecipientID    uint      `json:"recipient_id"`              // Who gets the money
+	Status         string    `json:"status" gorm:"type:enum('active','partially_paid','authorized','consumed','voided','expired','revoked','refunded');default:'active'"`
+	Type           string    `json:"type" gorm:"size:50"`                             // "purchase", "transfer", "static" or "split"
+	ProductID      uint      `json:"product_id"`                                      // For marketplace purchases
+	CaptureMode    string    `json:"capture_mode" gorm:"size:20;default:'immediate'"` // "manual" reserves the points until the creator captures them
+	PayerWalletID  *uint     `json:"payer_wallet_id"`
+	HoldID         *uint     `json:"hold_id"`
+	MinAmount      *int      `json:"min_amount,omitempty"` // Bounds of the amount entered for static tokens
+	MaxAmount      *int      `json:"max_amount,omitempty"`
+	PaidAmount     int       `json:"paid_amount"`      // Collected so far by a split token
+	RefundOnExpiry bool      `json:"refund_on_expiry"` // Refund a split token's contributions if it expires unpaid
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 func (PaymentToken) TableName() string {
@@ -22,9 +30,20 @@ func (PaymentToken) TableName() string {
 }
 
 type PaymentTokenRequest struct {
-	Amount      int    `json:"amount" binding:"required,gt=0"`
-	Merchant    string `json:"merchant"` // Optional display name
-	Type        string `json:"type" binding:"required,oneof=purchase transfer"`
-	RecipientID uint   `json:"recipient_id"`
-	ProductID   uint   `json:"product_id"`
+	Amount         int    `json:"amount" binding:"omitempty,gt=0"` // Required except for static tokens
+	Merchant       string `json:"merchant"`                        // Optional display name
+	Type           string `json:"type" binding:"required,oneof=purchase transfer static split"`
+	RecipientID    uint   `json:"recipient_id"`
+	ProductID      uint   `json:"product_id"`
+	CaptureMode    string `json:"capture_mode" binding:"omitempty,oneof=immediate manual"`
+	MinAmount      *int   `json:"min_amount" binding:"omitempty,gt=0"`
+	MaxAmount      *int   `json:"max_amount" binding:"omitempty,gt=0"`
+	RefundOnExpiry bool   `json:"refund_on_expiry"` // Split tokens only
+}
+
+type PaymentExecuteRequest struct {
+	Token   string `json:"token"`                      // Optional, must match the payload when sent
+	Payload string `json:"payload" binding:"required"` // Signed QR payload as scanned, the token code alone is not accepted
+	Amount  int    `json:"amount"`                     // Entered by the payer for static tokens, the share paid of a split token (defaults to what is left)
+	PIN     string `json:"pin" binding:"required"`
 }
@@ -0,0 +1,135 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"wallet-point/utils"
+)
+
+var (
+	ErrInvalidQRPayload = errors.New("format QR tidak dikenali")
+	ErrQRSignature      = errors.New("tanda tangan QR tidak valid")
+	ErrQRMismatch       = errors.New("QR tidak sesuai dengan data pembayaran")
+)
+
+func qrClaims(token *PaymentToken) QRClaims {
+	claims := QRClaims{
+		Token:       token.Token,
+		Amount:      token.Amount,
+		RecipientID: token.RecipientID,
+		Merchant:    token.Merchant,
+		Type:        token.Type,
+		Expiry:      token.Expiry.Unix(),
+	}
+	if token.MinAmount != nil {
+		claims.MinAmount = *token.MinAmount
+	}
+	if token.MaxAmount != nil {
+		claims.MaxAmount = *token.MaxAmount
+	}
+	return claims
+}
+
+// encodeQRPayload builds the signed payload shown in the QR code of a token
+func encodeQRPayload(token *PaymentToken) (string, error) {
+	body, err := json.Marshal(qrClaims(token))
+	if err != nil {
+		return "", err
+	}
+	signed := QRPayloadVersion + "." + base64.RawURLEncoding.EncodeToString(body)
+	return signed + "." + utils.Sign(signed), nil
+}
+
+// parseQRPayload checks the signature of a payload and returns its claims
+func parseQRPayload(payload string) (*QRClaims, error) {
+	parts := strings.Split(strings.TrimSpace(payload), ".")
+	if len(parts) != 3 || parts[0] != QRPayloadVersion {
+		return nil, ErrInvalidQRPayload
+	}
+
+	if !utils.VerifySignature(parts[0]+"."+parts[1], parts[2]) {
+		return nil, ErrQRSignature
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidQRPayload
+	}
+	var claims QRClaims
+	if err := json.Unmarshal(body, &claims); err != nil || claims.Token == "" {
+		return nil, ErrInvalidQRPayload
+	}
+	return &claims, nil
+}
+
+// verifyTokenPayload checks that a scanned payload was signed for this token
+// and still describes it
+func verifyTokenPayload(token *PaymentToken, payload string) error {
+	claims, err := parseQRPayload(payload)
+	if err != nil {
+		return err
+	}
+	if *claims != qrClaims(token) {
+		return ErrQRMismatch
+	}
+	return nil
+}
+
+// VerifyQRPayload lets scanning apps check a QR code before asking for the
+// PIN. A forged or altered code comes back as not valid rather than an error.
+// It only reads: a token past its expiry is reported as expired but left for
+// the sweeper to mark, so anyone scanning cannot write to it.
+func (s *WalletService) VerifyQRPayload(payload string) (*QRVerification, error) {
+	claims, err := parseQRPayload(payload)
+	if err == ErrInvalidQRPayload {
+		return nil, err
+	}
+	if err != nil {
+		return &QRVerification{Reason: err.Error()}, nil
+	}
+
+	var token PaymentToken
+	if err := s.db.Where("token = ?", claims.Token).First(&token).Error; err != nil {
+		return &QRVerification{Reason: "token tidak ditemukan"}, nil
+	}
+	if err := verifyTokenPayload(&token, payload); err != nil {
+		return &QRVerification{Reason: err.Error()}, nil
+	}
+	if token.Status == "active" && time.Now().After(token.Expiry) {
+		token.Status = "expired"
+	}
+
+	result := &QRVerification{
+		Valid:      true,
+		Token:      token.Token,
+		Amount:     token.Amount,
+		MinAmount:  token.MinAmount,
+		MaxAmount:  token.MaxAmount,
+		PaidAmount: token.PaidAmount,
+		Merchant:   token.Merchant,
+		Type:       token.Type,
+		Status:     token.Status,
+		Expiry:     token.Expiry,
+	}
+
+	// The merchant name is typed in by the creator, the recipient is what
+	// the server actually pays
+	recipientID, err := s.resolveTokenRecipient(&token)
+	if err == nil {
+		var recipient struct {
+			FullName string
+			Role     string
+		}
+		s.db.Table("users").Where("id = ?", recipientID).Select("full_name, role").Scan(&recipient)
+		if recipient.FullName != "" {
+			result.VerifiedMerchant = true
+			result.RecipientName = recipient.FullName
+			result.RecipientRole = recipient.Role
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,57 @@
+//go:build integration
+
+package wallet_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+	"wallet-point/internal/wallet"
+	"wallet-point/utils"
+)
+
+func TestVerifyQRPayloadLeavesExpiredTokensAlone(t *testing.T) {
+	env := setupIntegration(t)
+	utils.InitSigning("test-signing-key")
+	merchant := env.createStudent(t, "merchant", 0)
+
+	token := &wallet.PaymentToken{
+		Token:       fmt.Sprintf("expired-%d", time.Now().UnixNano()),
+		Amount:      10,
+		Expiry:      time.Now().Add(-time.Minute).Truncate(time.Second),
+		WalletID:    merchant.ID,
+		RecipientID: merchant.UserID,
+		Status:      "active",
+		Type:        "purchase",
+		CaptureMode: "immediate",
+	}
+	if err := env.db.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(wallet.QRClaims{
+		Token:       token.Token,
+		Amount:      token.Amount,
+		RecipientID: token.RecipientID,
+		Type:        token.Type,
+		Expiry:      token.Expiry.Unix(),
+	})
+	signed := wallet.QRPayloadVersion + "." + base64.RawURLEncoding.EncodeToString(body)
+
+	result, err := env.wallets.VerifyQRPayload(signed + "." + utils.Sign(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.Status != "expired" {
+		t.Errorf("VerifyQRPayload() valid %v status %s, want a valid, expired token", result.Valid, result.Status)
+	}
+
+	var stored wallet.PaymentToken
+	if err := env.db.First(&stored, token.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "active" {
+		t.Errorf("stored token status %s, want it left active for the sweeper", stored.Status)
+	}
+}
@@ -0,0 +1,43 @@
+package wallet
+
+import "time"
+
+// QRPayloadVersion prefixes signed QR payloads. Payloads look like
+// WPT2.<base64url claims>.<base64url signature>, older WPT:<token>:... codes
+// are not signed and cannot be verified.
+const QRPayloadVersion = "WPT2"
+
+// QRClaims is the signed content of a QR payload
+type QRClaims struct {
+	Token       string `json:"tok"`
+	Amount      int    `json:"amt"`
+	RecipientID uint   `json:"rcp"`
+	Merchant    string `json:"mer,omitempty"`
+	Type        string `json:"typ"`
+	Expiry      int64  `json:"exp"`
+	MinAmount   int    `json:"min,omitempty"`
+	MaxAmount   int    `json:"max,omitempty"`
+}
+
+type VerifyQRRequest struct {
+	Payload string `json:"payload" binding:"required"`
+}
+
+// QRVerification tells a scanning app whether a QR code was issued by the
+// server and who will receive the payment
+type QRVerification struct {
+	Valid            bool      `json:"valid"`
+	VerifiedMerchant bool      `json:"verified_merchant"`
+	Reason           string    `json:"reason,omitempty"`
+	Token            string    `json:"token,omitempty"`
+	Amount           int       `json:"amount,omitempty"`
+	MinAmount        *int      `json:"min_amount,omitempty"`
+	MaxAmount        *int      `json:"max_amount,omitempty"`
+	PaidAmount       int       `json:"paid_amount,omitempty"` // Collected so far by a split token
+	Merchant         string    `json:"merchant,omitempty"`
+	RecipientName    string    `json:"recipient_name,omitempty"`
+	RecipientRole    string    `json:"recipient_role,omitempty"`
+	Type             string    `json:"type,omitempty"`
+	Status           string    `json:"status,omitempty"`
+	Expiry           time.Time `json:"expiry,omitempty"`
+}
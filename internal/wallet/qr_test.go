@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"wallet-point/utils"
+)
+
+func TestParseQRPayload(t *testing.T) {
+	utils.InitSigning("test-signing-key")
+
+	minAmount := 5
+	token := &PaymentToken{
+		Token:       "abc123",
+		Amount:      50,
+		RecipientID: 7,
+		Merchant:    "Kantin",
+		Type:        "static",
+		Expiry:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		MinAmount:   &minAmount,
+	}
+	payload, err := encodeQRPayload(token)
+	if err != nil {
+		t.Fatalf("encodeQRPayload() error = %v", err)
+	}
+
+	claims, err := parseQRPayload(payload)
+	if err != nil {
+		t.Fatalf("parseQRPayload() error = %v", err)
+	}
+	if *claims != qrClaims(token) {
+		t.Errorf("parseQRPayload() = %+v, want %+v", *claims, qrClaims(token))
+	}
+	if err := verifyTokenPayload(token, payload); err != nil {
+		t.Errorf("verifyTokenPayload() error = %v", err)
+	}
+
+	changed := *token
+	changed.Amount = 500
+	if err := verifyTokenPayload(&changed, payload); err != ErrQRMismatch {
+		t.Errorf("verifyTokenPayload() on changed token error = %v, want ErrQRMismatch", err)
+	}
+
+	parts := strings.Split(payload, ".")
+	forged, _ := encodeQRPayload(&changed)
+	forgedParts := strings.Split(forged, ".")
+
+	tests := []struct {
+		name    string
+		payload string
+		want    error
+	}{
+		{"legacy format", "WPT:abc123:50", ErrInvalidQRPayload},
+		{"wrong version", "WPT1." + parts[1] + "." + parts[2], ErrInvalidQRPayload},
+		{"missing signature", parts[0] + "." + parts[1], ErrInvalidQRPayload},
+		{"claims swapped", parts[0] + "." + forgedParts[1] + "." + parts[2], ErrQRSignature},
+		{"bad signature", parts[0] + "." + parts[1] + ".AAAA", ErrQRSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseQRPayload(tt.payload); err != tt.want {
+				t.Errorf("parseQRPayload() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	utils.InitSigning("another-key")
+	if _, err := parseQRPayload(payload); err != ErrQRSignature {
+		t.Errorf("parseQRPayload() with another key error = %v, want ErrQRSignature", err)
+	}
+}
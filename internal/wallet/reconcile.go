@@ -0,0 +1,192 @@
+package wallet
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reconcile compares every wallet balance with the sum of its successful
+// transactions (credits minus debits) and reports the wallets that drifted.
+//
+// With repair set, a correction journal is posted for each drifting wallet
+// so its history adds up to the balance again. The balance itself is
+// left alone: it is kept equal to the wallet's ledger account by PostJournal,
+// drift means history rows are missing, e.g. from resets made before the
+// ledger existed.
+func (s *WalletService) Reconcile(repair bool, createdBy string) (*ReconciliationReport, error) {
+	drifts, err := s.repo.GetBalanceDrifts(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconciliationReport{
+		CheckedAt:      time.Now(),
+		WalletsChecked: len(drifts),
+		Repaired:       repair,
+		Drifts:         []WalletDrift{},
+	}
+
+	for _, d := range drifts {
+		d.Drift = d.Balance - d.ComputedBalance
+		if d.Drift == 0 {
+			continue
+		}
+
+		if repair {
+			corrected, err := s.correctDrift(d.WalletID, createdBy)
+			if err != nil {
+				log.Printf("[Reconcile] Failed to correct wallet %d: %v", d.WalletID, err)
+			} else if corrected != nil {
+				d = *corrected
+			}
+		}
+
+		report.DriftCount++
+		if d.Drift < 0 {
+			report.TotalDrift -= d.Drift
+		} else {
+			report.TotalDrift += d.Drift
+		}
+		report.Drifts = append(report.Drifts, d)
+	}
+
+	return report, nil
+}
+
+// correctDrift recomputes the drift of one wallet under lock and posts the
+// correction. It returns nil if the drift went away in the meantime.
+//
+// The missing history is posted as a journal against the corrections
+// account. A journal moves the balance along with the history, but these
+// points are already in the balance, so the same amount is taken back off in
+// the ledger only, the way opening balances are brought in. The wallet keeps
+// its balance and lots, and the corrections account nets to zero.
+func (s *WalletService) correctDrift(walletID uint, createdBy string) (*WalletDrift, error) {
+	createdBy = firstNonEmpty(createdBy, "system")
+	var corrected *WalletDrift
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		wallet, err := s.repo.LockByID(tx, walletID)
+		if err != nil {
+			return err
+		}
+		// Lots made up later from the raised balance would not be undone
+		if err := s.seedLots(tx, wallet); err != nil {
+			return err
+		}
+
+		drifts, err := s.repo.GetBalanceDrifts(tx, walletID)
+		if err != nil || len(drifts) == 0 {
+			return err
+		}
+		d := drifts[0]
+		d.Drift = d.Balance - d.ComputedBalance
+		if d.Drift == 0 {
+			return nil
+		}
+
+		description := fmt.Sprintf("Reconciliation correction: history %d, balance %d", d.ComputedBalance, d.Balance)
+		journal := func(walletDir, accountDir string, amount int) (*WalletTransaction, error) {
+			txns, err := s.PostJournal(tx, Journal{
+				Type:        "correction",
+				Description: description,
+				CreatedBy:   createdBy,
+				Postings: []Posting{
+					{WalletID: walletID, Direction: walletDir, Amount: amount},
+					{Account: AccountCorrections, Direction: accountDir, Amount: amount},
+				},
+				// The balance does not change, so neither a closed wallet
+				// nor a freeze stands in the way of fixing its history
+				AllowInactive: true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &txns[0], nil
+		}
+
+		if d.Drift > 0 {
+			txn, err := journal("credit", "debit", d.Drift)
+			if err != nil {
+				return err
+			}
+			if err := s.shiftUnrecorded(tx, walletID, -d.Drift, description, createdBy); err != nil {
+				return err
+			}
+			if err := s.repo.DeleteLotsOfTransaction(tx, txn.ID); err != nil {
+				return err
+			}
+		} else {
+			if err := s.shiftUnrecorded(tx, walletID, -d.Drift, description, createdBy); err != nil {
+				return err
+			}
+			txn, err := journal("debit", "credit", -d.Drift)
+			if err != nil {
+				return err
+			}
+			if err := s.repo.RestoreLotSpends(tx, txn.ID); err != nil {
+				return err
+			}
+		}
+
+		after, err := s.repo.GetBalanceDrifts(tx, walletID)
+		if err != nil {
+			return err
+		}
+		if len(after) != 1 || after[0].Balance != d.Balance || after[0].ComputedBalance != d.Balance {
+			return fmt.Errorf("%w (wallet %d still drifts after correction)", ErrLedgerMismatch, walletID)
+		}
+
+		d.Corrected = true
+		corrected = &d
+		return nil
+	})
+	return corrected, err
+}
+
+// shiftUnrecorded moves points between a wallet and the corrections account
+// in the ledger only, without a history row or lots
+func (s *WalletService) shiftUnrecorded(tx *gorm.DB, walletID uint, delta int, description, createdBy string) error {
+	account, err := s.walletAccount(tx, walletID)
+	if err != nil {
+		return err
+	}
+	corrections, err := s.systemAccount(tx, AccountCorrections)
+	if err != nil {
+		return err
+	}
+
+	journal := &LedgerJournal{
+		Type:        "correction",
+		Description: description,
+		CreatedBy:   createdBy,
+	}
+	if err := s.repo.CreateJournal(tx, journal); err != nil {
+		return err
+	}
+
+	walletDir, accountDir, amount := "credit", "debit", delta
+	if delta < 0 {
+		walletDir, accountDir, amount = "debit", "credit", -delta
+	}
+
+	lines := []LedgerLine{
+		{JournalID: journal.ID, AccountID: account.ID, Direction: walletDir, Amount: amount},
+		{JournalID: journal.ID, AccountID: corrections.ID, Direction: accountDir, Amount: amount},
+	}
+	for i := range lines {
+		if err := s.repo.CreateLedgerLine(tx, &lines[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.UpdateBalance(tx, walletID, delta); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateLedgerAccountBalance(tx, account.ID, delta); err != nil {
+		return err
+	}
+	return s.repo.UpdateLedgerAccountBalance(tx, corrections.ID, -delta)
+}
@@ -0,0 +1,82 @@
+//go:build integration
+
+package wallet_test
+
+import (
+	"testing"
+	"wallet-point/internal/wallet"
+)
+
+func TestReconcileRepairsHistoryWithoutMovingPoints(t *testing.T) {
+	env := setupIntegration(t)
+	missing := env.createStudent(t, "missing", 100)
+	extra := env.createStudent(t, "extra", 100)
+	if _, err := env.wallets.AdjustPoints(&wallet.AdjustmentRequest{
+		WalletID:    extra.ID,
+		Amount:      30,
+		Direction:   "debit",
+		Description: "Reconcile test debit",
+	}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hide a history row of each wallet, as a reset made before the ledger did
+	hide := func(walletID uint, direction string) {
+		err := env.db.Model(&wallet.WalletTransaction{}).
+			Where("wallet_id = ? AND direction = ?", walletID, direction).
+			Update("status", "failed").Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	hide(missing.ID, "credit")
+	hide(extra.ID, "debit")
+
+	lots := func(walletID uint) int {
+		var sum int
+		env.db.Table("point_lots").Where("wallet_id = ?", walletID).Select("COALESCE(SUM(remaining), 0)").Scan(&sum)
+		return sum
+	}
+	lotsBefore := map[uint]int{missing.ID: lots(missing.ID), extra.ID: lots(extra.ID)}
+	correctionsBefore := env.accountBalance(t, wallet.AccountCorrections)
+
+	report, err := env.wallets.Reconcile(true, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint]int{missing.ID: 100, extra.ID: -30}
+	for _, d := range report.Drifts {
+		if drift, ok := want[d.WalletID]; ok {
+			if d.Drift != drift || !d.Corrected {
+				t.Errorf("wallet %d drift %d corrected %v, want %d corrected", d.WalletID, d.Drift, d.Corrected, drift)
+			}
+			delete(want, d.WalletID)
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("drifts not reported: %v", want)
+	}
+
+	check, err := env.wallets.Reconcile(false, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range check.Drifts {
+		if d.WalletID == missing.ID || d.WalletID == extra.ID {
+			t.Errorf("wallet %d still drifts by %d", d.WalletID, d.Drift)
+		}
+	}
+
+	for id, balance := range map[uint]int{missing.ID: 100, extra.ID: 70} {
+		if got := env.reload(t, id).Balance; got != balance {
+			t.Errorf("wallet %d balance %d, want %d", id, got, balance)
+		}
+		if got := lots(id); got != lotsBefore[id] {
+			t.Errorf("wallet %d lots %d, want %d", id, got, lotsBefore[id])
+		}
+	}
+	if got := env.accountBalance(t, wallet.AccountCorrections); got != correctionsBefore {
+		t.Errorf("corrections account moved by %d", got-correctionsBefore)
+	}
+	env.checkLedger(t, env.reload(t, missing.ID), env.reload(t, extra.ID))
+}
@@ -0,0 +1,25 @@
+package wallet
+
+import "time"
+
+// WalletDrift compares a wallet's stored balance with the balance its
+// successful transactions add up to.
+type WalletDrift struct {
+	WalletID        uint   `json:"wallet_id"`
+	UserID          uint   `json:"user_id"`
+	FullName        string `json:"full_name"`
+	NimNip          string `json:"nim_nip"`
+	Balance         int    `json:"balance"`
+	ComputedBalance int    `json:"computed_balance"`
+	Drift           int    `json:"drift"` // Balance minus computed balance
+	Corrected       bool   `json:"corrected"`
+}
+
+type ReconciliationReport struct {
+	CheckedAt      time.Time     `json:"checked_at"`
+	WalletsChecked int           `json:"wallets_checked"`
+	DriftCount     int           `json:"drift_count"`
+	TotalDrift     int           `json:"total_drift"` // Sum of absolute drift
+	Repaired       bool          `json:"repaired"`
+	Drifts         []WalletDrift `json:"drifts"`
+}
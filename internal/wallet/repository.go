@@ -2,8 +2,10 @@ package wallet
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WalletRepository struct {
@@ -40,17 +42,81 @@ func (r *WalletRepository) FindByUserID(userID uint) (*Wallet, error) {
 	return &wallet, nil
 }
 
+// LockByID finds wallet by ID and locks the row until the transaction ends
+func (r *WalletRepository) LockByID(tx *gorm.DB, walletID uint) (*Wallet, error) {
+	var wallet Wallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wallet, walletID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("wallet not found")
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
 // GetAllWithUsers gets all wallets with user information
 func (r *WalletRepository) GetAllWithUsers() ([]WalletWithUser, error) {
 	var wallets []WalletWithUser
 	err := r.db.Table("wallets").
-		Select("wallets.id as wallet_id, users.id as user_id, users.email, users.full_name, users.nim_nip, users.role, wallets.balance, wallets.last_sync_at").
+		Select("wallets.id as wallet_id, users.id as user_id, users.email, users.full_name, users.nim_nip, users.role, wallets.balance, wallets.status, wallets.last_sync_at").
 		Joins("INNER JOIN users ON wallets.user_id = users.id").
 		Order("wallets.balance DESC").
 		Scan(&wallets).Error
 	return wallets, err
 }
 
+// UpdateStatus changes the status of a wallet
+func (r *WalletRepository) UpdateStatus(tx *gorm.DB, walletID uint, status, reason string, frozenUntil *time.Time) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&Wallet{}).Where("id = ?", walletID).Updates(map[string]interface{}{
+		"status":        status,
+		"status_reason": reason,
+		"frozen_until":  frozenUntil,
+	}).Error
+}
+
+// FindOwnerStatus returns the account status of the user owning a wallet
+func (r *WalletRepository) FindOwnerStatus(tx *gorm.DB, walletID uint) (string, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var status string
+	err := tx.Table("wallets").
+		Select("users.status").
+		Joins("INNER JOIN users ON users.id = wallets.user_id").
+		Where("wallets.id = ?", walletID).
+		Scan(&status).Error
+	return status, err
+}
+
+// GetExpiredFreezes returns frozen wallets whose freeze ended before now
+func (r *WalletRepository) GetExpiredFreezes(now time.Time) ([]Wallet, error) {
+	var wallets []Wallet
+	err := r.db.Where("status = ? AND frozen_until IS NOT NULL AND frozen_until <= ?", "frozen", now).
+		Find(&wallets).Error
+	return wallets, err
+}
+
+// DebitBalance subtracts from wallet balance only if enough points are available
+func (r *WalletRepository) DebitBalance(tx *gorm.DB, walletID uint, amount int) error {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Model(&Wallet{}).
+		Where("id = ? AND balance >= ?", walletID, amount).
+		Update("balance", gorm.Expr("balance - ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
+
 // CreateTransaction creates a new wallet transaction
 func (r *WalletRepository) CreateTransaction(tx *gorm.DB, transaction *WalletTransaction) error {
 	if tx == nil {
@@ -70,17 +136,6 @@ func (r *WalletRepository) UpdateBalance(tx *gorm.DB, walletID uint, delta int)
 		Error
 }
 
-// SetBalance sets wallet balance to specific value (for reset)
-func (r *WalletRepository) SetBalance(tx *gorm.DB, walletID uint, newBalance int) error {
-	if tx == nil {
-		tx = r.db
-	}
-	return tx.Model(&Wallet{}).
-		Where("id = ?", walletID).
-		Update("balance", newBalance).
-		Error
-}
-
 // GetTransactions gets transactions with filters and pagination
 func (r *WalletRepository) GetTransactions(params TransactionListParams) ([]TransactionWithDetails, int64, error) {
 	var transactions []TransactionWithDetails
@@ -92,6 +147,9 @@ func (r *WalletRepository) GetTransactions(params TransactionListParams) ([]Tran
 		Joins("INNER JOIN users ON wallets.user_id = users.id")
 
 	// Apply filters
+	if params.WalletID != 0 {
+		query = query.Where("wallet_transactions.wallet_id = ?", params.WalletID)
+	}
 	if params.Type != "" {
 		query = query.Where("wallet_transactions.type = ?", params.Type)
 	}
@@ -113,9 +171,12 @@ func (r *WalletRepository) GetTransactions(params TransactionListParams) ([]Tran
 		return nil, 0, err
 	}
 
-	// Apply pagination
-	offset := (params.Page - 1) * params.Limit
-	query = query.Limit(params.Limit).Offset(offset).Order("wallet_transactions.created_at DESC")
+	// Apply pagination, a zero limit returns every matching row
+	if params.Limit > 0 {
+		offset := (params.Page - 1) * params.Limit
+		query = query.Limit(params.Limit).Offset(offset)
+	}
+	query = query.Order("wallet_transactions.created_at DESC, wallet_transactions.id DESC")
 
 	if err := query.Scan(&transactions).Error; err != nil {
 		return nil, 0, err
@@ -147,3 +208,560 @@ func (r *WalletRepository) GetLeaderboard(limit int) ([]WalletWithUser, error) {
 		Scan(&results).Error
 	return results, err
 }
+
+// FindLedgerAccountByWallet finds the ledger account that mirrors a wallet
+func (r *WalletRepository) FindLedgerAccountByWallet(tx *gorm.DB, walletID uint) (*LedgerAccount, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var account LedgerAccount
+	if err := tx.Where("wallet_id = ?", walletID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindLedgerAccountByCode finds a ledger account by its unique code
+func (r *WalletRepository) FindLedgerAccountByCode(tx *gorm.DB, code string) (*LedgerAccount, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var account LedgerAccount
+	if err := tx.Where("code = ?", code).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CreateLedgerAccount creates a new ledger account
+func (r *WalletRepository) CreateLedgerAccount(tx *gorm.DB, account *LedgerAccount) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(account).Error
+}
+
+// UpdateLedgerAccountBalance updates the running balance of a ledger account
+func (r *WalletRepository) UpdateLedgerAccountBalance(tx *gorm.DB, accountID uint, delta int) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&LedgerAccount{}).
+		Where("id = ?", accountID).
+		Update("balance", gorm.Expr("balance + ?", delta)).
+		Error
+}
+
+// CreateJournal creates a journal header
+func (r *WalletRepository) CreateJournal(tx *gorm.DB, journal *LedgerJournal) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(journal).Error
+}
+
+// CreateLedgerLine creates a single journal line
+func (r *WalletRepository) CreateLedgerLine(tx *gorm.DB, line *LedgerLine) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(line).Error
+}
+
+// GetLedgerLines gets the latest journal lines posted to a ledger account
+func (r *WalletRepository) GetLedgerLines(accountID uint, limit int) ([]LedgerLineWithJournal, error) {
+	var lines []LedgerLineWithJournal
+	err := r.db.Table("ledger_lines").
+		Select("ledger_lines.*, ledger_journals.type as journal_type, ledger_journals.description as journal_description").
+		Joins("INNER JOIN ledger_journals ON ledger_journals.id = ledger_lines.journal_id").
+		Where("ledger_lines.account_id = ?", accountID).
+		Order("ledger_lines.id DESC").
+		Limit(limit).
+		Scan(&lines).Error
+	return lines, err
+}
+
+// JournalLeg is a ledger line together with the account it was posted to
+type JournalLeg struct {
+	LedgerLine
+	AccountCode     string
+	AccountType     string
+	AccountWalletID *uint
+}
+
+// FindTransactionForUpdate finds a wallet transaction and locks it
+func (r *WalletRepository) FindTransactionForUpdate(tx *gorm.DB, txnID uint) (*WalletTransaction, error) {
+	var txn WalletTransaction
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&txn, txnID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("transaction not found")
+		}
+		return nil, err
+	}
+	return &txn, nil
+}
+
+// GetJournalLegs gets every line of the journal a wallet transaction was posted in
+func (r *WalletRepository) GetJournalLegs(tx *gorm.DB, walletTxnID uint) ([]JournalLeg, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var legs []JournalLeg
+	err := tx.Table("ledger_lines").
+		Select("ledger_lines.*, ledger_accounts.code as account_code, ledger_accounts.type as account_type, ledger_accounts.wallet_id as account_wallet_id").
+		Joins("INNER JOIN ledger_accounts ON ledger_accounts.id = ledger_lines.account_id").
+		Where("ledger_lines.journal_id = (?)", tx.Table("ledger_lines").Select("journal_id").Where("wallet_transaction_id = ?", walletTxnID).Limit(1)).
+		Order("ledger_lines.id ASC").
+		Scan(&legs).Error
+	return legs, err
+}
+
+// FindTransactionsByIDs gets wallet transactions by ID
+func (r *WalletRepository) FindTransactionsByIDs(tx *gorm.DB, ids []uint) ([]WalletTransaction, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var txns []WalletTransaction
+	err := tx.Where("id IN ?", ids).Order("id ASC").Find(&txns).Error
+	return txns, err
+}
+
+// MarkTransactionsReversed flags transactions as reversed, skipping ones that already are
+func (r *WalletRepository) MarkTransactionsReversed(tx *gorm.DB, ids []uint, at time.Time) (int64, error) {
+	result := tx.Model(&WalletTransaction{}).
+		Where("id IN ? AND reversed_at IS NULL", ids).
+		Update("reversed_at", at)
+	return result.RowsAffected, result.Error
+}
+
+// CountLots counts every lot a wallet ever had
+func (r *WalletRepository) CountLots(tx *gorm.DB, walletID uint) (int64, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var count int64
+	err := tx.Model(&PointLot{}).Where("wallet_id = ?", walletID).Count(&count).Error
+	return count, err
+}
+
+// CreateLot records a batch of credited points
+func (r *WalletRepository) CreateLot(tx *gorm.DB, lot *PointLot) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(lot).Error
+}
+
+// GetOpenLots returns a wallet's unspent lots, oldest expiry first
+func (r *WalletRepository) GetOpenLots(tx *gorm.DB, walletID uint) ([]PointLot, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var lots []PointLot
+	err := tx.Where("wallet_id = ? AND remaining > 0 AND expired_at IS NULL", walletID).
+		Order("expires_at ASC, id ASC").
+		Find(&lots).Error
+	return lots, err
+}
+
+// UpdateLotRemaining sets the unspent amount of a lot
+func (r *WalletRepository) UpdateLotRemaining(tx *gorm.DB, lotID uint, remaining int) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&PointLot{}).Where("id = ?", lotID).Update("remaining", remaining).Error
+}
+
+// CreateLotSpend records the part of a lot used by a debit
+func (r *WalletRepository) CreateLotSpend(tx *gorm.DB, spend *LotSpend) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(spend).Error
+}
+
+// GetLotSpends returns the lots used by the given debits, oldest expiry first
+func (r *WalletRepository) GetLotSpends(tx *gorm.DB, txnIDs []uint) ([]LotSpend, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var spends []LotSpend
+	err := tx.Where("wallet_transaction_id IN ?", txnIDs).
+		Order("expires_at ASC, id ASC").
+		Find(&spends).Error
+	return spends, err
+}
+
+// DeleteLotsOfTransaction removes the lots opened by a credit
+func (r *WalletRepository) DeleteLotsOfTransaction(tx *gorm.DB, txnID uint) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Where("wallet_transaction_id = ?", txnID).Delete(&PointLot{}).Error
+}
+
+// RestoreLotSpends gives a debit's points back to the lots it was spent from
+// and forgets the spends
+func (r *WalletRepository) RestoreLotSpends(tx *gorm.DB, txnID uint) error {
+	if tx == nil {
+		tx = r.db
+	}
+	err := tx.Exec(`UPDATE point_lots l
+		JOIN point_lot_spends s ON s.lot_id = l.id
+		SET l.remaining = l.remaining + s.amount
+		WHERE s.wallet_transaction_id = ?`, txnID).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("wallet_transaction_id = ?", txnID).Delete(&LotSpend{}).Error
+}
+
+// GetExpiredLots returns a wallet's unspent lots whose expiry has passed
+func (r *WalletRepository) GetExpiredLots(tx *gorm.DB, walletID uint, now time.Time) ([]PointLot, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var lots []PointLot
+	err := tx.Where("wallet_id = ? AND remaining > 0 AND expired_at IS NULL AND expires_at <= ?", walletID, now).
+		Order("expires_at ASC, id ASC").
+		Find(&lots).Error
+	return lots, err
+}
+
+// MarkLotsExpired closes lots that have been expired
+func (r *WalletRepository) MarkLotsExpired(tx *gorm.DB, lotIDs []uint, at time.Time) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&PointLot{}).Where("id IN ?", lotIDs).Updates(map[string]interface{}{
+		"remaining":  0,
+		"expired_at": at,
+	}).Error
+}
+
+// GetWalletsWithExpiredLots lists wallets holding points past their expiry
+func (r *WalletRepository) GetWalletsWithExpiredLots(now time.Time) ([]uint, error) {
+	var walletIDs []uint
+	err := r.db.Model(&PointLot{}).
+		Where("remaining > 0 AND expired_at IS NULL AND expires_at <= ?", now).
+		Distinct().
+		Pluck("wallet_id", &walletIDs).Error
+	return walletIDs, err
+}
+
+// GetExpiringLots returns unspent lots expiring before the given time
+func (r *WalletRepository) GetExpiringLots(walletID uint, before time.Time) ([]PointLot, error) {
+	var lots []PointLot
+	err := r.db.Where("wallet_id = ? AND remaining > 0 AND expired_at IS NULL AND expires_at <= ?", walletID, before).
+		Order("expires_at ASC, id ASC").
+		Find(&lots).Error
+	return lots, err
+}
+
+// GetBalanceDrifts recomputes every wallet's balance from its successful
+// transactions and returns the stored and computed values side by side
+func (r *WalletRepository) GetBalanceDrifts(tx *gorm.DB, walletID uint) ([]WalletDrift, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var drifts []WalletDrift
+	query := tx.Table("wallets w").
+		Select(`w.id as wallet_id, w.user_id, u.full_name, u.nim_nip, w.balance,
+			COALESCE(SUM(CASE WHEN t.direction = 'credit' THEN t.amount WHEN t.direction = 'debit' THEN -t.amount ELSE 0 END), 0) as computed_balance`).
+		Joins("LEFT JOIN wallet_transactions t ON t.wallet_id = w.id AND t.status = 'success'").
+		Joins("LEFT JOIN users u ON u.id = w.user_id").
+		Group("w.id, w.user_id, u.full_name, u.nim_nip, w.balance").
+		Order("w.id ASC")
+	if walletID != 0 {
+		query = query.Where("w.id = ?", walletID)
+	}
+	err := query.Scan(&drifts).Error
+	return drifts, err
+}
+
+// AdjustHeldBalance changes the amount of points reserved by holds
+func (r *WalletRepository) AdjustHeldBalance(tx *gorm.DB, walletID uint, delta int) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&Wallet{}).
+		Where("id = ?", walletID).
+		Update("held_balance", gorm.Expr("held_balance + ?", delta)).
+		Error
+}
+
+// CreateHold records a reservation of points
+func (r *WalletRepository) CreateHold(tx *gorm.DB, hold *WalletHold) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(hold).Error
+}
+
+// FindHoldForUpdate finds a hold and locks it until the transaction ends
+func (r *WalletRepository) FindHoldForUpdate(tx *gorm.DB, holdID uint) (*WalletHold, error) {
+	var hold WalletHold
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, holdID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("hold not found")
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// UpdateHold updates fields of a hold
+func (r *WalletRepository) UpdateHold(tx *gorm.DB, holdID uint, updates map[string]interface{}) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&WalletHold{}).Where("id = ?", holdID).Updates(updates).Error
+}
+
+// GetHolds lists the holds of a wallet, optionally filtered by status
+func (r *WalletRepository) GetHolds(walletID uint, status string) ([]WalletHold, error) {
+	var holds []WalletHold
+	query := r.db.Where("wallet_id = ?", walletID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at DESC").Find(&holds).Error
+	return holds, err
+}
+
+// GetExpiredHoldIDs lists active holds whose expiry has passed
+func (r *WalletRepository) GetExpiredHoldIDs(now time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&WalletHold{}).
+		Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", "active", now).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ConfirmPendingTransaction turns a pending wallet transaction into a successful one
+func (r *WalletRepository) ConfirmPendingTransaction(tx *gorm.DB, txnID uint, amount int) (*WalletTransaction, error) {
+	result := tx.Model(&WalletTransaction{}).
+		Where("id = ? AND status = ?", txnID, "pending").
+		Updates(map[string]interface{}{"status": "success", "amount": amount})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("pending transaction not found")
+	}
+
+	var txn WalletTransaction
+	if err := tx.First(&txn, txnID).Error; err != nil {
+		return nil, err
+	}
+	return &txn, nil
+}
+
+// UpdateTransactionStatus updates the status of a wallet transaction
+func (r *WalletRepository) UpdateTransactionStatus(tx *gorm.DB, txnID uint, status string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&WalletTransaction{}).Where("id = ?", txnID).Update("status", status).Error
+}
+
+// FindWithUser finds a wallet together with its owner
+func (r *WalletRepository) FindWithUser(walletID uint) (*WalletWithUser, error) {
+	var wallet WalletWithUser
+	err := r.db.Table("wallets").
+		Select("wallets.id as wallet_id, users.id as user_id, users.email, users.full_name, users.nim_nip, users.role, wallets.balance, wallets.status").
+		Joins("INNER JOIN users ON wallets.user_id = users.id").
+		Where("wallets.id = ?", walletID).
+		Scan(&wallet).Error
+	if err != nil {
+		return nil, err
+	}
+	if wallet.WalletID == 0 {
+		return nil, errors.New("wallet not found")
+	}
+	return &wallet, nil
+}
+
+// SumNetSince totals credits minus debits of successful transactions since a given time
+func (r *WalletRepository) SumNetSince(walletID uint, since time.Time) (int, error) {
+	var net int
+	err := r.db.Table("wallet_transactions").
+		Where("wallet_id = ? AND status = ? AND created_at >= ?", walletID, "success", since).
+		Select("COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)").
+		Scan(&net).Error
+	return net, err
+}
+
+// GetStaticTokens lists the static payment tokens created from a wallet
+func (r *WalletRepository) GetStaticTokens(walletID uint) ([]PaymentToken, error) {
+	var tokens []PaymentToken
+	err := r.db.Where("wallet_id = ? AND type = ?", walletID, "static").Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *WalletRepository) CreateTokenPayment(tx *gorm.DB, payment *TokenPayment) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(payment).Error
+}
+
+// GetTokenPayments lists the payments made through a token with their payers
+func (r *WalletRepository) GetTokenPayments(tokenID uint) ([]TokenPaymentWithPayer, error) {
+	var payments []TokenPaymentWithPayer
+	err := r.db.Table("token_payments").
+		Select("token_payments.*, users.full_name as payer_name, users.nim_nip as payer_nim_nip").
+		Joins("LEFT JOIN wallets ON wallets.id = token_payments.payer_wallet_id").
+		Joins("LEFT JOIN users ON users.id = wallets.user_id").
+		Where("token_payments.token_id = ?", tokenID).
+		Order("token_payments.created_at DESC").
+		Scan(&payments).Error
+	return payments, err
+}
+
+// GetExpiredActiveTokens lists active tokens whose expiry has passed, without
+// their QR images
+func (r *WalletRepository) GetExpiredActiveTokens(now time.Time, limit int) ([]PaymentToken, error) {
+	var tokens []PaymentToken
+	err := r.db.Omit("qr_code_base64", "qr_payload").
+		Where("status = ? AND expiry < ?", "active", now).
+		Order("id ASC").Limit(limit).
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// ExpireTokens marks tokens expired if they are still active
+func (r *WalletRepository) ExpireTokens(ids []uint) (int64, error) {
+	result := r.db.Model(&PaymentToken{}).
+		Where("id IN ? AND status = ?", ids, "active").
+		Update("status", "expired")
+	return result.RowsAffected, result.Error
+}
+
+// ClearTokenQRCodes drops the QR images of finished tokens created before a time
+func (r *WalletRepository) ClearTokenQRCodes(before time.Time, limit int) (int64, error) {
+	result := r.db.Model(&PaymentToken{}).
+		Where("status IN ? AND created_at < ? AND qr_code_base64 <> ?", []string{"consumed", "expired", "voided", "revoked"}, before, "").
+		Limit(limit).
+		Update("qr_code_base64", "")
+	return result.RowsAffected, result.Error
+}
+
+// PurgeTokens deletes finished tokens created before a time. Static and split
+// tokens are kept because their token_payments rows point at them.
+func (r *WalletRepository) PurgeTokens(before time.Time, limit int) (int64, error) {
+	result := r.db.Where("status IN ? AND type NOT IN ? AND created_at < ?", []string{"consumed", "expired", "voided"}, []string{"static", "split"}, before).
+		Limit(limit).
+		Delete(&PaymentToken{})
+	return result.RowsAffected, result.Error
+}
+
+// GetExpiredSplitTokenIDs lists partially paid split tokens whose expiry has passed
+func (r *WalletRepository) GetExpiredSplitTokenIDs(now time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&PaymentToken{}).
+		Where("type = ? AND status = ? AND expiry < ?", "split", "partially_paid", now).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetUnrefundedTokenPayments lists the payments of a token neither refunded
+// nor reversed yet
+func (r *WalletRepository) GetUnrefundedTokenPayments(tx *gorm.DB, tokenID uint) ([]TokenPayment, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var payments []TokenPayment
+	err := tx.Joins("INNER JOIN wallet_transactions ON wallet_transactions.id = token_payments.transaction_id").
+		Where("token_payments.token_id = ? AND token_payments.refunded_at IS NULL AND wallet_transactions.reversed_at IS NULL", tokenID).
+		Order("token_payments.id ASC").
+		Find(&payments).Error
+	return payments, err
+}
+
+// FindTokenPaymentByTransaction finds the token payment made by a debit
+func (r *WalletRepository) FindTokenPaymentByTransaction(tx *gorm.DB, txnID uint) (*TokenPayment, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var payment TokenPayment
+	if err := tx.Where("transaction_id = ?", txnID).First(&payment).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *WalletRepository) MarkTokenPaymentRefunded(tx *gorm.DB, paymentID uint, at time.Time) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&TokenPayment{}).Where("id = ?", paymentID).Update("refunded_at", at).Error
+}
+
+// FindWithUsersByNimNip finds the wallets of the users with the given NIM/NIPs
+func (r *WalletRepository) FindWithUsersByNimNip(nimNips []string) ([]WalletWithUser, error) {
+	var wallets []WalletWithUser
+	err := r.db.Table("wallets").
+		Select("wallets.id as wallet_id, users.id as user_id, users.email, users.full_name, users.nim_nip, users.role, wallets.balance, wallets.status").
+		Joins("INNER JOIN users ON wallets.user_id = users.id").
+		Where("users.nim_nip IN ?", nimNips).
+		Scan(&wallets).Error
+	return wallets, err
+}
+
+func (r *WalletRepository) CreateRewardBatch(tx *gorm.DB, batch *RewardBatch) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(batch).Error
+}
+
+func (r *WalletRepository) UpdateRewardBatch(tx *gorm.DB, batch *RewardBatch) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&RewardBatch{}).Where("id = ?", batch.ID).Updates(map[string]interface{}{
+		"status":         batch.Status,
+		"succeeded_rows": batch.SucceededRows,
+		"queued_rows":    batch.QueuedRows,
+		"failed_rows":    batch.FailedRows,
+		"total_amount":   batch.TotalAmount,
+	}).Error
+}
+
+func (r *WalletRepository) CreateRewardBatchItems(tx *gorm.DB, items []RewardBatchItem) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(&items).Error
+}
+
+// GetRewardBatches lists reward batches, all of them when createdByID is zero
+func (r *WalletRepository) GetRewardBatches(createdByID uint) ([]RewardBatch, error) {
+	var batches []RewardBatch
+	query := r.db.Order("created_at DESC")
+	if createdByID != 0 {
+		query = query.Where("created_by_id = ?", createdByID)
+	}
+	err := query.Find(&batches).Error
+	return batches, err
+}
+
+func (r *WalletRepository) FindRewardBatch(batchID uint) (*RewardBatch, error) {
+	var batch RewardBatch
+	if err := r.db.First(&batch, batchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("reward batch not found")
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (r *WalletRepository) GetRewardBatchItems(batchID uint) ([]RewardBatchItem, error) {
+	var items []RewardBatchItem
+	err := r.db.Where("batch_id = ?", batchID).Order("line ASC").Find(&items).Error
+	return items, err
+}
@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAlreadyReversed = errors.New("transaction has already been reversed")
+
+// ReversalHook undoes the side effects another module attached to a wallet
+// transaction, e.g. restocking products when a purchase is reversed. It runs
+// inside the reversal's database transaction.
+type ReversalHook func(tx *gorm.DB, original *WalletTransaction) error
+
+// RegisterReversalHook registers a hook for reversed transactions of a given type
+func (s *WalletService) RegisterReversalHook(txnType string, hook ReversalHook) {
+	if s.reversalHooks == nil {
+		s.reversalHooks = make(map[string][]ReversalHook)
+	}
+	s.reversalHooks[txnType] = append(s.reversalHooks[txnType], hook)
+}
+
+// ReverseTransaction undoes a wallet transaction by posting compensating entries
+func (s *WalletService) ReverseTransaction(txnID uint, reason string, createdBy string) (*ReversalResult, error) {
+	var result *ReversalResult
+	err := s.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = s.ReverseTransactionWithTx(tx, txnID, reason, createdBy)
+		return err
+	})
+	return result, err
+}
+
+// ReverseTransactionWithTx reverses the whole journal a wallet transaction
+// belongs to, so both sides of a transfer or QR payment are undone together.
+// Each compensating entry references the entry it reverses via ReferenceID.
+func (s *WalletService) ReverseTransactionWithTx(tx *gorm.DB, txnID uint, reason string, createdBy string) (*ReversalResult, error) {
+	original, err := s.repo.FindTransactionForUpdate(tx, txnID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Type == "reversal" {
+		return nil, errors.New("reversal entries cannot be reversed")
+	}
+	if original.Type == "correction" {
+		// Corrections only repair history, they never moved points
+		return nil, errors.New("correction entries cannot be reversed")
+	}
+	if original.ReversedAt != nil {
+		return nil, ErrAlreadyReversed
+	}
+	if original.Status != "success" {
+		return nil, errors.New("only successful transactions can be reversed")
+	}
+
+	legs, err := s.repo.GetJournalLegs(tx, original.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var postings []Posting
+	var originalIDs []uint
+
+	if len(legs) == 0 {
+		// Entries written before the ledger existed only have their own leg
+		ref := original.ID
+		postings = []Posting{
+			{WalletID: original.WalletID, Direction: oppositeDirection(original.Direction), Amount: original.Amount, ReferenceID: &ref},
+			{Account: AccountAdjustments, Direction: original.Direction, Amount: original.Amount},
+		}
+		if original.Direction == "debit" {
+			postings[0].LotSources = []uint{ref}
+		}
+		originalIDs = []uint{original.ID}
+	} else {
+		for _, leg := range legs {
+			p := Posting{Direction: oppositeDirection(leg.Direction), Amount: leg.Amount}
+			if leg.AccountType == "wallet" {
+				if leg.AccountWalletID == nil || leg.WalletTransactionID == nil {
+					return nil, fmt.Errorf("ledger line %d is not linked to a wallet transaction", leg.ID)
+				}
+				ref := *leg.WalletTransactionID
+				p.WalletID = *leg.AccountWalletID
+				p.ReferenceID = &ref
+				if p.Direction == "credit" {
+					// Refunded points keep the expiry they had when spent
+					p.LotSources = []uint{ref}
+				}
+				originalIDs = append(originalIDs, ref)
+			} else {
+				p.Account = leg.AccountCode
+			}
+			postings = append(postings, p)
+		}
+	}
+
+	reversed, err := s.repo.MarkTransactionsReversed(tx, originalIDs, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if reversed != int64(len(originalIDs)) {
+		return nil, ErrAlreadyReversed
+	}
+
+	description := fmt.Sprintf("Reversal of transaction #%d: %s", original.ID, reason)
+	for i := range postings {
+		postings[i].TxnType = "reversal"
+		postings[i].Description = description
+	}
+
+	compensating, err := s.PostJournal(tx, Journal{
+		Type:          "reversal",
+		Description:   description,
+		ReferenceID:   &original.ID,
+		CreatedBy:     createdBy,
+		AllowInactive: true,
+		Postings:      postings,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	originals, err := s.repo.FindTransactionsByIDs(tx, originalIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range originals {
+		for _, hook := range s.reversalHooks[originals[i].Type] {
+			if err := hook(tx, &originals[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &ReversalResult{
+		ReversedIDs:  originalIDs,
+		Compensating: compensating,
+	}, nil
+}
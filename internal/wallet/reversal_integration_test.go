@@ -0,0 +1,82 @@
+//go:build integration
+
+package wallet_test
+
+import (
+	"errors"
+	"testing"
+	"wallet-point/internal/wallet"
+
+	"gorm.io/gorm"
+)
+
+// transfer moves points between two wallets and returns the receiver's entry
+func (env *integrationEnv) transfer(t *testing.T, from, to *wallet.Wallet, amount int) *wallet.WalletTransaction {
+	t.Helper()
+	var in *wallet.WalletTransaction
+	err := env.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		_, in, err = env.wallets.TransferWithTransaction(tx, from.ID, to.ID, amount, "Reversal test", "Reversal test", nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+func TestReverseTransferUndoesBothSides(t *testing.T) {
+	env := setupIntegration(t)
+	sender := env.createStudent(t, "reverse-sender", 100)
+	receiver := env.createStudent(t, "reverse-receiver", 0)
+
+	in := env.transfer(t, sender, receiver, 30)
+
+	// Reversing either side undoes the whole journal
+	result, err := env.wallets.ReverseTransaction(in.ID, "Sent to the wrong student", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ReversedIDs) != 2 || len(result.Compensating) != 2 {
+		t.Errorf("reversed %d entries with %d compensating, want 2 and 2", len(result.ReversedIDs), len(result.Compensating))
+	}
+
+	s, r := env.reload(t, sender.ID), env.reload(t, receiver.ID)
+	if s.Balance != 100 || r.Balance != 0 {
+		t.Errorf("balances sender %d, receiver %d, want 100 and 0", s.Balance, r.Balance)
+	}
+	env.checkLedger(t, s, r)
+
+	if _, err := env.wallets.ReverseTransaction(in.ID, "Again", "admin"); !errors.Is(err, wallet.ErrAlreadyReversed) {
+		t.Errorf("second reversal error = %v, want ErrAlreadyReversed", err)
+	}
+	if _, err := env.wallets.ReverseTransaction(result.Compensating[0].ID, "Undo", "admin"); err == nil {
+		t.Error("reversing a reversal entry succeeded")
+	}
+}
+
+func TestReverseSpentTransferFails(t *testing.T) {
+	env := setupIntegration(t)
+	sender := env.createStudent(t, "spent-sender", 100)
+	receiver := env.createStudent(t, "spent-receiver", 0)
+	other := env.createStudent(t, "spent-other", 0)
+
+	in := env.transfer(t, sender, receiver, 30)
+	env.transfer(t, env.reload(t, receiver.ID), other, 30)
+
+	if _, err := env.wallets.ReverseTransaction(in.ID, "Too late", "admin"); !errors.Is(err, wallet.ErrInsufficientBalance) {
+		t.Fatalf("reversal error = %v, want ErrInsufficientBalance", err)
+	}
+
+	// Nothing is marked reversed, the transfer can still be reversed later
+	var reversed int64
+	env.db.Model(&wallet.WalletTransaction{}).Where("id = ? AND reversed_at IS NOT NULL", in.ID).Count(&reversed)
+	if reversed != 0 {
+		t.Error("failed reversal marked the transfer reversed")
+	}
+	s, r := env.reload(t, sender.ID), env.reload(t, receiver.ID)
+	if s.Balance != 70 || r.Balance != 0 {
+		t.Errorf("balances sender %d, receiver %d, want 70 and 0", s.Balance, r.Balance)
+	}
+	env.checkLedger(t, s, r)
+}
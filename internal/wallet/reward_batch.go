@@ -0,0 +1,330 @@
+package wallet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-point/internal/budget"
+
+	"gorm.io/gorm"
+)
+
+// ErrRewardBatchInvalid rejects an atomic batch that has invalid rows
+var ErrRewardBatchInvalid = errors.New("reward batch has invalid rows")
+
+// ParseRewardCSV reads reward rows from CSV. A header row naming the nim,
+// amount and description columns is optional, without one the columns are
+// taken in that order.
+func ParseRewardCSV(r io.Reader) ([]RewardBatchRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	columns := map[string]int{"nim": 0, "amount": 1, "description": 2}
+	if len(records) > 0 && isRewardCSVHeader(records[0]) {
+		columns = map[string]int{}
+		for i, name := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := columns["nim"]; !ok {
+			return nil, errors.New("CSV header must have a nim column")
+		}
+		if _, ok := columns["amount"]; !ok {
+			return nil, errors.New("CSV header must have an amount column")
+		}
+		records = records[1:]
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]RewardBatchRow, 0, len(records))
+	for _, record := range records {
+		// Amounts that are not numbers are left at zero and reported by validation
+		amount, _ := strconv.Atoi(field(record, "amount"))
+		rows = append(rows, RewardBatchRow{
+			NIM:         field(record, "nim"),
+			Amount:      amount,
+			Description: field(record, "description"),
+		})
+	}
+	return rows, nil
+}
+
+func isRewardCSVHeader(record []string) bool {
+	for _, name := range record {
+		if strings.EqualFold(strings.TrimSpace(name), "nim") {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessRewardBatch validates a batch of rewards and, unless it is a dry
+// run, credits them. Atomic batches credit every row in one transaction or
+// nothing at all; partial batches credit the valid rows and report the rest.
+// Rows beyond the lecturer's point budget may be queued for admin approval.
+// createdBy is the role recorded on every credit.
+func (s *WalletService) ProcessRewardBatch(req *RewardBatchRequest, userID uint, createdBy string) (*RewardBatchResult, error) {
+	if len(req.Rows) > MaxRewardBatchRows {
+		return nil, fmt.Errorf("a reward batch can have at most %d rows", MaxRewardBatchRows)
+	}
+	if req.Mode == "" {
+		req.Mode = "atomic"
+	}
+
+	items, err := s.validateRewardBatch(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RewardBatchResult{
+		DryRun:    req.DryRun,
+		Mode:      req.Mode,
+		TotalRows: len(items),
+		Rows:      items,
+	}
+	for _, item := range items {
+		if item.Status == "valid" {
+			result.ValidRows++
+			result.TotalAmount += item.Amount
+		} else {
+			result.InvalidRows++
+		}
+	}
+
+	if req.DryRun {
+		return result, nil
+	}
+	if result.ValidRows == 0 || (req.Mode == "atomic" && result.InvalidRows > 0) {
+		return result, ErrRewardBatchInvalid
+	}
+
+	batch := &RewardBatch{
+		CreatedByID: userID,
+		Mode:        req.Mode,
+		Description: req.Description,
+		Status:      "completed",
+		TotalRows:   len(items),
+	}
+
+	err = s.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.CreateRewardBatch(tx, batch); err != nil {
+			return err
+		}
+
+		// Credit in wallet order so concurrent batches lock wallets the same way
+		order := make([]int, 0, len(items))
+		for i := range items {
+			if items[i].Status == "valid" {
+				order = append(order, i)
+			} else {
+				items[i].Status = "failed"
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return *items[order[a]].WalletID < *items[order[b]].WalletID
+		})
+
+		for _, i := range order {
+			item := &items[i]
+			var err error
+			if req.Mode == "partial" {
+				// A savepoint per row keeps one failed credit from undoing the others
+				err = tx.Transaction(func(tx *gorm.DB) error {
+					return s.creditReward(tx, batch.ID, item, userID, createdBy)
+				})
+			} else {
+				err = s.creditReward(tx, batch.ID, item, userID, createdBy)
+			}
+
+			if err != nil {
+				if req.Mode == "atomic" {
+					return fmt.Errorf("row %d: %w", item.Line, err)
+				}
+				item.Status = "failed"
+				item.Error = err.Error()
+				continue
+			}
+			if item.Status == "queued" {
+				batch.QueuedRows++
+				continue
+			}
+			batch.SucceededRows++
+			batch.TotalAmount += item.Amount
+		}
+
+		batch.FailedRows = batch.TotalRows - batch.SucceededRows - batch.QueuedRows
+		switch {
+		case batch.SucceededRows == 0 && batch.QueuedRows == 0:
+			batch.Status = "failed"
+		case batch.FailedRows > 0:
+			batch.Status = "partial"
+		}
+
+		for i := range items {
+			items[i].BatchID = batch.ID
+		}
+		if err := s.repo.CreateRewardBatchItems(tx, items); err != nil {
+			return err
+		}
+		return s.repo.UpdateRewardBatch(tx, batch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	batch.Items = items
+	result.Batch = batch
+	result.Rows = items
+	return result, nil
+}
+
+// creditReward posts the credit of one batch row, or queues it for admin
+// approval when the lecturer's budget cannot cover it
+func (s *WalletService) creditReward(tx *gorm.DB, batchID uint, item *RewardBatchItem, userID uint, createdBy string) error {
+	queued, err := s.chargeBudget(tx, budget.Charge{
+		DosenID:     userID,
+		UserID:      *item.UserID,
+		Kind:        "reward",
+		Amount:      item.Amount,
+		Description: item.Description,
+		ReferenceID: &batchID,
+	})
+	if err != nil {
+		return err
+	}
+	if queued != nil {
+		item.Status = "queued"
+		item.RequestID = &queued.ID
+		return nil
+	}
+
+	txns, err := s.PostJournal(tx, Journal{
+		Type:        "reward",
+		Description: item.Description,
+		ReferenceID: &batchID,
+		CreatedBy:   createdBy,
+		Postings: []Posting{
+			{WalletID: *item.WalletID, Direction: "credit", Amount: item.Amount},
+			{Account: AccountLecturerRewards, Direction: "debit", Amount: item.Amount},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	item.Status = "credited"
+	item.TransactionID = &txns[0].ID
+	return nil
+}
+
+// validateRewardBatch resolves every row to a student wallet and marks it
+// valid or invalid
+func (s *WalletService) validateRewardBatch(req *RewardBatchRequest) ([]RewardBatchItem, error) {
+	var nims []string
+	for _, row := range req.Rows {
+		if nim := strings.TrimSpace(row.NIM); nim != "" {
+			nims = append(nims, nim)
+		}
+	}
+
+	wallets := make(map[string]WalletWithUser)
+	if len(nims) > 0 {
+		found, err := s.repo.FindWithUsersByNimNip(nims)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range found {
+			wallets[w.NimNip] = w
+		}
+	}
+
+	items := make([]RewardBatchItem, 0, len(req.Rows))
+	seen := make(map[string]int)
+
+	for i, row := range req.Rows {
+		item := RewardBatchItem{
+			Line:        i + 1,
+			NIM:         strings.TrimSpace(row.NIM),
+			Amount:      row.Amount,
+			Description: firstNonEmpty(strings.TrimSpace(row.Description), req.Description, "Lecturer reward"),
+			Status:      "valid",
+		}
+
+		w, found := wallets[item.NIM]
+		switch {
+		case item.NIM == "":
+			item.Error = "nim is required"
+		case item.Amount <= 0:
+			item.Error = "amount must be a positive number"
+		case len(item.Description) > 255:
+			item.Error = "description must be at most 255 characters"
+		case seen[item.NIM] != 0:
+			item.Error = fmt.Sprintf("duplicate of row %d", seen[item.NIM])
+		case !found || w.Role != "mahasiswa":
+			item.Error = "student not found"
+		case w.Status == "closed":
+			item.Error = "wallet is closed"
+		}
+
+		if found {
+			item.UserID, item.WalletID, item.FullName = &w.UserID, &w.WalletID, w.FullName
+		}
+		if item.Error == "" && w.Status == "frozen" {
+			// The freeze may have lapsed, which only loading the wallet tells
+			if wallet, err := s.repo.FindByID(w.WalletID); err != nil || wallet.isFrozen(time.Now()) {
+				item.Error = "wallet is frozen"
+			}
+		}
+		if item.Error != "" {
+			item.Status = "invalid"
+		}
+		if item.NIM != "" && seen[item.NIM] == 0 {
+			seen[item.NIM] = item.Line
+		}
+
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// GetRewardBatches lists the reward batches of a lecturer, all of them for admins
+func (s *WalletService) GetRewardBatches(userID uint, role string) ([]RewardBatch, error) {
+	if role == "admin" {
+		userID = 0
+	}
+	return s.repo.GetRewardBatches(userID)
+}
+
+// GetRewardBatch returns a reward batch with its per-row results. Lecturers
+// only see their own batches.
+func (s *WalletService) GetRewardBatch(batchID, userID uint, role string) (*RewardBatch, error) {
+	batch, err := s.repo.FindRewardBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if role != "admin" && batch.CreatedByID != userID {
+		return nil, errors.New("reward batch not found")
+	}
+
+	batch.Items, err = s.repo.GetRewardBatchItems(batch.ID)
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
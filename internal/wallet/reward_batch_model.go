@@ -0,0 +1,81 @@
+package wallet
+
+import "time"
+
+const (
+	// MaxRewardBatchRows caps the number of rows in one reward batch
+	MaxRewardBatchRows = 1000
+)
+
+// RewardBatch is one bulk reward run by a lecturer. Every credit it makes is
+// a "reward" transaction whose ReferenceID is the batch ID.
+type RewardBatch struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	CreatedByID   uint      `json:"created_by_id" gorm:"not null;index"`
+	Mode          string    `json:"mode" gorm:"type:enum('atomic','partial');not null"`
+	Description   string    `json:"description" gorm:"size:255"`
+	Status        string    `json:"status" gorm:"type:enum('completed','partial','failed');not null"`
+	TotalRows     int       `json:"total_rows"`
+	SucceededRows int       `json:"succeeded_rows"`
+	QueuedRows    int       `json:"queued_rows"` // Over the lecturer's budget, waiting for approval
+	FailedRows    int       `json:"failed_rows"`
+	TotalAmount   int       `json:"total_amount"` // Points actually credited
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Virtual fields for response
+	Items []RewardBatchItem `json:"items,omitempty" gorm:"-"`
+}
+
+func (RewardBatch) TableName() string {
+	return "reward_batches"
+}
+
+// RewardBatchItem is the outcome of one row of a reward batch. Dry runs report
+// rows as valid or invalid, executed batches as credited, queued or failed.
+type RewardBatchItem struct {
+	ID            uint   `json:"id,omitempty" gorm:"primaryKey"`
+	BatchID       uint   `json:"batch_id,omitempty" gorm:"not null;index"`
+	Line          int    `json:"line"` // 1-based row number in the upload
+	NIM           string `json:"nim" gorm:"size:50"`
+	UserID        *uint  `json:"user_id"`
+	WalletID      *uint  `json:"wallet_id"`
+	FullName      string `json:"full_name,omitempty" gorm:"-"`
+	Amount        int    `json:"amount"`
+	Description   string `json:"description" gorm:"size:255"`
+	Status        string `json:"status" gorm:"size:20;not null"`
+	Error         string `json:"error,omitempty" gorm:"size:255"`
+	TransactionID *uint  `json:"transaction_id"`
+	RequestID     *uint  `json:"request_id,omitempty"` // Reward request of a queued row
+}
+
+func (RewardBatchItem) TableName() string {
+	return "reward_batch_items"
+}
+
+// RewardBatchRow is one reward of a batch as uploaded by the lecturer
+type RewardBatchRow struct {
+	NIM         string `json:"nim"`
+	Amount      int    `json:"amount"`
+	Description string `json:"description"`
+}
+
+// RewardBatchRequest represents a reward batch sent as JSON. CSV uploads are
+// parsed into the same request.
+type RewardBatchRequest struct {
+	Rows        []RewardBatchRow `json:"rows" binding:"required,min=1"`
+	Description string           `json:"description" binding:"max=255"` // Used for rows without a description
+	Mode        string           `json:"mode" binding:"omitempty,oneof=atomic partial"`
+	DryRun      bool             `json:"dry_run"`
+}
+
+// RewardBatchResult is the per-row report of a reward batch
+type RewardBatchResult struct {
+	Batch       *RewardBatch      `json:"batch,omitempty"` // Nil on dry runs
+	DryRun      bool              `json:"dry_run"`
+	Mode        string            `json:"mode"`
+	TotalRows   int               `json:"total_rows"`
+	ValidRows   int               `json:"valid_rows"`
+	InvalidRows int               `json:"invalid_rows"`
+	TotalAmount int               `json:"total_amount"` // Points of the valid rows
+	Rows        []RewardBatchItem `json:"rows"`
+}
@@ -0,0 +1,66 @@
+package wallet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRewardCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []RewardBatchRow
+		wantErr bool
+	}{
+		{
+			name: "without header",
+			csv:  "2101001,50,Quiz winner\n2101002,25\n",
+			want: []RewardBatchRow{
+				{NIM: "2101001", Amount: 50, Description: "Quiz winner"},
+				{NIM: "2101002", Amount: 25},
+			},
+		},
+		{
+			name: "header in another order",
+			csv:  "Description, Amount, NIM\nBest project, 100, 2101003\n",
+			want: []RewardBatchRow{
+				{NIM: "2101003", Amount: 100, Description: "Best project"},
+			},
+		},
+		{
+			name: "amount that is not a number",
+			csv:  "nim,amount\n2101004,ten\n",
+			want: []RewardBatchRow{
+				{NIM: "2101004", Amount: 0},
+			},
+		},
+		{
+			name: "empty",
+			csv:  "",
+			want: []RewardBatchRow{},
+		},
+		{
+			name:    "header without amount",
+			csv:     "nim,description\n2101005,Late\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed quoting",
+			csv:     "2101006,\"50,Unclosed\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRewardCSV(strings.NewReader(tt.csv))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRewardCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRewardCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
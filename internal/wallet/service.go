@@ -6,31 +6,67 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
 	"math"
+	"sync"
 	"time"
 
+	"wallet-point/internal/audit"
 	"wallet-point/internal/auth"
+	"wallet-point/internal/budget"
+	"wallet-point/internal/fraud"
+	"wallet-point/internal/limit"
+	"wallet-point/internal/realtime"
+	"wallet-point/utils"
 
 	"github.com/skip2/go-qrcode"
 	"gorm.io/gorm"
 )
 
 type WalletService struct {
-	repo        *WalletRepository
-	db          *gorm.DB
-	authService *auth.AuthService
+	repo          *WalletRepository
+	db            *gorm.DB
+	authService   *auth.AuthService
+	auditService  *audit.AuditService
+	limitService  *limit.LimitService
+	budgetService *budget.BudgetService
+	fraudService  *fraud.FraudService
+	broker        *realtime.Broker
+	reversalHooks map[string][]ReversalHook
+	sweepMu       sync.Mutex
+	sweepStats    TokenSweeperStats
 }
 
 func (s *WalletService) SetAuthService(authService *auth.AuthService) {
 	s.authService = authService
 }
 
+func (s *WalletService) SetAuditService(auditService *audit.AuditService) {
+	s.auditService = auditService
+}
+
+func (s *WalletService) SetLimitService(limitService *limit.LimitService) {
+	s.limitService = limitService
+}
+
+func (s *WalletService) SetBudgetService(budgetService *budget.BudgetService) {
+	s.budgetService = budgetService
+}
+
+func (s *WalletService) SetFraudService(fraudService *fraud.FraudService) {
+	s.fraudService = fraudService
+}
+
+func (s *WalletService) SetBroker(broker *realtime.Broker) {
+	s.broker = broker
+}
+
 func NewWalletService(repo *WalletRepository, db *gorm.DB) *WalletService {
-	return &WalletService{
+	s := &WalletService{
 		repo: repo,
 		db:   db,
 	}
+	s.RegisterReversalHook("marketplace", s.reverseSplitContribution) // Split contributions are paid as marketplace debits
+	return s
 }
 
 // GetWalletByUserID retrieves a user's wallet
@@ -44,70 +80,75 @@ func (s *WalletService) GetWalletByID(walletID uint) (*Wallet, error) {
 }
 
 // AdjustPoints adds or subtracts points from a wallet
-func (s *WalletService) AdjustPoints(req *AdjustmentRequest, adminID uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		delta := req.Amount
-		if req.Direction == "debit" {
-			delta = -req.Amount
-		}
-
-		// Check balance for debit
-		if req.Direction == "debit" {
+// Credits given by a dosen are taken from their point budget; a returned
+// request means the credit was queued for admin approval instead.
+func (s *WalletService) AdjustPoints(req *AdjustmentRequest, adminID uint) (*budget.RewardRequest, error) {
+	var queued *budget.RewardRequest
+	err := s.Transaction(func(tx *gorm.DB) error {
+		if req.Direction == "credit" {
 			wallet, err := s.repo.FindByID(req.WalletID)
 			if err != nil {
 				return err
 			}
-			if wallet.Balance < req.Amount {
-				return errors.New("insufficient balance")
+			queued, err = s.chargeBudget(tx, budget.Charge{
+				DosenID:     adminID,
+				UserID:      wallet.UserID,
+				Kind:        "adjustment",
+				Amount:      req.Amount,
+				Description: req.Description,
+			})
+			if err != nil || queued != nil {
+				return err
 			}
 		}
 
-		err := s.repo.UpdateBalance(tx, req.WalletID, delta)
-		if err != nil {
-			return err
-		}
-
-		txn := &WalletTransaction{
-			WalletID:    req.WalletID,
+		// PostJournal locks the wallet and rejects debits beyond its balance
+		_, err := s.PostJournal(tx, Journal{
 			Type:        "adjustment",
-			Amount:      req.Amount,
-			Direction:   req.Direction,
-			Status:      "success",
 			Description: req.Description,
 			CreatedBy:   "admin",
-		}
-
-		return s.repo.CreateTransaction(tx, txn)
+			AllowFrozen: true,
+			Postings: []Posting{
+				{WalletID: req.WalletID, Direction: req.Direction, Amount: req.Amount},
+				{Account: AccountAdjustments, Direction: oppositeDirection(req.Direction), Amount: req.Amount},
+			},
+		})
+		return err
 	})
+	return queued, err
 }
 
 // ResetWallet resets a wallet to a specific balance
 func (s *WalletService) ResetWallet(req *ResetWalletRequest, adminID uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		wallet, err := s.repo.FindByID(req.WalletID)
+	return s.Transaction(func(tx *gorm.DB) error {
+		wallet, err := s.repo.LockByID(tx, req.WalletID)
 		if err != nil {
 			return err
 		}
 
-		err = s.repo.SetBalance(tx, req.WalletID, req.NewBalance)
-		if err != nil {
-			return err
+		delta := req.NewBalance - wallet.Balance
+		if delta == 0 {
+			return nil
+		}
+
+		// The reset is posted as an adjustment so the ledger keeps the difference
+		direction := "credit"
+		if delta < 0 {
+			direction = "debit"
 		}
+		amount := int(math.Abs(float64(delta)))
 
-		txn := &WalletTransaction{
-			WalletID:    req.WalletID,
+		_, err = s.PostJournal(tx, Journal{
 			Type:        "adjustment",
-			Amount:      int(math.Abs(float64(req.NewBalance - wallet.Balance))),
-			Direction:   "debit", // default direction, could be more specific
-			Status:      "success",
 			Description: "Reset Wallet: " + req.Reason,
 			CreatedBy:   "admin",
-		}
-		if req.NewBalance > wallet.Balance {
-			txn.Direction = "credit"
-		}
-
-		return s.repo.CreateTransaction(tx, txn)
+			AllowFrozen: true,
+			Postings: []Posting{
+				{WalletID: req.WalletID, Direction: direction, Amount: amount},
+				{Account: AccountAdjustments, Direction: oppositeDirection(direction), Amount: amount},
+			},
+		})
+		return err
 	})
 }
 
@@ -144,6 +185,32 @@ func (s *WalletService) GeneratePaymentToken(req PaymentTokenRequest, userID uin
 	// the creator is the recipient. Balance is checked during consumption
 	// from the scanner's wallet.
 
+	// Static tokens always pay their creator and take the amount from the payer
+	lifetime := 10 * time.Minute
+	if req.Type == "static" {
+		if err := validateStaticToken(&req); err != nil {
+			return nil, err
+		}
+		req.Amount = 0
+		recipientID = userID
+		lifetime = StaticTokenLifetime
+	} else {
+		if req.Amount <= 0 {
+			return nil, errors.New("amount is required")
+		}
+		req.MinAmount, req.MaxAmount = nil, nil
+	}
+
+	// Split bills are collected from several payers over a longer time
+	if req.Type == "split" {
+		if req.CaptureMode == "manual" {
+			return nil, errors.New("tagihan patungan tidak mendukung konfirmasi manual")
+		}
+		lifetime = SplitTokenLifetime
+	} else {
+		req.RefundOnExpiry = false
+	}
+
 	// 2. Generate secure random token
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
@@ -151,27 +218,35 @@ func (s *WalletService) GeneratePaymentToken(req PaymentTokenRequest, userID uin
 	}
 	tokenCode := hex.EncodeToString(b)
 
-	// 3. Generate QR Code Image & Payload
-	qrPayload := fmt.Sprintf("WPT:%s:%d:%s", tokenCode, req.Amount, req.Merchant)
-	qrCode, err := qrcode.Encode(qrPayload, qrcode.Medium, 256)
+	// 3. Build record, the expiry is kept to whole seconds so it matches the signed payload
+	token := &PaymentToken{
+		Token:          tokenCode,
+		Amount:         req.Amount,
+		Merchant:       req.Merchant,
+		Expiry:         time.Now().Add(lifetime).Truncate(time.Second),
+		WalletID:       wallet.ID,
+		RecipientID:    recipientID,
+		Status:         "active",
+		Type:           req.Type,
+		ProductID:      req.ProductID,
+		CaptureMode:    firstNonEmpty(req.CaptureMode, "immediate"),
+		MinAmount:      req.MinAmount,
+		MaxAmount:      req.MaxAmount,
+		RefundOnExpiry: req.RefundOnExpiry,
+	}
+
+	// 4. Generate signed QR payload & image
+	token.QRPayload, err = encodeQRPayload(token)
 	if err != nil {
 		return nil, err
 	}
-
-	// 4. Create record
-	token := &PaymentToken{
-		Token:        tokenCode,
-		QRCodeBase64: base64.StdEncoding.EncodeToString(qrCode),
-		Amount:       req.Amount,
-		Merchant:     req.Merchant,
-		Expiry:       time.Now().Add(10 * time.Minute),
-		WalletID:     wallet.ID,
-		RecipientID:  recipientID,
-		Status:       "active",
-		Type:         req.Type,
-		ProductID:    req.ProductID,
+	qrCode, err := qrcode.Encode(token.QRPayload, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
 	}
+	token.QRCodeBase64 = base64.StdEncoding.EncodeToString(qrCode)
 
+	// 5. Save record
 	if err := s.db.Create(token).Error; err != nil {
 		return nil, err
 	}
@@ -216,20 +291,33 @@ func (s *WalletService) GetTokenDetails(tokenCode string) (*PaymentToken, error)
 	if token.Status == "active" && time.Now().After(token.Expiry) {
 		token.Status = "expired"
 		s.db.Model(&token).Update("status", "expired")
+		s.publishTokenEvent(&token, realtime.EventTokenExpired)
 	}
 
 	return &token, nil
 }
 
-// StudentPayToken executes a payment from a student scanning a bill
-func (s *WalletService) StudentPayToken(tokenCode string, scannerUserID uint, pin string) error {
+// StudentPayToken executes a payment from a student scanning a bill. When the
+// scanned payload is sent its signature and content must match the token, so
+// the payer is charged exactly what the QR code showed.
+func (s *WalletService) StudentPayToken(req PaymentExecuteRequest, scannerUserID uint) error {
 	// 1. Verify PIN
-	if err := s.authService.VerifyPIN(scannerUserID, pin); err != nil {
+	if err := s.authService.VerifyPIN(scannerUserID, req.PIN); err != nil {
+		return err
+	}
+
+	// Only a signed payload is trusted, a bare token code could have been
+	// read off someone else's screen or altered
+	claims, err := parseQRPayload(req.Payload)
+	if err != nil {
 		return err
 	}
+	if req.Token != "" && req.Token != claims.Token {
+		return ErrQRMismatch
+	}
 
 	var token PaymentToken
-	if err := s.db.Where("token = ? AND status = ?", tokenCode, "active").First(&token).Error; err != nil {
+	if err := s.db.Where("token = ? AND status IN ?", claims.Token, []string{"active", "partially_paid"}).First(&token).Error; err != nil {
 		return errors.New("token tidak valid")
 	}
 
@@ -237,196 +325,343 @@ func (s *WalletService) StudentPayToken(tokenCode string, scannerUserID uint, pi
 		return errors.New("token kadaluarsa")
 	}
 
+	if err := verifyTokenPayload(&token, req.Payload); err != nil {
+		return err
+	}
+
+	// The payer enters the amount of a static token, it is then paid like a fixed one
+	if token.Type == "static" {
+		if err := checkStaticAmount(&token, req.Amount); err != nil {
+			return err
+		}
+		token.Amount = req.Amount
+	}
+
+	// A split token is paid in shares, each payment is charged like a fixed one
+	if token.Type == "split" {
+		share, err := splitContribution(&token, req.Amount)
+		if err != nil {
+			return err
+		}
+		req.Amount = share
+	}
+
 	scannerWallet, err := s.repo.FindByUserID(scannerUserID)
 	if err != nil {
 		return errors.New("wallet pembayar tidak ditemukan")
 	}
 
-	if scannerWallet.Balance < token.Amount {
-		return errors.New("saldo tidak mencukupi")
+	if err := s.checkMovable(nil, scannerWallet, true); err != nil {
+		return err
 	}
 
-	// Recipient logic
-	var recipientID uint = token.RecipientID
-	if recipientID == 0 {
-		// Fallback to finding the first Admin
-		var adminUser struct {
-			ID       uint
-			FullName string
-		}
-		err := s.db.Table("users").Where("role = ?", "admin").Select("id, full_name").Order("id asc").First(&adminUser).Error
-		if err != nil {
-			log.Printf("[StudentPayToken] No admin user found in database: %v", err)
-			return errors.New("sistem gagal menemukan admin sebagai penerima")
-		}
-		recipientID = adminUser.ID
+	if token.Type == "static" && scannerWallet.ID == token.WalletID {
+		return errors.New("tidak dapat membayar QR milik sendiri")
 	}
 
-	recipientWallet, err := s.repo.FindByUserID(recipientID)
+	charge := token.Amount
+	if token.Type == "split" {
+		charge = req.Amount
+	}
+	if scannerWallet.AvailableBalance < charge {
+		return ErrInsufficientBalance
+	}
+
+	recipientID, err := s.resolveTokenRecipient(&token)
 	if err != nil {
-		// If wallet doesn't exist, create it (every user should have one)
-		log.Printf("[StudentPayToken] Recipient (User %d) has no wallet, creating one...", recipientID)
-		newWallet := &Wallet{
-			UserID:  recipientID,
-			Balance: 0,
-		}
-		if err := s.db.Create(newWallet).Error; err != nil {
-			return errors.New("gagal menyiapkan wallet penerima")
-		}
-		recipientWallet = newWallet
+		return err
+	}
+	if token.Type == "split" && recipientID == scannerUserID {
+		return errors.New("tidak dapat membayar QR milik sendiri")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		// 1. Deduct from scanner
-		if err := s.repo.UpdateBalance(tx, scannerWallet.ID, -token.Amount); err != nil {
+	err = s.Transaction(func(tx *gorm.DB) error {
+		recipientWallet, err := s.recipientWallet(tx, recipientID)
+		if err != nil {
 			return err
 		}
 
-		// 2. Credit to recipient
-		if err := s.repo.UpdateBalance(tx, recipientWallet.ID, token.Amount); err != nil {
-			return err
+		if s.fraudService != nil {
+			_, err := s.fraudService.Check(tx, fraud.Activity{
+				Kind:        "qr_payment",
+				SenderID:    scannerUserID,
+				ReceiverID:  recipientID,
+				Amount:      charge,
+				ReferenceID: &token.ID,
+			})
+			if err != nil {
+				return err
+			}
 		}
 
-		// 3. Mark token as consumed
-		if err := tx.Model(&token).Update("status", "consumed").Error; err != nil {
-			return err
+		// 1. Mark token as used, only one concurrent scan can win. Manual
+		// capture tokens only reserve the points until the creator captures them.
+		// Static tokens stay active and can be paid again, split tokens until
+		// their amount is covered.
+		if token.Type == "static" {
+			if err := lockStaticToken(tx, token.ID); err != nil {
+				return err
+			}
+		} else if token.Type == "split" {
+			collected, err := collectSplitPayment(tx, token.ID, charge)
+			if err != nil {
+				return err
+			}
+			token.Status = collected.Status
+			token.PaidAmount = collected.PaidAmount
+		} else {
+			newStatus := "consumed"
+			if token.CaptureMode == "manual" {
+				newStatus = "authorized"
+			}
+			result := tx.Model(&PaymentToken{}).
+				Where("id = ? AND status = ?", token.ID, "active").
+				Updates(map[string]interface{}{"status": newStatus, "payer_wallet_id": scannerWallet.ID})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("token tidak valid")
+			}
 		}
 
-		// 4. Handle Descriptions and Types based on Token Type
-		txnType := "marketplace"
-		payerDesc := "Pembayaran QR"
-		recipientDesc := fmt.Sprintf("Terima Pembayaran QR dari User #%d", scannerUserID)
-		var refID *uint
-
-		if token.Type == "transfer" {
-			txnType = "transfer" // Note: repository/model might use transfer_in/transfer_out
-			payerDesc = fmt.Sprintf("Transfer QR ke User #%d", recipientID)
-			recipientDesc = fmt.Sprintf("Terima Transfer QR dari User #%d", scannerUserID)
-		} else if token.Type == "purchase" {
-			txnType = "marketplace"
-			if token.ProductID != 0 {
-				refID = &token.ProductID
-				var prodName string
-				tx.Table("products").Where("id = ?", token.ProductID).Select("name").Scan(&prodName)
-				if prodName != "" {
-					payerDesc = "Beli: " + prodName
-					recipientDesc = fmt.Sprintf("Penjualan: %s ke User #%d", prodName, scannerUserID)
-				}
-
-				// Reduce Stock
-				if err := tx.Table("products").Where("id = ?", token.ProductID).Update("stock", gorm.Expr("stock - ?", 1)).Error; err != nil {
-					return fmt.Errorf("gagal memperbarui stok: %v", err)
-				}
+		// 2. Handle Descriptions and Types based on Token Type
+		payment := s.describeTokenPayment(tx, &token, scannerUserID, recipientID)
+
+		if token.Type == "purchase" && token.ProductID != 0 {
+			// Reduce Stock
+			result := tx.Table("products").Where("id = ? AND stock >= ?", token.ProductID, 1).Update("stock", gorm.Expr("stock - ?", 1))
+			if result.Error != nil {
+				return fmt.Errorf("gagal memperbarui stok: %v", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("stok produk habis")
 			}
 		}
 
-		// 5. Create Transactions
-		// Determine specific types if enum requires transfer_in/transfer_out
-		payerType := txnType
-		recipientType := txnType
-		if txnType == "transfer" {
-			payerType = "transfer_out"
-			recipientType = "transfer_in"
+		if token.CaptureMode == "manual" {
+			expiresAt := time.Now().Add(PaymentHoldTTL)
+			hold, err := s.AuthorizeHoldWithTx(tx, HoldParams{
+				WalletID:    scannerWallet.ID,
+				Amount:      charge,
+				Type:        payment.payerType,
+				Description: payment.payerDesc,
+				ReferenceID: payment.refID,
+				ExpiresAt:   &expiresAt,
+			})
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&PaymentToken{}).Where("id = ?", token.ID).Update("hold_id", hold.ID).Error; err != nil {
+				return err
+			}
+			return s.checkTokenLimit(tx, &token, scannerUserID, scannerWallet.ID, charge)
 		}
 
-		tx.Create(&WalletTransaction{
-			WalletID:    scannerWallet.ID,
-			Type:        payerType,
-			Amount:      token.Amount,
-			Direction:   "debit",
-			Status:      "success",
-			Description: payerDesc,
-			ReferenceID: refID,
+		// 3. Move the points from scanner to recipient in a single journal.
+		// Split contributions wait in escrow until the bill is covered.
+		credit := Posting{WalletID: recipientWallet.ID, Direction: "credit", Amount: charge, TxnType: payment.recipientType, Description: payment.recipientDesc}
+		if token.Type == "split" {
+			if err := s.checkMovable(tx, recipientWallet, false); err != nil {
+				return err
+			}
+			credit = Posting{Account: AccountSplitBillEscrow, Direction: "credit", Amount: charge}
+		}
+		txns, err := s.PostJournal(tx, Journal{
+			Type:        payment.txnType,
+			Description: fmt.Sprintf("QR payment %s", token.Token),
+			ReferenceID: payment.refID,
+			Postings: []Posting{
+				{WalletID: scannerWallet.ID, Direction: "debit", Amount: charge, TxnType: payment.payerType, Description: payment.payerDesc},
+				credit,
+			},
 		})
+		if err != nil {
+			return err
+		}
+		if token.Type == "static" || token.Type == "split" {
+			err := s.repo.CreateTokenPayment(tx, &TokenPayment{
+				TokenID:       token.ID,
+				PayerWalletID: scannerWallet.ID,
+				Amount:        charge,
+				TransactionID: txns[0].ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if token.Type == "split" && token.Status == "consumed" {
+			if err := s.releaseSplitEscrowWithTx(tx, &token, recipientWallet.ID); err != nil {
+				return err
+			}
+		}
+		return s.checkTokenLimit(tx, &token, scannerUserID, scannerWallet.ID, charge)
+	})
+	if err != nil {
+		return err
+	}
 
-		tx.Create(&WalletTransaction{
-			WalletID:    recipientWallet.ID,
-			Type:        recipientType,
-			Amount:      token.Amount,
-			Direction:   "credit",
-			Status:      "success",
-			Description: recipientDesc,
-			ReferenceID: refID,
-		})
+	// Let the cashier screen know the bill was paid
+	token.PayerWalletID = &scannerWallet.ID
+	switch {
+	case token.Type == "static" || (token.Type == "split" && token.Status == "partially_paid"):
+		s.publishTokenEvent(&token, realtime.EventTokenPaid)
+	case token.Type == "split":
+		s.publishTokenEvent(&token, realtime.EventTokenConsumed)
+	case token.CaptureMode == "manual":
+		token.Status = "authorized"
+		s.publishTokenEvent(&token, realtime.EventTokenAuthorized)
+	default:
+		token.Status = "consumed"
+		s.publishTokenEvent(&token, realtime.EventTokenConsumed)
+	}
+	return nil
+}
 
+// checkTokenLimit enforces the payer's limits once a QR payment is posted or
+// reserved. Transfer tokens count as transfers, bills as marketplace spend.
+func (s *WalletService) checkTokenLimit(tx *gorm.DB, token *PaymentToken, payerUserID, payerWalletID uint, amount int) error {
+	if s.limitService == nil {
 		return nil
-	})
+	}
+	if token.Type == "transfer" {
+		return s.limitService.CheckTransfer(tx, payerUserID, payerWalletID, amount)
+	}
+	return s.limitService.CheckMarketplace(tx, payerUserID, payerWalletID, amount)
 }
 
 // DebitWithTransaction handles point deduction within an existing transaction
-func (s *WalletService) DebitWithTransaction(tx *gorm.DB, walletID uint, amount int, txnType string, description string) error {
-	// 1. Check balance
-	wallet, err := s.repo.FindByID(walletID)
+//
+// The wallet row is locked and the balance checked inside tx, so concurrent
+// debits cannot overdraw it.
+func (s *WalletService) DebitWithTransaction(tx *gorm.DB, walletID uint, amount int, txnType string, description string) (*WalletTransaction, error) {
+	// Post against the system account matching the transaction type
+	txns, err := s.PostJournal(tx, Journal{
+		Type:        txnType,
+		Description: description,
+		Postings: []Posting{
+			{WalletID: walletID, Direction: "debit", Amount: amount},
+			{Account: contraAccount(txnType), Direction: "credit", Amount: amount},
+		},
+	})
 	if err != nil {
-		return err
-	}
-	if wallet.Balance < amount {
-		return errors.New("insufficient balance")
-	}
-
-	// 2. Update balance
-	if err := s.repo.UpdateBalance(tx, walletID, -amount); err != nil {
-		return err
+		return nil, err
 	}
+	return &txns[0], nil
+}
 
-	// 3. Create transaction record
-	txn := &WalletTransaction{
-		WalletID:    walletID,
+// CreditWithTransaction handles point addition within an existing transaction
+func (s *WalletService) CreditWithTransaction(tx *gorm.DB, walletID uint, amount int, txnType string, description string) (*WalletTransaction, error) {
+	txns, err := s.PostJournal(tx, Journal{
 		Type:        txnType,
-		Amount:      amount,
-		Direction:   "debit",
-		Status:      "success",
 		Description: description,
+		Postings: []Posting{
+			{Account: contraAccount(txnType), Direction: "debit", Amount: amount},
+			{WalletID: walletID, Direction: "credit", Amount: amount},
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &txns[0], nil
+}
 
-	return s.repo.CreateTransaction(tx, txn)
+// EscrowDebitWithTransaction moves points from a wallet into marketplace
+// escrow, where they stay until EscrowCreditWithTransaction pays them out
+func (s *WalletService) EscrowDebitWithTransaction(tx *gorm.DB, walletID uint, amount int, description string) (*WalletTransaction, error) {
+	txns, err := s.PostJournal(tx, Journal{
+		Type:        "marketplace",
+		Description: description,
+		Postings: []Posting{
+			{WalletID: walletID, Direction: "debit", Amount: amount},
+			{Account: AccountMarketplaceEscrow, Direction: "credit", Amount: amount},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &txns[0], nil
 }
 
-// CreditWithTransaction handles point addition within an existing transaction
-func (s *WalletService) CreditWithTransaction(tx *gorm.DB, walletID uint, amount int, txnType string, description string) error {
-	// 1. Update balance
-	if err := s.repo.UpdateBalance(tx, walletID, amount); err != nil {
-		return err
+// EscrowCreditWithTransaction pays points held in marketplace escrow out to
+// a wallet, the seller's on release or the buyer's on refund. escrowTxnID is
+// the buyer's debit into escrow, whose point expiry the payout keeps. It
+// fails with ErrWalletClosed for a closed wallet.
+func (s *WalletService) EscrowCreditWithTransaction(tx *gorm.DB, walletID uint, amount int, description string, escrowTxnID *uint) (*WalletTransaction, error) {
+	credit := Posting{WalletID: walletID, Direction: "credit", Amount: amount}
+	if escrowTxnID != nil {
+		credit.LotSources = []uint{*escrowTxnID}
 	}
 
-	// 2. Create transaction record
-	txn := &WalletTransaction{
-		WalletID:    walletID,
-		Type:        txnType,
-		Amount:      amount,
-		Direction:   "credit",
-		Status:      "success",
+	txns, err := s.PostJournal(tx, Journal{
+		Type:        "marketplace",
 		Description: description,
+		// Like a reversal it settles a past payment, so escrow never gets stuck
+		// on a wallet frozen since. A closed wallet is still refused.
+		AllowFrozen: true,
+		Postings: []Posting{
+			{Account: AccountMarketplaceEscrow, Direction: "debit", Amount: amount},
+			credit,
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &txns[0], nil
+}
 
-	return s.repo.CreateTransaction(tx, txn)
+// TransferWithTransaction moves points between two wallets as one balanced
+// journal. It returns the sender's transfer_out and the receiver's
+// transfer_in transaction, both carrying referenceID.
+func (s *WalletService) TransferWithTransaction(tx *gorm.DB, fromWalletID, toWalletID uint, amount int, outDescription, inDescription string, referenceID *uint) (*WalletTransaction, *WalletTransaction, error) {
+	txns, err := s.PostJournal(tx, Journal{
+		Type:        "transfer",
+		Description: outDescription,
+		ReferenceID: referenceID,
+		Postings: []Posting{
+			{WalletID: fromWalletID, Direction: "debit", Amount: amount, TxnType: "transfer_out", Description: outDescription},
+			{WalletID: toWalletID, Direction: "credit", Amount: amount, TxnType: "transfer_in", Description: inDescription},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &txns[0], &txns[1], nil
 }
 
-// ProcessMissionRewardWithTx handles mission rewards within a transaction
-func (s *WalletService) ProcessMissionRewardWithTx(tx *gorm.DB, userID uint, amount int, missionTitle string, missionID uint, reviewerID uint) error {
+// ProcessMissionRewardWithTx handles mission rewards within a transaction.
+// The reward is taken from the point budget of the mission's creator and is
+// left to admin approval when the budget queues it.
+func (s *WalletService) ProcessMissionRewardWithTx(tx *gorm.DB, userID uint, amount int, missionTitle string, missionID uint, creatorID uint, reviewerID uint) error {
 	wallet, err := s.repo.FindByUserID(userID)
 	if err != nil {
 		return err
 	}
 
-	// Create transaction record
-	txn := &WalletTransaction{
-		WalletID:    wallet.ID,
-		Type:        "mission",
+	queued, err := s.chargeBudget(tx, budget.Charge{
+		DosenID:     creatorID,
+		UserID:      userID,
+		Kind:        "mission",
 		Amount:      amount,
-		Direction:   "credit",
-		Status:      "success",
 		Description: "Reward for mission: " + missionTitle,
 		ReferenceID: &missionID,
-		CreatedBy:   "dosen",
-	}
-
-	// Update balance
-	if err := s.repo.UpdateBalance(tx, wallet.ID, amount); err != nil {
+	})
+	if err != nil || queued != nil {
 		return err
 	}
 
-	return s.repo.CreateTransaction(tx, txn)
+	// Rewards are paid out of the mission rewards pool
+	_, err = s.PostJournal(tx, Journal{
+		Type:        "mission",
+		Description: "Reward for mission: " + missionTitle,
+		ReferenceID: &missionID,
+		CreatedBy:   "dosen",
+		Postings: []Posting{
+			{Account: AccountMissionRewards, Direction: "debit", Amount: amount},
+			{WalletID: wallet.ID, Direction: "credit", Amount: amount},
+		},
+	})
+	return err
 }
 
 func (s *WalletService) GetAdminStats() (*AdminStats, error) {
@@ -440,10 +675,7 @@ func (s *WalletService) GetAdminStats() (*AdminStats, error) {
 	s.db.Table("wallets").Select("COALESCE(SUM(balance), 0)").Scan(&stats.CirculationPoints)
 
 	// 3. Today Stats
-	location, err := time.LoadLocation("Asia/Jakarta")
-	if err != nil {
-		location = time.Local
-	}
+	location := utils.Location()
 	now := time.Now().In(location)
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
 
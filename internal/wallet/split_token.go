@@ -0,0 +1,226 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"wallet-point/internal/realtime"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSplitPaidOut = errors.New("split bill has been paid out, reverse the payout first")
+
+// splitContribution returns the share of a split token a payer covers, by
+// default everything that is left
+func splitContribution(token *PaymentToken, amount int) (int, error) {
+	remaining := token.Amount - token.PaidAmount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return 0, fmt.Errorf("nominal harus antara 1 dan sisa tagihan (%d poin)", remaining)
+	}
+	return amount, nil
+}
+
+// collectSplitPayment locks a split token and adds a contribution to it. The
+// bill is consumed once the contributions cover its amount.
+func collectSplitPayment(tx *gorm.DB, tokenID uint, amount int) (*PaymentToken, error) {
+	var token PaymentToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Omit("qr_code_base64").First(&token, tokenID).Error
+	if err != nil || (token.Status != "active" && token.Status != "partially_paid") {
+		return nil, errors.New("token tidak valid")
+	}
+	if time.Now().After(token.Expiry) {
+		return nil, errors.New("token kadaluarsa")
+	}
+	if _, err := splitContribution(&token, amount); err != nil {
+		return nil, err
+	}
+
+	token.PaidAmount += amount
+	token.Status = "partially_paid"
+	if token.PaidAmount == token.Amount {
+		token.Status = "consumed"
+	}
+
+	err = tx.Model(&PaymentToken{}).Where("id = ?", token.ID).
+		Updates(map[string]interface{}{"paid_amount": token.PaidAmount, "status": token.Status}).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// releaseSplitEscrowWithTx pays the contributions a split token still holds
+// in escrow out to its recipient. Contributions refunded or reversed since
+// are no longer in escrow and are left out. It fails with ErrWalletClosed
+// for a closed recipient wallet, leaving the contributions in escrow.
+func (s *WalletService) releaseSplitEscrowWithTx(tx *gorm.DB, token *PaymentToken, walletID uint) error {
+	payments, err := s.repo.GetUnrefundedTokenPayments(tx, token.ID)
+	if err != nil {
+		return err
+	}
+	amount := 0
+	sources := make([]uint, len(payments))
+	for i, p := range payments {
+		amount += p.Amount
+		sources[i] = p.TransactionID
+	}
+	if amount == 0 {
+		return nil
+	}
+
+	description := fmt.Sprintf("Terima Pembayaran Split Bill %s", token.Token)
+	_, err = s.PostJournal(tx, Journal{
+		Type:        "marketplace",
+		Description: description,
+		ReferenceID: &token.ID,
+		// The contributions are already paid, so escrow never gets stuck on a
+		// recipient wallet frozen since
+		AllowFrozen: true,
+		Postings: []Posting{
+			{Account: AccountSplitBillEscrow, Direction: "debit", Amount: amount},
+			{WalletID: walletID, Direction: "credit", Amount: amount, LotSources: sources},
+		},
+	})
+	return err
+}
+
+// reverseSplitContribution takes a contribution an admin reversed off its
+// split token, so a later payout or refund does not count it again. Once the
+// bill has been paid out the points have left escrow and the payout has to be
+// reversed instead.
+func (s *WalletService) reverseSplitContribution(tx *gorm.DB, original *WalletTransaction) error {
+	if original.Direction != "debit" {
+		return nil
+	}
+	payment, err := s.repo.FindTokenPaymentByTransaction(tx, original.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Refunds mark the payment before reversing it
+	if payment.RefundedAt != nil {
+		return nil
+	}
+
+	var token PaymentToken
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Omit("qr_code_base64").First(&token, payment.TokenID).Error
+	if err != nil {
+		return err
+	}
+	if token.Type != "split" {
+		return nil
+	}
+	if token.Status != "active" && token.Status != "partially_paid" {
+		return ErrSplitPaidOut
+	}
+
+	token.PaidAmount -= payment.Amount
+	token.Status = "partially_paid"
+	if token.PaidAmount == 0 {
+		token.Status = "active"
+	}
+	err = tx.Model(&PaymentToken{}).Where("id = ?", token.ID).
+		Updates(map[string]interface{}{"paid_amount": token.PaidAmount, "status": token.Status}).Error
+	if err != nil {
+		return err
+	}
+	return s.repo.MarkTokenPaymentRefunded(tx, payment.ID, time.Now())
+}
+
+// expireSplitTokens closes partially paid split tokens past their expiry.
+// Contributions are refunded from escrow when the creator asked for it,
+// otherwise the collected points are released to the recipient.
+func (s *WalletService) expireSplitTokens(now time.Time) (expired, refunded int64, err error) {
+	ids, err := s.repo.GetExpiredSplitTokenIDs(now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, id := range ids {
+		var token *PaymentToken
+		err := s.Transaction(func(tx *gorm.DB) error {
+			var err error
+			token, err = s.closeSplitTokenWithTx(tx, id)
+			return err
+		})
+		if err != nil {
+			log.Printf("[TokenSweeper] Failed to close split token %d: %v", id, err)
+			continue
+		}
+		if token == nil {
+			continue
+		}
+
+		if token.Status == "refunded" {
+			refunded++
+		} else {
+			expired++
+		}
+		s.publishTokenEvent(token, realtime.EventTokenExpired)
+	}
+	return expired, refunded, nil
+}
+
+func (s *WalletService) closeSplitTokenWithTx(tx *gorm.DB, tokenID uint) (*PaymentToken, error) {
+	var token PaymentToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Omit("qr_code_base64").First(&token, tokenID).Error
+	if err != nil {
+		return nil, err
+	}
+	// Paid in full or closed by an earlier run in the meantime
+	if token.Status != "partially_paid" {
+		return nil, nil
+	}
+
+	token.Status = "expired"
+	refund := token.RefundOnExpiry
+	var recipientWallet *Wallet
+	if !refund {
+		recipientID, err := s.resolveTokenRecipient(&token)
+		if err != nil {
+			return nil, err
+		}
+		if recipientWallet, err = s.recipientWallet(tx, recipientID); err != nil {
+			return nil, err
+		}
+		// A closed recipient can no longer be paid, the payers get their
+		// contributions back instead
+		refund = recipientWallet.Status == "closed"
+	}
+
+	if refund {
+		payments, err := s.repo.GetUnrefundedTokenPayments(tx, token.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		for _, p := range payments {
+			// Marked first so reverseSplitContribution leaves the token alone
+			if err := s.repo.MarkTokenPaymentRefunded(tx, p.ID, now); err != nil {
+				return nil, err
+			}
+			// Reversing the payer's debit moves the contribution back out of escrow
+			_, err := s.ReverseTransactionWithTx(tx, p.TransactionID, fmt.Sprintf("Refund split bill %s", token.Token), "system")
+			if err != nil {
+				return nil, err
+			}
+		}
+		token.Status = "refunded"
+	} else if err := s.releaseSplitEscrowWithTx(tx, &token, recipientWallet.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&PaymentToken{}).Where("id = ?", token.ID).Update("status", token.Status).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
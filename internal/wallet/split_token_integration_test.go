@@ -0,0 +1,57 @@
+//go:build integration
+
+package wallet_test
+
+import (
+	"testing"
+	"time"
+	"wallet-point/internal/wallet"
+)
+
+func TestReversedSplitContributionIsNotPaidOut(t *testing.T) {
+	env := setupIntegration(t)
+	recipient := env.createStudent(t, "split-recipient", 0)
+	first := env.createStudent(t, "split-first", 100)
+	second := env.createStudent(t, "split-second", 100)
+	escrowBefore := env.accountBalance(t, wallet.AccountSplitBillEscrow)
+
+	token, err := env.wallets.GeneratePaymentToken(wallet.PaymentTokenRequest{Type: "split", Amount: 90}, recipient.UserID, recipient.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payer := range []*wallet.Wallet{first, second} {
+		err := env.wallets.StudentPayToken(wallet.PaymentExecuteRequest{Payload: token.QRPayload, Amount: 30, PIN: "123456"}, payer.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var payment wallet.TokenPayment
+	if err := env.db.Where("token_id = ? AND payer_wallet_id = ?", token.ID, first.ID).First(&payment).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.wallets.ReverseTransaction(payment.TransactionID, "Paid by mistake", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloaded wallet.PaymentToken
+	env.db.First(&reloaded, token.ID)
+	if reloaded.PaidAmount != 30 || reloaded.Status != "partially_paid" {
+		t.Errorf("token paid %d with status %s, want 30 and partially_paid", reloaded.PaidAmount, reloaded.Status)
+	}
+
+	// Let the bill expire without a refund, the recipient keeps what is left
+	env.db.Model(&wallet.PaymentToken{}).Where("id = ?", token.ID).Update("expiry", time.Now().Add(-time.Minute))
+	if _, err := env.wallets.SweepPaymentTokens(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, f, s := env.reload(t, recipient.ID), env.reload(t, first.ID), env.reload(t, second.ID)
+	if r.Balance != 30 || f.Balance != 100 || s.Balance != 70 {
+		t.Errorf("balances recipient %d, first %d, second %d, want 30, 100 and 70", r.Balance, f.Balance, s.Balance)
+	}
+	if escrow := env.accountBalance(t, wallet.AccountSplitBillEscrow); escrow != escrowBefore {
+		t.Errorf("split escrow holds %d, want %d", escrow, escrowBefore)
+	}
+	env.checkLedger(t, r, f, s)
+}
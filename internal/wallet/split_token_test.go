@@ -0,0 +1,36 @@
+package wallet
+
+import "testing"
+
+func TestSplitContribution(t *testing.T) {
+	token := &PaymentToken{Type: "split", Amount: 100, PaidAmount: 40}
+
+	tests := []struct {
+		name    string
+		amount  int
+		want    int
+		wantErr bool
+	}{
+		{"defaults to what is left", 0, 60, false},
+		{"partial share", 25, 25, false},
+		{"exactly what is left", 60, 60, false},
+		{"more than what is left", 61, 0, true},
+		{"negative", -5, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitContribution(token, tt.amount)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitContribution() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("splitContribution() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	paid := &PaymentToken{Type: "split", Amount: 100, PaidAmount: 100}
+	if _, err := splitContribution(paid, 0); err == nil {
+		t.Error("splitContribution() on a covered bill should fail")
+	}
+}
@@ -0,0 +1,287 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-point/utils"
+)
+
+const statementDateLayout = "2006-01-02 15:04:05.999999"
+
+// GetStatement builds the statement of a wallet for a month given as YYYY-MM,
+// an empty month means the current one. Only successful transactions count.
+func (s *WalletService) GetStatement(walletID uint, month string) (*Statement, error) {
+	now := time.Now().In(utils.Location())
+	if month == "" {
+		month = now.Format("2006-01")
+	}
+	start, err := time.ParseInLocation("2006-01", month, utils.Location())
+	if err != nil {
+		return nil, errors.New("invalid month, use YYYY-MM")
+	}
+	if start.After(now) {
+		return nil, errors.New("statement month is in the future")
+	}
+	end := start.AddDate(0, 1, 0)
+
+	owner, err := s.repo.FindWithUser(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	txns, _, err := s.repo.GetTransactions(TransactionListParams{
+		WalletID: walletID,
+		Status:   "success",
+		// Timestamps are stored in the server's time zone
+		FromDate: start.In(time.Local).Format(statementDateLayout),
+		ToDate:   end.Add(-time.Microsecond).In(time.Local).Format(statementDateLayout),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Work back from the current balance to the balance at both ends of the month
+	sinceStart, err := s.repo.SumNetSince(walletID, start)
+	if err != nil {
+		return nil, err
+	}
+	sinceEnd, err := s.repo.SumNetSince(walletID, end)
+	if err != nil {
+		return nil, err
+	}
+
+	periodEnd := end
+	if periodEnd.After(now) {
+		periodEnd = now
+	}
+
+	statement := &Statement{
+		WalletID:       owner.WalletID,
+		UserID:         owner.UserID,
+		FullName:       owner.FullName,
+		NimNip:         owner.NimNip,
+		Month:          month,
+		PeriodStart:    start,
+		PeriodEnd:      periodEnd,
+		OpeningBalance: owner.Balance - sinceStart,
+		ClosingBalance: owner.Balance - sinceEnd,
+		Transactions:   []TransactionWithDetails{},
+		GeneratedAt:    now,
+	}
+
+	subtotals := make(map[string]*StatementSubtotal)
+	for _, t := range statementTypes {
+		subtotals[t] = &StatementSubtotal{Type: t}
+	}
+
+	// Oldest first, the way a statement reads
+	for i := len(txns) - 1; i >= 0; i-- {
+		t := txns[i]
+		statement.Transactions = append(statement.Transactions, t)
+
+		sub, ok := subtotals[t.Type]
+		if !ok {
+			sub = &StatementSubtotal{Type: t.Type}
+			subtotals[t.Type] = sub
+		}
+		sub.Count++
+		if t.Direction == "credit" {
+			sub.Credit += t.Amount
+			sub.Net += t.Amount
+			statement.TotalCredit += t.Amount
+		} else {
+			sub.Debit += t.Amount
+			sub.Net -= t.Amount
+			statement.TotalDebit += t.Amount
+		}
+	}
+
+	for _, t := range statementTypes {
+		statement.Subtotals = append(statement.Subtotals, *subtotals[t])
+		delete(subtotals, t)
+	}
+	var others []string
+	for t := range subtotals {
+		others = append(others, t)
+	}
+	sort.Strings(others)
+	for _, t := range others {
+		statement.Subtotals = append(statement.Subtotals, *subtotals[t])
+	}
+
+	return statement, nil
+}
+
+// RenderStatementCSV writes a statement as CSV: a summary block, the
+// subtotals and then one row per transaction
+func RenderStatementCSV(st *Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	rows := [][]string{
+		{"Wallet Statement", st.Month},
+		{"Name", csvText(st.FullName)},
+		{"NIM/NIP", csvText(st.NimNip)},
+		{"Wallet ID", strconv.FormatUint(uint64(st.WalletID), 10)},
+		{"Period", st.PeriodStart.Format("2006-01-02"), st.PeriodEnd.Format("2006-01-02")},
+		{"Opening Balance", strconv.Itoa(st.OpeningBalance)},
+		{"Total Credit", strconv.Itoa(st.TotalCredit)},
+		{"Total Debit", strconv.Itoa(st.TotalDebit)},
+		{"Closing Balance", strconv.Itoa(st.ClosingBalance)},
+		{},
+		{"Type", "Count", "Credit", "Debit", "Net"},
+	}
+	for _, sub := range st.Subtotals {
+		rows = append(rows, []string{sub.Type, strconv.Itoa(sub.Count), strconv.Itoa(sub.Credit), strconv.Itoa(sub.Debit), strconv.Itoa(sub.Net)})
+	}
+	rows = append(rows, []string{}, []string{"Date", "Transaction ID", "Type", "Description", "Credit", "Debit", "Balance"})
+
+	balance := st.OpeningBalance
+	for _, t := range st.Transactions {
+		credit, debit := "", ""
+		if t.Direction == "credit" {
+			balance += t.Amount
+			credit = strconv.Itoa(t.Amount)
+		} else {
+			balance -= t.Amount
+			debit = strconv.Itoa(t.Amount)
+		}
+		rows = append(rows, []string{
+			t.CreatedAt.In(utils.Location()).Format("2006-01-02 15:04:05"),
+			strconv.FormatUint(uint64(t.ID), 10),
+			t.Type,
+			csvText(t.Description),
+			credit,
+			debit,
+			strconv.Itoa(balance),
+		})
+	}
+
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvText neutralises user-entered text that a spreadsheet would run as a
+// formula, e.g. a transfer note starting with "=", by prefixing it with '
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// RenderStatementPDF lays a statement out on A4 pages
+func RenderStatementPDF(st *Statement) []byte {
+	const (
+		left   = 40.0
+		right  = utils.PDFPageWidth - 40
+		bottom = 60.0
+	)
+
+	doc := utils.NewPDFDocument()
+	y := 0.0
+
+	newPage := func() {
+		doc.AddPage()
+		y = utils.PDFPageHeight - 50
+		doc.Text(left, 30, 8, false, fmt.Sprintf("Wallet #%d - %s - page %d", st.WalletID, st.Month, doc.PageCount()))
+	}
+	tableHeader := func() {
+		doc.Text(left, y, 9, true, "Date")
+		doc.Text(left+95, y, 9, true, "Type")
+		doc.Text(left+175, y, 9, true, "Description")
+		doc.TextRight(right-130, y, 9, true, "Credit")
+		doc.TextRight(right-65, y, 9, true, "Debit")
+		doc.TextRight(right, y, 9, true, "Balance")
+		doc.Line(left, y-4, right, y-4)
+		y -= 16
+	}
+
+	newPage()
+	doc.Text(left, y, 18, true, "Wallet Statement")
+	y -= 22
+	doc.Text(left, y, 11, false, fmt.Sprintf("%s (%s)", st.FullName, st.NimNip))
+	y -= 15
+	doc.Text(left, y, 10, false, fmt.Sprintf("Wallet #%d  |  Period %s to %s", st.WalletID, st.PeriodStart.Format("02 Jan 2006"), st.PeriodEnd.Format("02 Jan 2006")))
+	y -= 28
+
+	summary := [][2]string{
+		{"Opening balance", strconv.Itoa(st.OpeningBalance)},
+		{"Total credit", strconv.Itoa(st.TotalCredit)},
+		{"Total debit", strconv.Itoa(st.TotalDebit)},
+		{"Closing balance", strconv.Itoa(st.ClosingBalance)},
+	}
+	for _, row := range summary {
+		doc.Text(left, y, 10, row[0] == "Closing balance", row[0])
+		doc.TextRight(left+220, y, 10, row[0] == "Closing balance", row[1])
+		y -= 14
+	}
+	y -= 14
+
+	doc.Text(left, y, 12, true, "Summary by type")
+	y -= 18
+	doc.Text(left, y, 9, true, "Type")
+	doc.TextRight(left+180, y, 9, true, "Count")
+	doc.TextRight(left+250, y, 9, true, "Credit")
+	doc.TextRight(left+320, y, 9, true, "Debit")
+	doc.TextRight(left+390, y, 9, true, "Net")
+	doc.Line(left, y-4, left+390, y-4)
+	y -= 16
+	for _, sub := range st.Subtotals {
+		doc.Text(left, y, 9, false, sub.Type)
+		doc.TextRight(left+180, y, 9, false, strconv.Itoa(sub.Count))
+		doc.TextRight(left+250, y, 9, false, strconv.Itoa(sub.Credit))
+		doc.TextRight(left+320, y, 9, false, strconv.Itoa(sub.Debit))
+		doc.TextRight(left+390, y, 9, false, strconv.Itoa(sub.Net))
+		y -= 13
+	}
+	y -= 18
+
+	doc.Text(left, y, 12, true, "Transactions")
+	y -= 18
+	if len(st.Transactions) == 0 {
+		doc.Text(left, y, 9, false, "No transactions in this period.")
+		return doc.Bytes()
+	}
+	tableHeader()
+
+	balance := st.OpeningBalance
+	for _, t := range st.Transactions {
+		if y < bottom {
+			newPage()
+			tableHeader()
+		}
+
+		credit, debit := "", ""
+		if t.Direction == "credit" {
+			balance += t.Amount
+			credit = strconv.Itoa(t.Amount)
+		} else {
+			balance -= t.Amount
+			debit = strconv.Itoa(t.Amount)
+		}
+
+		description := t.Description
+		if len(description) > 45 {
+			description = description[:42] + "..."
+		}
+
+		doc.Text(left, y, 8, false, t.CreatedAt.In(utils.Location()).Format("2006-01-02 15:04"))
+		doc.Text(left+95, y, 8, false, t.Type)
+		doc.Text(left+175, y, 8, false, description)
+		doc.TextRight(right-130, y, 8, false, credit)
+		doc.TextRight(right-65, y, 8, false, debit)
+		doc.TextRight(right, y, 8, false, strconv.Itoa(balance))
+		y -= 12
+	}
+
+	return doc.Bytes()
+}
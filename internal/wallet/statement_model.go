@@ -0,0 +1,32 @@
+package wallet
+
+import "time"
+
+// statementTypes are always listed in a statement, even without movements
+var statementTypes = []string{"mission", "transfer_in", "transfer_out", "marketplace", "adjustment"}
+
+type StatementSubtotal struct {
+	Type   string `json:"type"`
+	Credit int    `json:"credit"`
+	Debit  int    `json:"debit"`
+	Net    int    `json:"net"`
+	Count  int    `json:"count"`
+}
+
+// Statement summarises the successful movements of a wallet in one month
+type Statement struct {
+	WalletID       uint                     `json:"wallet_id"`
+	UserID         uint                     `json:"user_id"`
+	FullName       string                   `json:"full_name"`
+	NimNip         string                   `json:"nim_nip"`
+	Month          string                   `json:"month"` // YYYY-MM
+	PeriodStart    time.Time                `json:"period_start"`
+	PeriodEnd      time.Time                `json:"period_end"`
+	OpeningBalance int                      `json:"opening_balance"`
+	ClosingBalance int                      `json:"closing_balance"`
+	TotalCredit    int                      `json:"total_credit"`
+	TotalDebit     int                      `json:"total_debit"`
+	Subtotals      []StatementSubtotal      `json:"subtotals"`
+	Transactions   []TransactionWithDetails `json:"transactions"`
+	GeneratedAt    time.Time                `json:"generated_at"`
+}
@@ -0,0 +1,57 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Transfer ke Budi", "Transfer ke Budi"},
+		{"=HYPERLINK(\"http://evil\",\"klik\")", "'=HYPERLINK(\"http://evil\",\"klik\")"},
+		{"+62812", "'+62812"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"a=b", "a=b"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := csvText(tt.value); got != tt.want {
+			t.Errorf("csvText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRenderStatementCSVEscapesFormulas(t *testing.T) {
+	st := &Statement{
+		Month:    "2026-01",
+		FullName: "=cmd|' /C calc'!A0",
+		Transactions: []TransactionWithDetails{
+			{ID: 1, Type: "transfer_in", Direction: "credit", Amount: 10, Description: "=1+1", CreatedAt: time.Now()},
+		},
+	}
+	out, err := RenderStatementCSV(st)
+	if err != nil {
+		t.Fatalf("RenderStatementCSV() error = %v", err)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(out))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("rendered CSV does not parse: %v", err)
+	}
+	for _, record := range records {
+		for _, cell := range record {
+			if cell != "" && cell[0] == '=' {
+				t.Errorf("cell %q starts a formula", cell)
+			}
+		}
+	}
+}
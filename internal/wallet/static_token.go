@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// validateStaticToken checks the amount bounds of a new static token
+func validateStaticToken(req *PaymentTokenRequest) error {
+	if req.CaptureMode == "manual" {
+		return errors.New("QR statis tidak mendukung konfirmasi manual")
+	}
+	if req.MinAmount != nil && req.MaxAmount != nil && *req.MinAmount > *req.MaxAmount {
+		return errors.New("minimal nominal tidak boleh melebihi maksimal nominal")
+	}
+	return nil
+}
+
+// checkStaticAmount checks the amount a payer entered for a static token
+func checkStaticAmount(token *PaymentToken, amount int) error {
+	if amount <= 0 {
+		return errors.New("nominal pembayaran wajib diisi")
+	}
+	if token.MinAmount != nil && amount < *token.MinAmount {
+		return fmt.Errorf("nominal minimal %d poin", *token.MinAmount)
+	}
+	if token.MaxAmount != nil && amount > *token.MaxAmount {
+		return fmt.Errorf("nominal maksimal %d poin", *token.MaxAmount)
+	}
+	return nil
+}
+
+// lockStaticToken makes sure a static token is still active while a payment
+// is posted, a concurrent revoke waits for the payment to finish
+func lockStaticToken(tx *gorm.DB, tokenID uint) error {
+	var token PaymentToken
+	err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("id = ? AND status = ?", tokenID, "active").First(&token).Error
+	if err != nil {
+		return errors.New("token tidak valid")
+	}
+	return nil
+}
+
+// GetStaticTokens lists the static QR codes created by a user
+func (s *WalletService) GetStaticTokens(userID uint) ([]PaymentToken, error) {
+	wallet, err := s.repo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetStaticTokens(wallet.ID)
+}
+
+// GetStaticTokenPayments returns the payments received through a static or
+// split token
+func (s *WalletService) GetStaticTokenPayments(tokenCode string, userID uint) (*TokenPaymentHistory, error) {
+	token, err := s.ownToken(tokenCode, userID, "static", "split")
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := s.repo.GetTokenPayments(token.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &TokenPaymentHistory{Token: *token, Payments: payments}
+	for _, p := range payments {
+		history.TotalAmount += p.Amount
+	}
+	return history, nil
+}
+
+// RevokeStaticToken stops a static token from accepting more payments
+func (s *WalletService) RevokeStaticToken(tokenCode string, userID uint) (*PaymentToken, error) {
+	token, err := s.ownToken(tokenCode, userID, "static")
+	if err != nil {
+		return nil, err
+	}
+	if token.Status != "active" {
+		return nil, errors.New("token sudah tidak aktif")
+	}
+
+	result := s.db.Model(&PaymentToken{}).Where("id = ? AND status = ?", token.ID, "active").Update("status", "revoked")
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("token sudah tidak aktif")
+	}
+
+	token.Status = "revoked"
+	return token, nil
+}
+
+// ownToken finds a token of one of the given types created by the user
+func (s *WalletService) ownToken(tokenCode string, userID uint, types ...string) (*PaymentToken, error) {
+	token, err := s.GetTokenDetails(tokenCode)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(types, token.Type) {
+		return nil, errors.New("jenis token tidak didukung")
+	}
+
+	wallet, err := s.repo.FindByUserID(userID)
+	if err != nil || wallet.ID != token.WalletID {
+		return nil, errors.New("token bukan milik anda")
+	}
+	return token, nil
+}
@@ -0,0 +1,40 @@
+package wallet
+
+import "time"
+
+// StaticTokenLifetime is how long a static merchant QR stays valid unless
+// it is revoked earlier. Static codes are printed and put up at a stall, so
+// they must outlive a student's whole study; the expiry only keeps a
+// forgotten code from staying payable forever, revoking is how one is retired.
+const StaticTokenLifetime = 5 * 365 * 24 * time.Hour
+
+// SplitTokenLifetime is how long a split bill can collect contributions
+const SplitTokenLifetime = 24 * time.Hour
+
+// TokenPayment records one payment made through a static or split token,
+// which unlike other tokens can be paid many times
+type TokenPayment struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	TokenID       uint       `json:"token_id" gorm:"not null;index"`
+	PayerWalletID uint       `json:"payer_wallet_id" gorm:"not null;index"`
+	Amount        int        `json:"amount" gorm:"not null"`
+	TransactionID uint       `json:"transaction_id" gorm:"not null;index"` // Debit on the payer's wallet
+	RefundedAt    *time.Time `json:"refunded_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (TokenPayment) TableName() string {
+	return "token_payments"
+}
+
+type TokenPaymentWithPayer struct {
+	TokenPayment
+	PayerName   string `json:"payer_name"`
+	PayerNimNip string `json:"payer_nim_nip"`
+}
+
+type TokenPaymentHistory struct {
+	Token       PaymentToken            `json:"token"`
+	TotalAmount int                     `json:"total_amount"`
+	Payments    []TokenPaymentWithPayer `json:"payments"`
+}
@@ -0,0 +1,170 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"wallet-point/internal/audit"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWalletFrozen            = errors.New("wallet is frozen")
+	ErrWalletClosed            = errors.New("wallet is closed")
+	ErrRecipientWalletInactive = errors.New("recipient wallet cannot receive points")
+
+	errFreezeNotExpired = errors.New("wallet freeze has not expired")
+)
+
+// checkWalletStatus rejects movements on wallets that are not active or whose
+// owner is suspended. A freeze past its expiry no longer blocks, even before
+// the sweeper has lifted it. The recipient of a credit gets a generic error so
+// a sender does not learn why another user's wallet was blocked.
+func checkWalletStatus(wallet *Wallet, ownerStatus string, debited bool) error {
+	blocked := wallet.Status == "closed" || wallet.isFrozen(time.Now()) || ownerStatus == "suspended"
+	if !blocked {
+		return nil
+	}
+	if !debited {
+		return ErrRecipientWalletInactive
+	}
+	if wallet.Status == "closed" {
+		return ErrWalletClosed
+	}
+	return ErrWalletFrozen
+}
+
+// checkMovable looks up the owner of a wallet and checks that the wallet may
+// take part in a movement
+func (s *WalletService) checkMovable(tx *gorm.DB, wallet *Wallet, debited bool) error {
+	ownerStatus, err := s.repo.FindOwnerStatus(tx, wallet.ID)
+	if err != nil {
+		return err
+	}
+	return checkWalletStatus(wallet, ownerStatus, debited)
+}
+
+// FreezeWallet blocks every user-initiated movement on a wallet. Admin
+// adjustments, reversals and point expiry still apply.
+func (s *WalletService) FreezeWallet(walletID uint, req *FreezeWalletRequest) (*Wallet, error) {
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		return nil, errors.New("freeze expiry must be in the future")
+	}
+	return s.changeStatus(walletID, func(wallet *Wallet) (string, error) {
+		if wallet.Status == "closed" {
+			return "", ErrWalletClosed
+		}
+		return "frozen", nil
+	}, req.Reason, req.Until)
+}
+
+// UnfreezeWallet makes a frozen wallet active again
+func (s *WalletService) UnfreezeWallet(walletID uint) (*Wallet, error) {
+	return s.changeStatus(walletID, func(wallet *Wallet) (string, error) {
+		switch wallet.Status {
+		case "closed":
+			return "", ErrWalletClosed
+		case "active":
+			return "", errors.New("wallet is not frozen")
+		}
+		return "active", nil
+	}, "", nil)
+}
+
+// LiftExpiredFreezes makes wallets whose freeze has expired active again and
+// writes each change to the audit log
+func (s *WalletService) LiftExpiredFreezes() (int, error) {
+	now := time.Now()
+	wallets, err := s.repo.GetExpiredFreezes(now)
+	if err != nil {
+		return 0, err
+	}
+
+	lifted := 0
+	for _, w := range wallets {
+		var until *time.Time
+		_, err := s.changeStatus(w.ID, func(wallet *Wallet) (string, error) {
+			// An admin may have changed the freeze since the wallets were listed
+			if wallet.Status != "frozen" || wallet.isFrozen(now) {
+				return "", errFreezeNotExpired
+			}
+			until = wallet.FrozenUntil
+			return "active", nil
+		}, "", nil)
+		if errors.Is(err, errFreezeNotExpired) {
+			continue
+		}
+		if err != nil {
+			return lifted, err
+		}
+		lifted++
+
+		if s.auditService != nil {
+			s.auditService.LogActivity(audit.CreateAuditParams{
+				Action:   "UNFREEZE_WALLET",
+				Entity:   "WALLET",
+				EntityID: w.ID,
+				Details:  fmt.Sprintf("Wallet freeze expired at %s", until.Format(time.RFC3339)),
+			})
+		}
+	}
+	return lifted, nil
+}
+
+// RunFreezeExpiry lifts expired wallet freezes on a fixed interval. It
+// blocks, so start it in its own goroutine.
+func (s *WalletService) RunFreezeExpiry(interval time.Duration) {
+	for {
+		lifted, err := s.LiftExpiredFreezes()
+		if err != nil {
+			log.Printf("[FreezeExpiry] Run failed: %v", err)
+		} else if lifted > 0 {
+			log.Printf("[FreezeExpiry] Lifted %d expired wallet freezes", lifted)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// CloseWallet permanently closes an empty wallet
+func (s *WalletService) CloseWallet(walletID uint, req *CloseWalletRequest) (*Wallet, error) {
+	return s.changeStatus(walletID, func(wallet *Wallet) (string, error) {
+		if wallet.Status == "closed" {
+			return "", ErrWalletClosed
+		}
+		if wallet.Balance != 0 {
+			return "", errors.New("wallet balance must be zero before closing")
+		}
+		return "closed", nil
+	}, req.Reason, nil)
+}
+
+// changeStatus locks the wallet, lets next decide the new status from the
+// current one and stores it.
+func (s *WalletService) changeStatus(walletID uint, next func(wallet *Wallet) (string, error), reason string, frozenUntil *time.Time) (*Wallet, error) {
+	var updated *Wallet
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		wallet, err := s.repo.LockByID(tx, walletID)
+		if err != nil {
+			return err
+		}
+
+		status, err := next(wallet)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.UpdateStatus(tx, walletID, status, reason, frozenUntil); err != nil {
+			return err
+		}
+
+		wallet.Status = status
+		wallet.StatusReason = reason
+		wallet.FrozenUntil = frozenUntil
+		updated = wallet
+		return nil
+	})
+	return updated, err
+}
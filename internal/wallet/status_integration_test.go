@@ -0,0 +1,139 @@
+//go:build integration
+
+package wallet_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"wallet-point/internal/marketplace"
+	"wallet-point/internal/wallet"
+
+	"gorm.io/gorm"
+)
+
+func TestSplitBillForClosedRecipientIsRefunded(t *testing.T) {
+	env := setupIntegration(t)
+	recipient := env.createStudent(t, "closed-recipient", 0)
+	payer := env.createStudent(t, "split-payer", 100)
+
+	token, err := env.wallets.GeneratePaymentToken(wallet.PaymentTokenRequest{Type: "split", Amount: 90}, recipient.UserID, recipient.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.wallets.StudentPayToken(wallet.PaymentExecuteRequest{Payload: token.QRPayload, Amount: 30, PIN: "123456"}, payer.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.wallets.CloseWallet(recipient.ID, &wallet.CloseWalletRequest{Reason: "Graduated"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env.db.Model(&wallet.PaymentToken{}).Where("id = ?", token.ID).Update("expiry", time.Now().Add(-time.Minute))
+	if _, err := env.wallets.SweepPaymentTokens(); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloaded wallet.PaymentToken
+	env.db.First(&reloaded, token.ID)
+	r, p := env.reload(t, recipient.ID), env.reload(t, payer.ID)
+	if reloaded.Status != "refunded" || r.Balance != 0 || p.Balance != 100 {
+		t.Errorf("token %s, recipient %d, payer %d, want refunded, 0 and 100", reloaded.Status, r.Balance, p.Balance)
+	}
+	env.checkLedger(t, r, p)
+}
+
+func TestListingReleaseToClosedSellerRefundsBuyer(t *testing.T) {
+	env := setupIntegration(t)
+	seller := env.createStudent(t, "closed-seller", 0)
+	buyer := env.createStudent(t, "listing-buyer", 100)
+
+	listing, err := env.marketplace.CreateListing(seller.UserID, &marketplace.CreateListingRequest{Title: "Used calculator", Price: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := env.marketplace.BuyListing(buyer.UserID, listing.ID, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.wallets.CloseWallet(seller.ID, &wallet.CloseWalletRequest{Reason: "Graduated"}); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err = env.marketplace.ConfirmListingOrder(order.ID, buyer.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := env.marketplace.GetListing(listing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, b := env.reload(t, seller.ID), env.reload(t, buyer.ID)
+	if order.Status != "refunded" || reloaded.Status != "withdrawn" || s.Balance != 0 || b.Balance != 100 {
+		t.Errorf("order %s, listing %s, seller %d, buyer %d, want refunded, withdrawn, 0 and 100", order.Status, reloaded.Status, s.Balance, b.Balance)
+	}
+	env.checkLedger(t, s, b)
+}
+
+func TestFrozenWalletBlocksTransfers(t *testing.T) {
+	env := setupIntegration(t)
+	frozen := env.createStudent(t, "frozen", 100)
+	other := env.createStudent(t, "frozen-other", 100)
+
+	if _, err := env.wallets.FreezeWallet(frozen.ID, &wallet.FreezeWalletRequest{Reason: "Under review"}); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(from, to *wallet.Wallet) error {
+		return env.db.Transaction(func(tx *gorm.DB) error {
+			_, _, err := env.wallets.TransferWithTransaction(tx, from.ID, to.ID, 10, "Status test", "Status test", nil)
+			return err
+		})
+	}
+	if err := send(frozen, other); !errors.Is(err, wallet.ErrWalletFrozen) {
+		t.Errorf("transfer from frozen wallet error = %v, want ErrWalletFrozen", err)
+	}
+	if err := send(other, frozen); !errors.Is(err, wallet.ErrRecipientWalletInactive) {
+		t.Errorf("transfer to frozen wallet error = %v, want ErrRecipientWalletInactive", err)
+	}
+
+	// Admin adjustments still apply to a frozen wallet
+	if _, err := env.wallets.AdjustPoints(&wallet.AdjustmentRequest{WalletID: frozen.ID, Amount: 5, Direction: "credit", Description: "Status test"}, 0); err != nil {
+		t.Errorf("adjustment on frozen wallet error = %v", err)
+	}
+
+	if _, err := env.wallets.CloseWallet(frozen.ID, &wallet.CloseWalletRequest{Reason: "Graduated"}); err == nil {
+		t.Error("closed a wallet that still holds points")
+	}
+
+	if _, err := env.wallets.UnfreezeWallet(frozen.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := send(frozen, other); err != nil {
+		t.Errorf("transfer after unfreezing error = %v", err)
+	}
+
+	f, o := env.reload(t, frozen.ID), env.reload(t, other.ID)
+	if f.Balance != 95 || o.Balance != 110 {
+		t.Errorf("balances frozen %d, other %d, want 95 and 110", f.Balance, o.Balance)
+	}
+	env.checkLedger(t, f, o)
+}
+
+func TestExpiredFreezeIsLifted(t *testing.T) {
+	env := setupIntegration(t)
+	w := env.createStudent(t, "freeze-expiry", 0)
+
+	until := time.Now().Add(time.Hour)
+	if _, err := env.wallets.FreezeWallet(w.ID, &wallet.FreezeWalletRequest{Reason: "Cooling off", Until: &until}); err != nil {
+		t.Fatal(err)
+	}
+	env.db.Model(&wallet.Wallet{}).Where("id = ?", w.ID).Update("frozen_until", time.Now().Add(-time.Minute))
+
+	if _, err := env.wallets.LiftExpiredFreezes(); err != nil {
+		t.Fatal(err)
+	}
+	if status := env.reload(t, w.ID).Status; status != "active" {
+		t.Errorf("status after the freeze expired %s, want active", status)
+	}
+}
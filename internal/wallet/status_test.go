@@ -0,0 +1,38 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckWalletStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		wallet      Wallet
+		ownerStatus string
+		debited     bool
+		want        error
+	}{
+		{"active debit", Wallet{Status: "active"}, "active", true, nil},
+		{"active credit", Wallet{Status: "active"}, "active", false, nil},
+		{"frozen debit", Wallet{Status: "frozen"}, "active", true, ErrWalletFrozen},
+		{"frozen until later", Wallet{Status: "frozen", FrozenUntil: &future}, "active", true, ErrWalletFrozen},
+		{"freeze expired", Wallet{Status: "frozen", FrozenUntil: &past}, "active", true, nil},
+		{"closed debit", Wallet{Status: "closed"}, "active", true, ErrWalletClosed},
+		{"suspended owner debit", Wallet{Status: "active"}, "suspended", true, ErrWalletFrozen},
+		// The sender of a credit must not learn why the recipient is blocked
+		{"frozen recipient", Wallet{Status: "frozen"}, "active", false, ErrRecipientWalletInactive},
+		{"closed recipient", Wallet{Status: "closed"}, "active", false, ErrRecipientWalletInactive},
+		{"suspended recipient", Wallet{Status: "active"}, "suspended", false, ErrRecipientWalletInactive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkWalletStatus(&tt.wallet, tt.ownerStatus, tt.debited); got != tt.want {
+				t.Errorf("checkWalletStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
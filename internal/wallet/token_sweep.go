@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"log"
+	"time"
+	"wallet-point/internal/realtime"
+)
+
+// SweepPaymentTokens expires active and partially paid split tokens past their
+// expiry, clears the QR images of tokens that can no longer be paid and
+// deletes old finished tokens. Static and split tokens are kept for their
+// payment history.
+func (s *WalletService) SweepPaymentTokens() (*TokenSweepResult, error) {
+	now := time.Now()
+	result := &TokenSweepResult{}
+
+	expired, refunded, err := s.expireSplitTokens(now)
+	if err != nil {
+		return result, err
+	}
+	result.Expired += expired
+	result.Refunded += refunded
+
+	for {
+		tokens, err := s.repo.GetExpiredActiveTokens(now, tokenSweepBatch)
+		if err != nil {
+			return result, err
+		}
+		if len(tokens) == 0 {
+			break
+		}
+
+		ids := make([]uint, len(tokens))
+		for i, t := range tokens {
+			ids[i] = t.ID
+		}
+		expired, err := s.repo.ExpireTokens(ids)
+		if err != nil {
+			return result, err
+		}
+		result.Expired += expired
+
+		for i := range tokens {
+			tokens[i].Status = "expired"
+			s.publishTokenEvent(&tokens[i], realtime.EventTokenExpired)
+		}
+		if len(tokens) < tokenSweepBatch {
+			break
+		}
+	}
+
+	for {
+		cleared, err := s.repo.ClearTokenQRCodes(now.Add(-TokenQRRetention), tokenSweepBatch)
+		if err != nil {
+			return result, err
+		}
+		result.QRCleared += cleared
+		if cleared < tokenSweepBatch {
+			break
+		}
+	}
+
+	for {
+		purged, err := s.repo.PurgeTokens(now.Add(-TokenRetention), tokenSweepBatch)
+		if err != nil {
+			return result, err
+		}
+		result.Purged += purged
+		if purged < tokenSweepBatch {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// RunTokenSweeper sweeps payment tokens on a fixed interval and records the
+// metrics of every run. It blocks, so start it in its own goroutine.
+func (s *WalletService) RunTokenSweeper(interval time.Duration) {
+	for {
+		started := time.Now()
+		result, err := s.SweepPaymentTokens()
+		s.recordTokenSweep(started, result, err)
+
+		if err != nil {
+			log.Printf("[TokenSweeper] Run failed: %v", err)
+		} else if result.Expired > 0 || result.Refunded > 0 || result.QRCleared > 0 || result.Purged > 0 {
+			log.Printf("[TokenSweeper] Expired %d tokens, refunded %d split bills, cleared %d QR codes, purged %d tokens", result.Expired, result.Refunded, result.QRCleared, result.Purged)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// GetTokenSweeperStats returns the sweeper metrics since the server started
+func (s *WalletService) GetTokenSweeperStats() TokenSweeperStats {
+	s.sweepMu.Lock()
+	defer s.sweepMu.Unlock()
+	return s.sweepStats
+}
+
+func (s *WalletService) recordTokenSweep(started time.Time, result *TokenSweepResult, err error) {
+	s.sweepMu.Lock()
+	defer s.sweepMu.Unlock()
+
+	stats := &s.sweepStats
+	stats.Runs++
+	stats.LastRunAt = &started
+	stats.LastDurationMs = time.Since(started).Milliseconds()
+	stats.LastError = ""
+	if err != nil {
+		stats.Failures++
+		stats.LastError = err.Error()
+	}
+
+	// A failed run may still have handled some tokens
+	stats.LastResult = *result
+	stats.Totals.Expired += result.Expired
+	stats.Totals.QRCleared += result.QRCleared
+	stats.Totals.Purged += result.Purged
+	stats.Totals.Refunded += result.Refunded
+}
@@ -0,0 +1,32 @@
+package wallet
+
+import "time"
+
+const (
+	// TokenQRRetention is how long the QR image of a token that can no longer
+	// be paid is kept
+	TokenQRRetention = 24 * time.Hour
+	// TokenRetention is how long consumed, expired and voided tokens are kept
+	TokenRetention = 90 * 24 * time.Hour
+
+	tokenSweepBatch = 500
+)
+
+// TokenSweepResult counts the tokens handled by one sweeper run
+type TokenSweepResult struct {
+	Expired   int64 `json:"expired"`
+	QRCleared int64 `json:"qr_cleared"`
+	Purged    int64 `json:"purged"`
+	Refunded  int64 `json:"refunded"` // Split tokens whose contributions were refunded
+}
+
+// TokenSweeperStats are the sweeper metrics since the server started
+type TokenSweeperStats struct {
+	Runs           int64            `json:"runs"`
+	Failures       int64            `json:"failures"`
+	LastRunAt      *time.Time       `json:"last_run_at"`
+	LastDurationMs int64            `json:"last_duration_ms"`
+	LastError      string           `json:"last_error,omitempty"`
+	LastResult     TokenSweepResult `json:"last_result"`
+	Totals         TokenSweepResult `json:"totals"`
+}
@@ -44,3 +44,27 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// StreamTicketAuth authenticates the event stream with a ticket from
+// ?ticket=, for clients that cannot set headers like the browser EventSource.
+// Requests without a ticket fall back to the Authorization header.
+func StreamTicketAuth() gin.HandlerFunc {
+	headerAuth := AuthMiddleware()
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			headerAuth(c)
+			return
+		}
+
+		userID, err := utils.ParseStreamTicket(ticket)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error(), nil)
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", userID)
+		c.Next()
+	}
+}
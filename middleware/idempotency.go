@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"wallet-point/internal/idempotency"
+	"wallet-point/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyHeader    = "Idempotency-Key"
+	idempotencyKeyMaxLen = 191
+	idempotencyTTL       = 24 * time.Hour
+)
+
+// responseRecorder keeps a copy of the response body so it can be replayed
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency honours the Idempotency-Key header on money-moving endpoints.
+// A retried request with the same key gets the stored response and the handler
+// is not run again. Must be registered after AuthMiddleware.
+func Idempotency(repo *idempotency.IdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyKeyMaxLen {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Idempotency-Key is too long", nil)
+			c.Abort()
+			return
+		}
+
+		userID := c.GetUint("user_id")
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read request body", nil)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		existing, err := repo.Find(userID, key)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check Idempotency-Key", err.Error())
+			c.Abort()
+			return
+		}
+
+		if existing != nil && time.Now().After(existing.ExpiresAt) {
+			repo.Delete(existing.ID)
+			existing = nil
+		}
+
+		if existing != nil {
+			switch {
+			case existing.RequestHash != requestHash:
+				utils.ErrorResponse(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request", nil)
+			case existing.Status != "completed":
+				utils.ErrorResponse(c, http.StatusConflict, "A request with this Idempotency-Key is still being processed", nil)
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.ResponseCode, "application/json; charset=utf-8", []byte(existing.ResponseBody))
+			}
+			c.Abort()
+			return
+		}
+
+		record := &idempotency.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: requestHash,
+			Status:      "processing",
+			ExpiresAt:   time.Now().Add(idempotencyTTL),
+		}
+		if err := repo.Create(record); err != nil {
+			// Another request with the same key won the race
+			utils.ErrorResponse(c, http.StatusConflict, "A request with this Idempotency-Key is still being processed", nil)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		// Release the key if the handler panics, the recovery middleware answers with a 500
+		defer func() {
+			if r := recover(); r != nil {
+				repo.Delete(record.ID)
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		// Server errors are not stored so the client can safely retry them
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := repo.Delete(record.ID); err != nil {
+				log.Printf("[Idempotency] Failed to release key %s: %v", key, err)
+			}
+			return
+		}
+
+		if err := repo.Complete(record.ID, recorder.Status(), recorder.body.String()); err != nil {
+			log.Printf("[Idempotency] Failed to store response for key %s: %v", key, err)
+		}
+	}
+}
+
+// RunIdempotencyCleanup removes expired idempotency keys on a fixed interval.
+// It blocks, so start it once in its own goroutine.
+func RunIdempotencyCleanup(repo *idempotency.IdempotencyRepository, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if _, err := repo.DeleteExpired(time.Now()); err != nil {
+			log.Printf("[Idempotency] Failed to purge expired keys: %v", err)
+		}
+	}
+}
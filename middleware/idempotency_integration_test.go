@@ -0,0 +1,91 @@
+//go:build integration
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"wallet-point/internal/database"
+	"wallet-point/internal/idempotency"
+	"wallet-point/middleware"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Run with TEST_DB_DSN pointing at a development database, see
+// internal/wallet/integration_test.go
+
+func TestIdempotencyReplaysAndRejectsConflicts(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Migrate(db)
+	gin.SetMode(gin.TestMode)
+
+	// A user ID no real user has, so keys never clash with other runs
+	userID := uint(2_000_000_000 + time.Now().UnixNano()%100_000_000)
+	t.Cleanup(func() { db.Where("user_id = ?", userID).Delete(&idempotency.IdempotencyKey{}) })
+
+	calls, fail := 0, false
+	r := gin.New()
+	r.POST("/pay", func(c *gin.Context) { c.Set("user_id", userID) },
+		middleware.Idempotency(idempotency.NewIdempotencyRepository(db)),
+		func(c *gin.Context) {
+			calls++
+			if fail {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "down"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"payment": calls})
+		})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/pay", strings.NewReader(body))
+		req.Header.Set(middleware.IdempotencyHeader, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("pay-1", `{"amount":10}`)
+	retry := send("pay-1", `{"amount":10}`)
+	if first.Code != http.StatusOK || retry.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("first %d, retry %d with %d handler calls, want 200, 200 and 1 call", first.Code, retry.Code, calls)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry replayed %q with body %s, want the first response %s",
+			retry.Header().Get("Idempotent-Replayed"), retry.Body.String(), first.Body.String())
+	}
+
+	if w := send("pay-1", `{"amount":99}`); w.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("reused key with another body got %d with %d handler calls, want 422 and 1 call", w.Code, calls)
+	}
+
+	// Server errors are not stored, the retry runs the handler again
+	fail = true
+	if w := send("pay-2", `{"amount":10}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("failing request got %d, want 500", w.Code)
+	}
+	fail = false
+	if w := send("pay-2", `{"amount":10}`); w.Code != http.StatusOK || calls != 3 {
+		t.Errorf("retry after a server error got %d with %d handler calls, want 200 and 3 calls", w.Code, calls)
+	}
+
+	var stored int64
+	db.Model(&idempotency.IdempotencyKey{}).Where("user_id = ?", userID).Count(&stored)
+	if stored != 2 {
+		t.Errorf("%d keys stored, want 2", stored)
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyWithoutStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	r := gin.New()
+	// Requests that never reach the store work without one
+	r.POST("/pay", Idempotency(nil), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	tests := []struct {
+		name      string
+		key       string
+		wantCode  int
+		wantCalls int
+	}{
+		{"no key runs the handler", "", http.StatusOK, 1},
+		{"overlong key is rejected", strings.Repeat("k", idempotencyKeyMaxLen+1), http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			req := httptest.NewRequest(http.MethodPost, "/pay", strings.NewReader(`{"amount":10}`))
+			if tt.key != "" {
+				req.Header.Set(IdempotencyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode || calls != tt.wantCalls {
+				t.Errorf("got %d with %d handler calls, want %d with %d", w.Code, calls, tt.wantCode, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -3,8 +3,13 @@ package routes
 import (
 	"wallet-point/internal/audit"
 	"wallet-point/internal/auth"
+	"wallet-point/internal/budget"
+	"wallet-point/internal/fraud"
+	"wallet-point/internal/idempotency"
+	"wallet-point/internal/limit"
 	"wallet-point/internal/marketplace"
 	"wallet-point/internal/mission"
+	"wallet-point/internal/realtime"
 	"wallet-point/internal/transfer"
 	"wallet-point/internal/user"
 	"wallet-point/internal/wallet"
@@ -21,7 +26,16 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRoutes(r *gin.Engine, db *gorm.DB, allowedOrigins string, jwtExpiry int, uploadPath string) {
+// Services are the parts SetupRoutes wires up that have background jobs,
+// for main to start
+type Services struct {
+	Wallet      *wallet.WalletService
+	Marketplace *marketplace.MarketplaceService
+	Transfer    *transfer.Service
+	Idempotency *idempotency.IdempotencyRepository
+}
+
+func SetupRoutes(r *gin.Engine, db *gorm.DB, allowedOrigins string, jwtExpiry int, uploadPath string) *Services {
 	// Apply global middleware
 	r.Use(middleware.CORS(allowedOrigins))
 	r.Use(middleware.Logger())
@@ -52,27 +66,49 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, allowedOrigins string, jwtExpiry in
 	marketplaceRepo := marketplace.NewMarketplaceRepository(db)
 	auditRepo := audit.NewAuditRepository(db)
 	missionRepo := mission.NewMissionRepository(db)
+	idempotencyRepo := idempotency.NewIdempotencyRepository(db)
+	limitRepo := limit.NewLimitRepository(db)
+	budgetRepo := budget.NewBudgetRepository(db)
+	fraudRepo := fraud.NewFraudRepository(db)
 
 	// Initialize services
 	authService := auth.NewAuthService(authRepo, jwtExpiry)
 	userService := user.NewUserService(userRepo)
 	walletService := wallet.NewWalletService(walletRepo, db)
 	walletService.SetAuthService(authService) // Inject for PIN verification
+	limitService := limit.NewLimitService(limitRepo)
+	budgetService := budget.NewBudgetService(budgetRepo)
+	walletService.SetLimitService(limitService)
+	walletService.SetBudgetService(budgetService) // Lecturer rewards are taken from point budgets
+	walletService.SetBroker(realtime.NewBroker()) // Push token and balance events to connected clients
 
 	marketplaceService := marketplace.NewMarketplaceService(marketplaceRepo, walletService, authService, db)
+	marketplaceService.SetLimitService(limitService)
+	walletService.RegisterReversalHook("marketplace", marketplaceService.HandleReversal) // Restock reversed purchases
 	auditService := audit.NewAuditService(auditRepo)
+	walletService.SetAuditService(auditService) // Audit freezes lifted on expiry
+	fraudService := fraud.NewFraudService(fraudRepo, auditService)
+	walletService.SetFraudService(fraudService) // Screen QR payments and transfers for point farming
 	missionService := mission.NewMissionService(missionRepo, walletService, db)
 	transferService := transfer.NewService(walletRepo, walletService, authService, db)
+	transferService.SetLimitService(limitService)
+	transferService.SetFraudService(fraudService)
 
 	// Initialize handlers
 	authHandler := auth.NewAuthHandler(authService, auditService)
 	userHandler := user.NewUserHandler(userService, auditService)
 	walletHandler := wallet.NewWalletHandler(walletService, auditService)
 	marketplaceHandler := marketplace.NewMarketplaceHandler(marketplaceService, auditService)
+	limitHandler := limit.NewLimitHandler(limitService, auditService)
+	budgetHandler := budget.NewBudgetHandler(budgetService, auditService)
+	fraudHandler := fraud.NewFraudHandler(fraudService, auditService)
 	auditHandler := audit.NewAuditHandler(auditService)
 	missionHandler := mission.NewMissionHandler(missionService, auditService, uploadPath)
 	transferHandler := transfer.NewHandler(transferService, auditService)
 
+	// Replays retried money-moving requests instead of executing them twice
+	idempotent := middleware.Idempotency(idempotencyRepo)
+
 	// ========================================
 	// PUBLIC ROUTES
 	// ========================================
@@ -104,20 +140,62 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, allowedOrigins string, jwtExpiry in
 		adminGroup.GET("/wallets", walletHandler.GetAllWallets)
 		adminGroup.GET("/wallets/:id", walletHandler.GetWalletByID)
 		adminGroup.GET("/wallets/:id/transactions", walletHandler.GetWalletTransactions)
+		adminGroup.GET("/wallets/:id/ledger", walletHandler.GetWalletLedger)
+		adminGroup.GET("/wallets/:id/statement", walletHandler.GetWalletStatement)
+		adminGroup.POST("/wallets/:id/freeze", walletHandler.FreezeWallet)
+		adminGroup.DELETE("/wallets/:id/freeze", walletHandler.UnfreezeWallet)
+		adminGroup.POST("/wallets/:id/close", walletHandler.CloseWallet)
+		adminGroup.GET("/wallets/:id/holds", walletHandler.GetWalletHolds)
+		adminGroup.POST("/holds/:id/void", walletHandler.ReleaseHold)
+		adminGroup.GET("/payment-tokens/sweeper", walletHandler.GetTokenSweeperStats)
 		adminGroup.POST("/wallet/adjustment", walletHandler.AdjustPoints)
 		adminGroup.POST("/wallet/reset", walletHandler.ResetWallet)
 
 		// Transaction Monitoring
 		adminGroup.GET("/transactions", walletHandler.GetAllTransactions)
+		adminGroup.POST("/transactions/:id/reverse", walletHandler.ReverseTransaction)
+		adminGroup.GET("/reconciliation", walletHandler.GetReconciliation)
+		adminGroup.POST("/reconciliation/repair", walletHandler.RepairReconciliation)
+
+		// Spending and transfer limits
+		adminGroup.GET("/limits", limitHandler.GetPolicies)
+		adminGroup.POST("/limits", limitHandler.CreatePolicy)
+		adminGroup.PUT("/limits/:id", limitHandler.UpdatePolicy)
+		adminGroup.DELETE("/limits/:id", limitHandler.DeletePolicy)
+		adminGroup.GET("/limits/users/:id", limitHandler.GetUserLimits)
+
+		// Point Budgets
+		adminGroup.GET("/budgets", budgetHandler.GetBudgets)
+		adminGroup.POST("/budgets", budgetHandler.AllocateBudget)
+		adminGroup.GET("/budgets/requests", budgetHandler.GetRewardRequests)
+		adminGroup.POST("/budgets/requests/:id/review", walletHandler.ReviewRewardRequest)
 		adminGroup.GET("/transfers", transferHandler.GetAllTransfers)
 
+		// Fraud and velocity rules
+		adminGroup.GET("/fraud/rules", fraudHandler.GetRules)
+		adminGroup.PUT("/fraud/rules/:code", fraudHandler.UpdateRule)
+		adminGroup.GET("/fraud/checks", fraudHandler.GetChecks)
+		adminGroup.GET("/fraud/reviews", fraudHandler.GetReviewQueue)
+		adminGroup.POST("/fraud/reviews/:id/review", fraudHandler.ReviewCheck)
+
 		// Marketplace Management
 		adminGroup.GET("/marketplace/transactions", marketplaceHandler.GetTransactions)
+		adminGroup.GET("/marketplace/orders", marketplaceHandler.GetFulfilmentQueue)
+		adminGroup.POST("/marketplace/orders/pickup", marketplaceHandler.PickUpOrder)
+		adminGroup.POST("/marketplace/orders/:id/ready", marketplaceHandler.MarkOrderReady)
+		adminGroup.POST("/marketplace/orders/:id/cancel", idempotent, marketplaceHandler.CancelOrder)
+		adminGroup.GET("/marketplace/listing-orders", marketplaceHandler.GetAllListingOrders)
+		adminGroup.POST("/marketplace/listing-orders/:id/resolve", marketplaceHandler.ResolveListingOrder)
 		adminGroup.GET("/products", marketplaceHandler.GetAll)
 		adminGroup.POST("/products", marketplaceHandler.Create)
 		adminGroup.GET("/products/:id", marketplaceHandler.GetByID)
 		adminGroup.PUT("/products/:id", marketplaceHandler.Update)
 		adminGroup.DELETE("/products/:id", marketplaceHandler.Delete)
+		adminGroup.GET("/product-categories", marketplaceHandler.GetCategories)
+		adminGroup.POST("/product-categories", marketplaceHandler.CreateCategory)
+		adminGroup.PUT("/product-categories/:id", marketplaceHandler.UpdateCategory)
+		adminGroup.DELETE("/product-categories/:id", marketplaceHandler.DeleteCategory)
+		adminGroup.GET("/product-tags", marketplaceHandler.GetTags)
 
 		// Audit Logs
 		adminGroup.GET("/audit-logs", auditHandler.GetAll)
@@ -150,6 +228,11 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, allowedOrigins string, jwtExpiry in
 		// Monitoring & Manual Rewards
 		dosenGroup.GET("/students", userHandler.GetAll)
 		dosenGroup.POST("/reward", walletHandler.AdjustPoints)
+		dosenGroup.POST("/reward/batch", walletHandler.CreateRewardBatch)
+		dosenGroup.GET("/reward/batches", walletHandler.GetRewardBatches)
+		dosenGroup.GET("/reward/batches/:id", walletHandler.GetRewardBatch)
+		dosenGroup.GET("/budget", budgetHandler.GetMyBudget)
+		dosenGroup.GET("/budget/requests", budgetHandler.GetMyRewardRequests)
 	}
 
 	// ========================================
@@ -166,32 +249,76 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, allowedOrigins string, jwtExpiry in
 		mahasiswaGroup.GET("/submissions", missionHandler.GetAllSubmissions)
 
 		// Transfer Points
-		mahasiswaGroup.POST("/transfer", transferHandler.CreateTransfer)
+		mahasiswaGroup.POST("/transfer", idempotent, transferHandler.CreateTransfer)
+		mahasiswaGroup.POST("/transfer/quote", transferHandler.QuoteTransfer)
+		mahasiswaGroup.POST("/transfer/confirm", idempotent, transferHandler.ConfirmTransfer)
 		mahasiswaGroup.GET("/transfer/history", transferHandler.GetMyTransfers)
 		mahasiswaGroup.GET("/transfer/recipient/:id", transferHandler.GetRecipientInfo)
+		mahasiswaGroup.POST("/transfer/requests", transferHandler.CreatePaymentRequest)
+		mahasiswaGroup.GET("/transfer/requests", transferHandler.GetPaymentRequests)
+		mahasiswaGroup.POST("/transfer/requests/:id/accept", idempotent, transferHandler.AcceptPaymentRequest)
+		mahasiswaGroup.POST("/transfer/requests/:id/decline", transferHandler.DeclinePaymentRequest)
+		mahasiswaGroup.POST("/transfer/requests/:id/cancel", transferHandler.CancelPaymentRequest)
+		mahasiswaGroup.POST("/transfer/schedules", transferHandler.ScheduleTransfer)
+		mahasiswaGroup.GET("/transfer/schedules", transferHandler.GetScheduledTransfers)
+		mahasiswaGroup.GET("/transfer/schedules/:id/runs", transferHandler.GetScheduleRuns)
+		mahasiswaGroup.POST("/transfer/schedules/:id/pause", transferHandler.PauseScheduledTransfer)
+		mahasiswaGroup.POST("/transfer/schedules/:id/resume", transferHandler.ResumeScheduledTransfer)
+		mahasiswaGroup.POST("/transfer/schedules/:id/cancel", transferHandler.CancelScheduledTransfer)
 		mahasiswaGroup.GET("/users/lookup", userHandler.LookupUser)
 
 		// Marketplace & Cart
 		mahasiswaGroup.GET("/marketplace/products", marketplaceHandler.GetAll)
 		mahasiswaGroup.GET("/marketplace/products/:id", marketplaceHandler.GetByID)
-		mahasiswaGroup.POST("/marketplace/purchase", marketplaceHandler.Purchase)
+		mahasiswaGroup.GET("/marketplace/categories", marketplaceHandler.GetCategories)
+		mahasiswaGroup.GET("/marketplace/tags", marketplaceHandler.GetTags)
+		mahasiswaGroup.POST("/marketplace/purchase", idempotent, marketplaceHandler.Purchase)
 		mahasiswaGroup.GET("/marketplace/cart", marketplaceHandler.GetCart)
 		mahasiswaGroup.POST("/marketplace/cart", marketplaceHandler.AddToCart)
 		mahasiswaGroup.PUT("/marketplace/cart/:id", marketplaceHandler.UpdateCartItem)
 		mahasiswaGroup.DELETE("/marketplace/cart/:id", marketplaceHandler.RemoveFromCart)
-		mahasiswaGroup.POST("/marketplace/cart/checkout", marketplaceHandler.Checkout)
+		mahasiswaGroup.POST("/marketplace/cart/checkout", idempotent, marketplaceHandler.Checkout)
+		mahasiswaGroup.GET("/marketplace/orders", marketplaceHandler.GetMyOrders)
+		mahasiswaGroup.GET("/marketplace/orders/:id", marketplaceHandler.GetMyOrder)
+		mahasiswaGroup.POST("/marketplace/orders/:id/cancel", idempotent, marketplaceHandler.CancelOrder)
+
+		// Student listings, paid through escrow
+		mahasiswaGroup.GET("/marketplace/listings", marketplaceHandler.GetListings)
+		mahasiswaGroup.GET("/marketplace/listings/mine", marketplaceHandler.GetMyListings)
+		mahasiswaGroup.GET("/marketplace/listings/:id", marketplaceHandler.GetListing)
+		mahasiswaGroup.POST("/marketplace/listings", marketplaceHandler.CreateListing)
+		mahasiswaGroup.PUT("/marketplace/listings/:id", marketplaceHandler.UpdateListing)
+		mahasiswaGroup.DELETE("/marketplace/listings/:id", marketplaceHandler.WithdrawListing)
+		mahasiswaGroup.POST("/marketplace/listings/:id/buy", idempotent, marketplaceHandler.BuyListing)
+		mahasiswaGroup.GET("/marketplace/listing-orders", marketplaceHandler.GetMyListingOrders)
+		mahasiswaGroup.POST("/marketplace/listing-orders/:id/confirm", idempotent, marketplaceHandler.ConfirmListingOrder)
+		mahasiswaGroup.POST("/marketplace/listing-orders/:id/dispute", marketplaceHandler.DisputeListingOrder)
 
 		// Gamification
 		mahasiswaGroup.GET("/leaderboard", walletHandler.GetLeaderboard)
 
 		// Personal Wallet
 		mahasiswaGroup.GET("/wallet", walletHandler.GetMyWallet)
+		mahasiswaGroup.GET("/wallet/holds", walletHandler.GetMyHolds)
+		mahasiswaGroup.GET("/wallet/statement", walletHandler.GetMyStatement)
+		mahasiswaGroup.GET("/limits", limitHandler.GetMyLimits)
 		mahasiswaGroup.GET("/transactions", walletHandler.GetMyTransactions)
 		mahasiswaGroup.POST("/payment/token", walletHandler.GeneratePaymentToken)
-		mahasiswaGroup.POST("/payment/execute", walletHandler.ExecuteStudentPayment)
+		mahasiswaGroup.POST("/payment/execute", idempotent, walletHandler.ExecuteStudentPayment)
+		mahasiswaGroup.POST("/payment/token/:token/capture", idempotent, walletHandler.CaptureTokenPayment)
+		mahasiswaGroup.POST("/payment/token/:token/void", walletHandler.VoidTokenPayment)
+		mahasiswaGroup.GET("/payment/static", walletHandler.GetStaticTokens)
+		mahasiswaGroup.GET("/payment/token/:token/payments", walletHandler.GetStaticTokenPayments)
+		mahasiswaGroup.POST("/payment/token/:token/revoke", walletHandler.RevokeStaticToken)
 	}
 	// Global QR Status Check
 	api.GET("/payment/status/:token", walletHandler.CheckTokenStatus)
+	api.POST("/payment/verify", walletHandler.VerifyQRPayload)
+
+	// Realtime token and balance events (SSE) for any signed in user. Browsers
+	// open the stream with a short-lived ticket instead of the session JWT.
+	api.POST("/events/ticket", middleware.AuthMiddleware(), walletHandler.IssueStreamTicket)
+	api.GET("/events", middleware.StreamTicketAuth(), walletHandler.StreamEvents)
 	api.GET("/missions/:id/leaderboard", missionHandler.GetQuizLeaderboard)
 
 	// Health check
@@ -204,4 +331,11 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, allowedOrigins string, jwtExpiry in
 
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	return &Services{
+		Wallet:      walletService,
+		Marketplace: marketplaceService,
+		Transfer:    transferService,
+		Idempotency: idempotencyRepo,
+	}
 }
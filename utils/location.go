@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"log"
+	"time"
+)
+
+var location = time.Local
+
+// InitLocation sets the time zone that days and months are counted in, e.g.
+// when daily limits reset, regardless of where the server runs
+func InitLocation(name string) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: unknown time zone %q, using the server's local time: %v", name, err)
+		return
+	}
+	location = loc
+}
+
+// Location returns the time zone set by InitLocation
+func Location() *time.Location {
+	return location
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestDeriveSigningKey(t *testing.T) {
+	secret := "jwt-secret"
+	key := DeriveSigningKey(secret)
+	if key == secret || key != DeriveSigningKey(secret) {
+		t.Fatalf("DeriveSigningKey() = %q, want a stable key other than the secret", key)
+	}
+	if key == DeriveSigningKey("other-secret") {
+		t.Error("DeriveSigningKey() gave two secrets the same key")
+	}
+
+	// A signature made with the JWT secret must not pass as a QR signature
+	InitSigning(key)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("payload"))
+	if VerifySignature("payload", base64.RawURLEncoding.EncodeToString(mac.Sum(nil))) {
+		t.Error("VerifySignature() accepted a signature made with the JWT secret")
+	}
+	if !VerifySignature("payload", Sign("payload")) {
+		t.Error("VerifySignature() rejected its own signature")
+	}
+}
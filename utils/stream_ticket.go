@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamTicketTTL is how long a stream ticket can be used to open the event
+// stream. Reconnecting clients fetch a new one.
+const StreamTicketTTL = time.Minute
+
+const streamTicketVersion = "ST1"
+
+var ErrInvalidStreamTicket = errors.New("invalid or expired stream ticket")
+
+// IssueStreamTicket returns a short-lived ticket that only opens the event
+// stream of a user. Browsers pass it in the URL, where the session JWT would
+// end up in access logs.
+func IssueStreamTicket(userID uint) (string, time.Time) {
+	expiresAt := time.Now().Add(StreamTicketTTL)
+	signed := fmt.Sprintf("%s.%d.%d", streamTicketVersion, userID, expiresAt.Unix())
+	return signed + "." + Sign(signed), expiresAt
+}
+
+// ParseStreamTicket checks a ticket made by IssueStreamTicket and returns
+// the user it was issued to
+func ParseStreamTicket(ticket string) (uint, error) {
+	parts := strings.Split(ticket, ".")
+	if len(parts) != 4 || parts[0] != streamTicketVersion {
+		return 0, ErrInvalidStreamTicket
+	}
+	if !VerifySignature(strings.Join(parts[:3], "."), parts[3]) {
+		return 0, ErrInvalidStreamTicket
+	}
+
+	userID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidStreamTicket
+	}
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return 0, ErrInvalidStreamTicket
+	}
+	return uint(userID), nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamTicket(t *testing.T) {
+	InitSigning("test-signing-key")
+
+	ticket, expiresAt := IssueStreamTicket(42)
+	if !expiresAt.After(time.Now()) {
+		t.Fatalf("IssueStreamTicket() expires at %v, want in the future", expiresAt)
+	}
+
+	userID, err := ParseStreamTicket(ticket)
+	if err != nil || userID != 42 {
+		t.Fatalf("ParseStreamTicket() = %d, %v, want 42", userID, err)
+	}
+
+	parts := strings.Split(ticket, ".")
+	expired := "ST1.42.1000000000"
+	tests := []struct {
+		name   string
+		ticket string
+	}{
+		{"other user", parts[0] + ".43." + parts[2] + "." + parts[3]},
+		{"extended expiry", parts[0] + "." + parts[1] + ".9999999999." + parts[3]},
+		{"expired", expired + "." + Sign(expired)},
+		{"session JWT", "eyJhbGciOiJIUzI1NiJ9.eyJ1c2VyX2lkIjo0Mn0.c2ln"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseStreamTicket(tt.ticket); err != ErrInvalidStreamTicket {
+				t.Errorf("ParseStreamTicket() error = %v, want ErrInvalidStreamTicket", err)
+			}
+		})
+	}
+}